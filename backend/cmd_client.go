@@ -0,0 +1,421 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"nova-speed/backend/pkg/client"
+)
+
+// clientReport is the JSON output of the client command. Phases that were
+// not run are omitted.
+type clientReport struct {
+	Server        string                    `json:"server"`
+	SessionID     string                    `json:"sessionId,omitempty"`
+	Ping          *client.PingResult        `json:"ping,omitempty"`
+	Download      *client.DownloadResult    `json:"download,omitempty"`
+	DownloadStats *client.DownloadStats     `json:"downloadClient,omitempty"`
+	Upload        *client.UploadResult      `json:"upload,omitempty"`
+	UploadStats   *client.UploadStats       `json:"uploadClient,omitempty"`
+	Quality       *client.ConnectionQuality `json:"quality,omitempty"`
+	Readiness     *client.Readiness         `json:"readiness,omitempty"`
+	Errors        map[string]string         `json:"errors,omitempty"`
+}
+
+var clientTests = []string{"ping", "download", "upload"}
+
+// runClientCommand implements `client`, a headless speed test against a
+// running server. It returns the process exit code: 0 when every selected
+// test completed, 1 when any failed, 2 on usage errors.
+func runClientCommand(args []string) int {
+	fs := flag.NewFlagSet("client", flag.ContinueOnError)
+	server := fs.String("server", "ws://localhost:3001", "server base URL (http, https, ws or wss)")
+	tests := fs.String("tests", strings.Join(clientTests, ","), "comma-separated tests to run: ping, download, upload")
+	jsonOutput := fs.Bool("json", false, "print results as JSON")
+	chunkSize := fs.Int("chunk-size", 0, "initial download chunk size in bytes (0 = server default)")
+	uploadDuration := fs.Duration("upload-duration", 15*time.Second, "stop sending upload data after this long if the server has not finished")
+	insecure := fs.Bool("insecure", false, "skip TLS certificate verification (self-signed certificates)")
+	session := fs.Bool("session", false, "run all tests over one /ws/test session instead of one socket per test")
+	showProgress := fs.Bool("progress", true, "show the server's live throughput while testing (terminal only)")
+	streams := fs.Int("streams", 1, "parallel connections for the download test (not with -session)")
+	quicAddr := fs.String("quic", "", "run the tests over the server's QUIC listener at this host:port (not with -session or -streams)")
+	tcpAddr := fs.String("tcp", "", "run download and upload over the server's raw TCP port at this host:port, with -streams flows (not with -session or -quic)")
+	tcpDuration := fs.Duration("tcp-duration", 0, "raw TCP test duration (0 = server default)")
+	loadedLatency := fs.Bool("loaded-latency", true, "measure latency during download and upload and grade bufferbloat")
+	pingCount := fs.Int("ping-count", 0, "number of ping probes (0 = server default)")
+	pingInterval := fs.Duration("ping-interval", 0, "delay between ping probes (0 = server default)")
+	udp := fs.Bool("udp", false, "run the ping test over UDP to see real packet loss (not with -session)")
+	udpRate := fs.Int("udp-rate", 0, "UDP probes per second (0 = server default)")
+	udpCount := fs.Int("udp-count", 0, "number of UDP probes (0 = server default)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: nova-speed client [flags]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	selected, err := parseClientTests(*tests)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "client: %v\n", err)
+		return 2
+	}
+	if *streams < 1 {
+		fmt.Fprintln(os.Stderr, "client: -streams must be at least 1")
+		return 2
+	}
+	if *streams > 1 && *session {
+		fmt.Fprintln(os.Stderr, "client: -streams cannot be combined with -session")
+		return 2
+	}
+	if *quicAddr != "" && (*session || *streams > 1) {
+		fmt.Fprintln(os.Stderr, "client: -quic cannot be combined with -session or -streams")
+		return 2
+	}
+	if *udp && *session {
+		fmt.Fprintln(os.Stderr, "client: -udp cannot be combined with -session")
+		return 2
+	}
+	if *tcpAddr != "" && (*session || *quicAddr != "") {
+		fmt.Fprintln(os.Stderr, "client: -tcp cannot be combined with -session or -quic")
+		return 2
+	}
+
+	c := client.New(*server)
+	if err := c.Err(); err != nil {
+		fmt.Fprintf(os.Stderr, "client: %v\n", err)
+		return 2
+	}
+	if *insecure {
+		c.TLSConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	c.QUICAddr = *quicAddr
+	c.TCPAddr = *tcpAddr
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	report := &clientReport{Server: *server}
+	fail := func(test string, err error) {
+		if report.Errors == nil {
+			report.Errors = make(map[string]string)
+		}
+		report.Errors[test] = err.Error()
+		if !*jsonOutput {
+			fmt.Printf("%-9s failed: %v\n", test+":", err)
+		}
+	}
+
+	if !*jsonOutput {
+		if *quicAddr != "" {
+			fmt.Printf("Testing against %s over QUIC (%s)\n", *server, *quicAddr)
+		} else if *tcpAddr != "" {
+			fmt.Printf("Testing against %s, raw TCP on %s\n", *server, *tcpAddr)
+		} else {
+			fmt.Printf("Testing against %s\n", *server)
+		}
+	}
+
+	live := &liveProgress{enabled: *showProgress && !*jsonOutput && isTerminal(os.Stderr)}
+	downloadOpts := client.DownloadOptions{ChunkSize: *chunkSize, Streams: *streams, LoadedLatency: *loadedLatency, OnServerProgress: live.show("Download:")}
+	uploadOpts := client.UploadOptions{MaxDuration: *uploadDuration, LoadedLatency: *loadedLatency, OnServerProgress: live.show("Upload:")}
+	pingOpts := client.PingOptions{Count: *pingCount, Interval: *pingInterval}
+	tcpOpts := client.TCPOptions{Duration: *tcpDuration, Flows: *streams}
+
+	if *session {
+		summary, err := c.RunTest(ctx, client.TestOptions{
+			Phases:        selected,
+			Ping:          pingOpts,
+			Download:      downloadOpts,
+			Upload:        uploadOpts,
+			LoadedLatency: *loadedLatency,
+			OnSession:     func(id string) { report.SessionID = id },
+		})
+		live.clear()
+		if err != nil {
+			fail("session", err)
+		} else {
+			report.Ping, report.Download, report.Upload = summary.PingResult, summary.DownloadResult, summary.UploadResult
+			report.Quality, report.Readiness = summary.Quality, summary.Readiness
+			if !*jsonOutput {
+				printPing(report.Ping)
+				printDownload(report.Download, nil)
+				printUpload(report.Upload, nil)
+			}
+		}
+	}
+
+	// Group the separate tests under one server session so they can be
+	// looked up by ID; older servers without sessions still work
+	if !*session {
+		if s, err := c.CreateSession(ctx); err == nil {
+			c.SessionID = s.ID
+			report.SessionID = s.ID
+		}
+	}
+
+	for _, test := range selected {
+		if *session || ctx.Err() != nil {
+			break
+		}
+		switch test {
+		case "ping":
+			var result *client.PingResult
+			if *udp {
+				result, err = c.UDPPing(ctx, client.UDPOptions{Count: *udpCount, Rate: *udpRate})
+			} else {
+				result, err = c.Ping(ctx, pingOpts)
+			}
+			if err != nil {
+				fail(test, err)
+				continue
+			}
+			report.Ping = result
+			if !*jsonOutput {
+				printPing(result)
+			}
+		case "download":
+			var result *client.DownloadResult
+			var stats *client.DownloadStats
+			if *tcpAddr != "" {
+				result, stats, err = c.TCPDownload(ctx, tcpOpts)
+			} else {
+				result, stats, err = c.Download(ctx, downloadOpts)
+			}
+			live.clear()
+			if err != nil {
+				fail(test, err)
+				continue
+			}
+			report.Download, report.DownloadStats = result, stats
+			if !*jsonOutput {
+				printDownload(result, stats)
+			}
+		case "upload":
+			var result *client.UploadResult
+			var stats *client.UploadStats
+			if *tcpAddr != "" {
+				result, stats, err = c.TCPUpload(ctx, tcpOpts)
+			} else {
+				result, stats, err = c.Upload(ctx, uploadOpts)
+			}
+			live.clear()
+			if err != nil {
+				fail(test, err)
+				continue
+			}
+			report.Upload, report.UploadStats = result, stats
+			if !*jsonOutput {
+				printUpload(result, stats)
+			}
+		}
+	}
+
+	// The server scores the session as phases complete
+	if !*session && report.SessionID != "" && ctx.Err() == nil {
+		if s, err := c.GetSession(ctx, report.SessionID); err == nil {
+			report.Quality, report.Readiness = s.Result.Quality, s.Result.Readiness
+		}
+	}
+
+	if !*jsonOutput {
+		printQuality(report.Quality)
+		printReadiness(report.Readiness)
+		if report.SessionID != "" {
+			fmt.Printf("Test ID:  %s\n", report.SessionID)
+		}
+	}
+
+	if *jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			fmt.Fprintf(os.Stderr, "client: %v\n", err)
+			return 1
+		}
+	}
+
+	if ctx.Err() != nil || len(report.Errors) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// The print helpers skip phases that did not run; client-side stats are
+// only available when each test has its own socket
+
+func printPing(result *client.PingResult) {
+	if result == nil {
+		return
+	}
+	fmt.Printf("Ping:     %.2f ms (min %.2f, max %.2f), jitter %.2f ms, loss %.1f%% (%d packets)\n",
+		result.Latency, result.MinLatency, result.MaxLatency, result.Jitter, result.PacketLoss, result.Packets)
+	if result.Packets > 0 {
+		fmt.Printf("          p50 %.2f, p90 %.2f, p95 %.2f, p99 %.2f ms, stddev %.2f ms, RFC 3550 jitter %.2f ms\n",
+			result.P50Latency, result.P90Latency, result.P95Latency, result.P99Latency, result.LatencyStdDev, result.JitterRFC3550)
+	}
+	if result.Late > 0 || result.Duplicates > 0 || result.Reordered > 0 {
+		fmt.Printf("          %d sent, %d late, %d duplicates, %d reordered\n",
+			result.Sent, result.Late, result.Duplicates, result.Reordered)
+	}
+	if result.UpstreamLatency != 0 || result.DownstreamLatency != 0 {
+		fmt.Printf("          one-way: %.2f ms up, %.2f ms down (clock offset %.2f ms)\n",
+			result.UpstreamLatency, result.DownstreamLatency, result.ClockOffset)
+	}
+}
+
+func printDownload(result *client.DownloadResult, stats *client.DownloadStats) {
+	if result == nil {
+		return
+	}
+	fmt.Printf("Download: %.2f Mbps, %s in %.2f s, TTFB %.2f ms (server)",
+		result.Throughput, formatBytes(result.Bytes), result.Duration, result.TTFB)
+	if stats != nil {
+		fmt.Printf(" / %.2f ms (client)", stats.TTFB)
+	}
+	fmt.Println()
+	printStreams(result.StreamResults)
+	printLatencyUnderLoad(result.LatencyUnderLoad)
+}
+
+func printUpload(result *client.UploadResult, stats *client.UploadStats) {
+	if result == nil {
+		return
+	}
+	fmt.Printf("Upload:   %.2f Mbps, %s in %.2f s", result.Throughput, formatBytes(result.Bytes), result.Duration)
+	if stats != nil {
+		fmt.Printf(" (%s sent)", formatBytes(stats.Bytes))
+	}
+	fmt.Println()
+	printStreams(result.StreamResults)
+	printLatencyUnderLoad(result.LatencyUnderLoad)
+}
+
+func printLatencyUnderLoad(l client.LatencyUnderLoad) {
+	if l.BufferbloatGrade == "" {
+		return
+	}
+	fmt.Printf("          loaded latency %.2f ms (idle %.2f, +%.2f), bufferbloat grade %s\n",
+		l.LoadedLatency, l.IdleLatency, l.LatencyIncrease, l.BufferbloatGrade)
+}
+
+func printStreams(streams []client.StreamResult) {
+	if len(streams) < 2 {
+		return
+	}
+	speeds := make([]string, len(streams))
+	for i, s := range streams {
+		speeds[i] = fmt.Sprintf("%.2f", s.Throughput)
+	}
+	fmt.Printf("          %d streams: %s Mbps\n", len(streams), strings.Join(speeds, ", "))
+}
+
+func printQuality(quality *client.ConnectionQuality) {
+	if quality == nil {
+		return
+	}
+	verdict := "unstable"
+	if quality.IsStable {
+		verdict = "stable"
+	}
+	fmt.Printf("Quality:  %.0f/100, %s%s\n", quality.StabilityScore, verdict, formatCodes(quality.Recommendations))
+}
+
+func printReadiness(readiness *client.Readiness) {
+	if readiness == nil {
+		return
+	}
+	if s := readiness.Streaming; s != nil {
+		fmt.Printf("Stream:   up to %s (score %.0f)\n", s.RecommendedQuality, s.Score)
+	}
+	if g := readiness.Gaming; g != nil {
+		fmt.Printf("Gaming:   %s (score %.0f)%s\n", g.Rating, g.OverallScore, formatCodes(g.Recommendations))
+	}
+	if v := readiness.VideoCall; v != nil {
+		fmt.Printf("Calls:    %s (score %.0f)%s\n", v.Rating, v.OverallScore, formatCodes(v.Recommendations))
+	}
+}
+
+func formatCodes(codes []string) string {
+	if len(codes) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(codes, ", ") + ")"
+}
+
+// liveProgress keeps one status line on stderr updated from the server's
+// progress messages
+type liveProgress struct {
+	enabled bool
+	active  bool
+}
+
+func (l *liveProgress) show(label string) func(client.ProgressMessage) {
+	if !l.enabled {
+		return nil
+	}
+	return func(p client.ProgressMessage) {
+		fmt.Fprintf(os.Stderr, "\r\033[K%-9s %.2f Mbps, %s in %.1f s", label, p.Mbps, formatBytes(p.Bytes), p.Elapsed)
+		l.active = true
+	}
+}
+
+// clear erases the status line so results print in its place
+func (l *liveProgress) clear() {
+	if l.active {
+		fmt.Fprint(os.Stderr, "\r\033[K")
+		l.active = false
+	}
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func parseClientTests(value string) ([]string, error) {
+	var selected []string
+	seen := make(map[string]bool)
+	for _, name := range strings.Split(value, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" || seen[name] {
+			continue
+		}
+		known := false
+		for _, t := range clientTests {
+			if t == name {
+				known = true
+			}
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown test %q (use %s)", name, strings.Join(clientTests, ", "))
+		}
+		seen[name] = true
+		selected = append(selected, name)
+	}
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("no tests selected")
+	}
+	return selected, nil
+}
+
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.2f GB", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.2f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.2f KB", float64(n)/(1<<10))
+	default:
+		return fmt.Sprintf("%d B", n)
+	}
+}
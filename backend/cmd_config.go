@@ -0,0 +1,42 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"nova-speed/backend/internal/config"
+)
+
+// runConfigCommand implements `config check`, which loads and validates the
+// configuration without starting the server. It returns the process exit
+// code: 0 when valid, 1 when invalid, 2 on usage errors.
+func runConfigCommand(args []string) int {
+	if len(args) == 0 || args[0] != "check" {
+		fmt.Fprintln(os.Stderr, "usage: nova-speed config check [-config path]")
+		return 2
+	}
+
+	fs := flag.NewFlagSet("config check", flag.ContinueOnError)
+	configPath := fs.String("config", "", "path to a YAML or TOML config file (defaults to $"+config.ConfigPathEnv+")")
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	if _, err := config.Load(*configPath); err != nil {
+		var validationErr *config.ValidationError
+		if errors.As(err, &validationErr) {
+			fmt.Fprintf(os.Stderr, "configuration is invalid (%d errors):\n", len(validationErr.Errors))
+			for _, fe := range validationErr.Errors {
+				fmt.Fprintf(os.Stderr, "  - %s\n", fe)
+			}
+		} else {
+			fmt.Fprintf(os.Stderr, "configuration is invalid: %v\n", err)
+		}
+		return 1
+	}
+
+	fmt.Println("configuration OK")
+	return 0
+}
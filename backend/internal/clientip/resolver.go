@@ -0,0 +1,178 @@
+// Package clientip works out the real client address of a request that may
+// have passed through reverse proxies, trusting forwarding headers only when
+// they were added by a configured proxy.
+package clientip
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// UnixPeer stands in for the client address of a request from a Unix socket
+// peer whose forwarding headers don't name the client
+const UnixPeer = "unix"
+
+// Header looks up a request header by name
+type Header func(name string) string
+
+// Resolver resolves client IPs using a list of trusted proxy networks
+type Resolver struct {
+	trusted []*net.IPNet
+}
+
+// NewResolver parses the trusted proxies. Entries may be CIDR ranges or
+// single addresses.
+func NewResolver(trustedProxies []string) (*Resolver, error) {
+	r := &Resolver{}
+	for _, entry := range trustedProxies {
+		network, err := ParseNetwork(entry)
+		if err != nil {
+			return nil, err
+		}
+		r.trusted = append(r.trusted, network)
+	}
+	return r, nil
+}
+
+// ParseNetwork parses a CIDR range or a single IP (treated as /32 or /128)
+func ParseNetwork(entry string) (*net.IPNet, error) {
+	entry = strings.TrimSpace(entry)
+	if strings.Contains(entry, "/") {
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q", entry)
+		}
+		return network, nil
+	}
+
+	ip := net.ParseIP(entry)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid IP %q", entry)
+	}
+	bits := 128
+	if ip4 := ip.To4(); ip4 != nil {
+		ip, bits = ip4, 32
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+}
+
+// IsTrusted reports whether ip belongs to a trusted proxy
+func (r *Resolver) IsTrusted(ip net.IP) bool {
+	if r == nil || ip == nil {
+		return false
+	}
+	for _, network := range r.trusted {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Resolve returns the client IP for a request received from peer.
+//
+// Headers are ignored unless peer is a trusted proxy. Otherwise the hop
+// chain from Forwarded (RFC 7239) or, failing that, X-Forwarded-For is
+// walked right to left, skipping trusted proxies; the first untrusted hop is
+// the client. X-Real-IP and CF-Connecting-IP are used only when no chain
+// header is present.
+func (r *Resolver) Resolve(peer net.IP, header Header) string {
+	return r.ResolveFrom(peer, r.IsTrusted(peer), header)
+}
+
+// ResolveFrom is Resolve for callers that already know whether the peer is
+// trusted. A nil peer is one without an IP address, i.e. a reverse proxy on
+// a Unix socket, and is always trusted; if its headers don't name a client,
+// the result is UnixPeer.
+func (r *Resolver) ResolveFrom(peer net.IP, peerTrusted bool, header Header) string {
+	if !peerTrusted && peer != nil {
+		return peer.String()
+	}
+
+	hops := parseForwarded(header("Forwarded"))
+	if len(hops) == 0 {
+		hops = parseXForwardedFor(header("X-Forwarded-For"))
+	}
+	if len(hops) > 0 {
+		return r.walk(peer, hops)
+	}
+
+	for _, name := range []string{"X-Real-IP", "CF-Connecting-IP"} {
+		if ip := parseHop(header(name)); ip != nil {
+			return ip.String()
+		}
+	}
+
+	return peerString(peer)
+}
+
+// walk returns the rightmost untrusted hop. If a hop is not a usable
+// address (e.g. "unknown" or an obfuscated Forwarded identifier), nothing to
+// its left can be trusted, so the nearest proxy address is returned instead.
+func (r *Resolver) walk(peer net.IP, hops []string) string {
+	client := peer
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := parseHop(hops[i])
+		if ip == nil {
+			break
+		}
+		client = ip
+		if !r.IsTrusted(ip) {
+			break
+		}
+	}
+	return peerString(client)
+}
+
+// peerString formats a peer or hop address, nil being a Unix socket peer
+func peerString(ip net.IP) string {
+	if ip == nil {
+		return UnixPeer
+	}
+	return ip.String()
+}
+
+func parseXForwardedFor(value string) []string {
+	if value == "" {
+		return nil
+	}
+	return strings.Split(value, ",")
+}
+
+// parseForwarded extracts the for= parameter of each Forwarded element, in order
+func parseForwarded(value string) []string {
+	if value == "" {
+		return nil
+	}
+
+	var hops []string
+	for _, element := range strings.Split(value, ",") {
+		forValue := ""
+		for _, pair := range strings.Split(element, ";") {
+			key, val, ok := strings.Cut(strings.TrimSpace(pair), "=")
+			if ok && strings.EqualFold(key, "for") {
+				forValue = strings.Trim(val, `"`)
+			}
+		}
+		// An element without for= still counts as a hop we can't see past
+		hops = append(hops, forValue)
+	}
+	return hops
+}
+
+// parseHop parses an address as it appears in forwarding headers: a bare
+// IPv4/IPv6 address, optionally bracketed and/or followed by a port
+func parseHop(hop string) net.IP {
+	hop = strings.TrimSpace(hop)
+	if hop == "" {
+		return nil
+	}
+	if ip := net.ParseIP(hop); ip != nil {
+		return ip
+	}
+	if host, _, err := net.SplitHostPort(hop); err == nil {
+		return net.ParseIP(host)
+	}
+	return net.ParseIP(strings.Trim(hop, "[]"))
+}
@@ -0,0 +1,244 @@
+package clientip
+
+import (
+	"net"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	r, err := NewResolver([]string{"10.0.0.0/8", "192.168.1.1", "fd00::/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		peer    string
+		headers map[string]string
+		want    string
+	}{
+		{
+			name: "untrusted peer ignores headers",
+			peer: "203.0.113.5",
+			headers: map[string]string{
+				"X-Forwarded-For": "198.51.100.1",
+				"X-Real-IP":       "198.51.100.2",
+			},
+			want: "203.0.113.5",
+		},
+		{
+			name: "trusted peer without headers",
+			peer: "10.0.0.2",
+			want: "10.0.0.2",
+		},
+		{
+			name:    "X-Forwarded-For client",
+			peer:    "10.0.0.2",
+			headers: map[string]string{"X-Forwarded-For": "198.51.100.1"},
+			want:    "198.51.100.1",
+		},
+		{
+			name:    "X-Forwarded-For skips trusted hops",
+			peer:    "10.0.0.2",
+			headers: map[string]string{"X-Forwarded-For": "198.51.100.1, 10.1.2.3, 192.168.1.1"},
+			want:    "198.51.100.1",
+		},
+		{
+			name:    "X-Forwarded-For spoofed entries left of the client",
+			peer:    "10.0.0.2",
+			headers: map[string]string{"X-Forwarded-For": "1.2.3.4, 198.51.100.1, 10.1.2.3"},
+			want:    "198.51.100.1",
+		},
+		{
+			name:    "X-Forwarded-For all trusted",
+			peer:    "10.0.0.2",
+			headers: map[string]string{"X-Forwarded-For": "10.1.1.1, 10.2.2.2"},
+			want:    "10.1.1.1",
+		},
+		{
+			name:    "X-Forwarded-For unusable hop stops the walk",
+			peer:    "10.0.0.2",
+			headers: map[string]string{"X-Forwarded-For": "198.51.100.1, unknown, 10.1.2.3"},
+			want:    "10.1.2.3",
+		},
+		{
+			name:    "X-Forwarded-For with port",
+			peer:    "10.0.0.2",
+			headers: map[string]string{"X-Forwarded-For": "198.51.100.1:4711"},
+			want:    "198.51.100.1",
+		},
+		{
+			name: "Forwarded wins over X-Forwarded-For",
+			peer: "10.0.0.2",
+			headers: map[string]string{
+				"Forwarded":       `for=198.51.100.7;proto=https, for=10.1.2.3`,
+				"X-Forwarded-For": "198.51.100.1",
+			},
+			want: "198.51.100.7",
+		},
+		{
+			name:    "Forwarded IPv6 with port",
+			peer:    "10.0.0.2",
+			headers: map[string]string{"Forwarded": `For="[2001:db8::1]:4711"`},
+			want:    "2001:db8::1",
+		},
+		{
+			name:    "Forwarded obfuscated identifier",
+			peer:    "10.0.0.2",
+			headers: map[string]string{"Forwarded": `for=_hidden, for=10.1.2.3`},
+			want:    "10.1.2.3",
+		},
+		{
+			name:    "Forwarded element without for",
+			peer:    "10.0.0.2",
+			headers: map[string]string{"Forwarded": `proto=https`},
+			want:    "10.0.0.2",
+		},
+		{
+			name:    "X-Real-IP",
+			peer:    "192.168.1.1",
+			headers: map[string]string{"X-Real-IP": "198.51.100.3"},
+			want:    "198.51.100.3",
+		},
+		{
+			name: "X-Real-IP before CF-Connecting-IP",
+			peer: "192.168.1.1",
+			headers: map[string]string{
+				"X-Real-IP":        "198.51.100.3",
+				"CF-Connecting-IP": "198.51.100.4",
+			},
+			want: "198.51.100.3",
+		},
+		{
+			name:    "CF-Connecting-IP",
+			peer:    "192.168.1.1",
+			headers: map[string]string{"CF-Connecting-IP": "198.51.100.4"},
+			want:    "198.51.100.4",
+		},
+		{
+			name:    "chain header wins over X-Real-IP",
+			peer:    "10.0.0.2",
+			headers: map[string]string{"X-Forwarded-For": "198.51.100.1", "X-Real-IP": "198.51.100.3"},
+			want:    "198.51.100.1",
+		},
+		{
+			name:    "invalid X-Real-IP",
+			peer:    "10.0.0.2",
+			headers: map[string]string{"X-Real-IP": "not an ip"},
+			want:    "10.0.0.2",
+		},
+		{
+			name:    "trusted IPv6 peer",
+			peer:    "fd12::1",
+			headers: map[string]string{"X-Forwarded-For": "2001:db8::2"},
+			want:    "2001:db8::2",
+		},
+		{
+			name:    "single trusted address is exact",
+			peer:    "192.168.1.2",
+			headers: map[string]string{"X-Forwarded-For": "198.51.100.1"},
+			want:    "192.168.1.2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := func(name string) string { return tt.headers[name] }
+			if got := r.Resolve(net.ParseIP(tt.peer), header); got != tt.want {
+				t.Errorf("Resolve() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveFrom(t *testing.T) {
+	r, err := NewResolver(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		peer    net.IP
+		trusted bool
+		headers map[string]string
+		want    string
+	}{
+		{
+			name:    "trusted peer",
+			peer:    net.ParseIP("203.0.113.5"),
+			trusted: true,
+			headers: map[string]string{"X-Forwarded-For": "198.51.100.1"},
+			want:    "198.51.100.1",
+		},
+		{
+			name:    "untrusted peer",
+			peer:    net.ParseIP("203.0.113.5"),
+			headers: map[string]string{"X-Forwarded-For": "198.51.100.1"},
+			want:    "203.0.113.5",
+		},
+		// A Unix socket peer has no address to check, and is trusted
+		{
+			name:    "Unix socket peer",
+			headers: map[string]string{"X-Forwarded-For": "198.51.100.1"},
+			want:    "198.51.100.1",
+		},
+		{
+			name:    "Unix socket peer with X-Real-IP",
+			headers: map[string]string{"X-Real-IP": "198.51.100.2"},
+			want:    "198.51.100.2",
+		},
+		{
+			name: "Unix socket peer without headers",
+			want: UnixPeer,
+		},
+		{
+			name:    "Unix socket peer with unusable hop",
+			headers: map[string]string{"X-Forwarded-For": "unknown"},
+			want:    UnixPeer,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := func(name string) string { return tt.headers[name] }
+			if got := r.ResolveFrom(tt.peer, tt.trusted, header); got != tt.want {
+				t.Errorf("ResolveFrom() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseNetwork(t *testing.T) {
+	tests := []struct {
+		entry   string
+		want    string
+		wantErr bool
+	}{
+		{entry: "10.0.0.0/8", want: "10.0.0.0/8"},
+		{entry: " 10.1.2.3/8 ", want: "10.0.0.0/8"},
+		{entry: "192.168.1.1", want: "192.168.1.1/32"},
+		{entry: "::1", want: "::1/128"},
+		{entry: "fd00::/8", want: "fd00::/8"},
+		{entry: "10.0.0.0/33", wantErr: true},
+		{entry: "localhost", wantErr: true},
+		{entry: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		network, err := ParseNetwork(tt.entry)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseNetwork(%q) = %v, want an error", tt.entry, network)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseNetwork(%q): %v", tt.entry, err)
+			continue
+		}
+		if got := network.String(); got != tt.want {
+			t.Errorf("ParseNetwork(%q) = %s, want %s", tt.entry, got, tt.want)
+		}
+	}
+}
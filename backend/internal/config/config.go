@@ -1,75 +1,454 @@
 package config
 
 import (
+	"bytes"
+	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+
+	"nova-speed/backend/internal/clientip"
+	"nova-speed/backend/internal/listen"
+	"nova-speed/backend/internal/origins"
+	"nova-speed/backend/internal/scoring"
 )
 
+// ConfigPathEnv names the environment variable that points at a config file
+// when no -config flag is given.
+const ConfigPathEnv = "NOVA_CONFIG"
+
+// Config is the complete server configuration. It is built in three layers:
+// built-in defaults, an optional YAML/TOML file, and environment overrides.
 type Config struct {
-	Port           string
-	AllowedOrigins string
-	MaxConnections int
-	EnableLogging  bool
-	EnableMetrics  bool
-	GeoIPCityPath  string
-	GeoIPASNPath   string
-	GeoIPISPPath   string
+	Server ServerConfig `yaml:"server" toml:"server"`
+	TLS    TLSConfig    `yaml:"tls" toml:"tls"`
+	QUIC   QUICConfig   `yaml:"quic" toml:"quic"`
+	TCP    TCPConfig    `yaml:"tcp" toml:"tcp"`
+	UDP    UDPConfig    `yaml:"udp" toml:"udp"`
+	CORS   CORSConfig   `yaml:"cors" toml:"cors"`
+	Limits LimitsConfig `yaml:"limits" toml:"limits"`
+	GeoIP  GeoIPConfig  `yaml:"geoip" toml:"geoip"`
+	Tests  TestsConfig  `yaml:"tests" toml:"tests"`
+
+	Sessions SessionsConfig `yaml:"sessions" toml:"sessions"`
+	Storage  StorageConfig  `yaml:"storage" toml:"storage"`
+
+	// Threshold tables for the streaming, gaming and video call verdicts
+	Scoring scoring.Thresholds `yaml:"scoring" toml:"scoring"`
+
+	originMatcher *origins.Matcher   // Compiled from CORS.AllowedOrigins by Load
+	ipResolver    *clientip.Resolver // Compiled from Server.TrustedProxies by Load
 }
 
-func Load() *Config {
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "3001" // Default to 3001 to avoid conflicts with web servers on 8080
+type ServerConfig struct {
+	Port string `yaml:"port" toml:"port"`
+	// Listen overrides Port with explicit addresses (see package listen),
+	// e.g. "0.0.0.0:3001", "[::]:3001" or "unix:/run/nova-speed.sock"
+	Listen        []string `yaml:"listen" toml:"listen"`
+	EnableLogging bool     `yaml:"enableLogging" toml:"enableLogging"`
+	EnableMetrics bool     `yaml:"enableMetrics" toml:"enableMetrics"`
+	LogLevel      string   `yaml:"logLevel" toml:"logLevel"`
+	AdminToken    string   `yaml:"adminToken" toml:"adminToken"` // Enables POST /admin/reload when set
+
+	// Reverse proxies (CIDRs or IPs) whose forwarding headers are believed
+	TrustedProxies []string `yaml:"trustedProxies" toml:"trustedProxies"`
+}
+
+// TLSConfig enables a native HTTPS listener when both CertFile and KeyFile are set
+type TLSConfig struct {
+	CertFile       string        `yaml:"certFile" toml:"certFile"`
+	KeyFile        string        `yaml:"keyFile" toml:"keyFile"`
+	Port           string        `yaml:"port" toml:"port"`
+	Listen         []string      `yaml:"listen" toml:"listen"`                 // Overrides Port, same forms as server.listen
+	MinVersion     string        `yaml:"minVersion" toml:"minVersion"`         // "1.2" or "1.3"
+	CipherSuites   []string      `yaml:"cipherSuites" toml:"cipherSuites"`     // TLS 1.2 suites; empty uses Go's defaults
+	RedirectHTTP   bool          `yaml:"redirectHTTP" toml:"redirectHTTP"`     // Redirect plain HTTP requests to HTTPS
+	ReloadInterval time.Duration `yaml:"reloadInterval" toml:"reloadInterval"` // How often cert files are checked for changes
+}
+
+// Enabled reports whether a certificate is configured
+func (t TLSConfig) Enabled() bool {
+	return t.CertFile != "" || t.KeyFile != ""
+}
+
+// ListenAddrs returns the HTTPS listen addresses
+func (t TLSConfig) ListenAddrs() []string {
+	if len(t.Listen) > 0 {
+		return t.Listen
 	}
+	return []string{":" + t.Port}
+}
 
-	allowedOrigins := os.Getenv("ALLOWED_ORIGINS")
-	if allowedOrigins == "" {
-		// Default to production domain and localhost for development
-		// Include common local network ranges for local testing
-		allowedOrigins = "https://hashmatrix.dev,https://www.hashmatrix.dev,http://localhost:3000,http://localhost:5173,http://192.168.0.0/16,http://10.0.0.0/8,http://172.16.0.0/12"
+// HTTPSPort returns the port used for HTTP -> HTTPS redirects: the first
+// TCP HTTPS listener's port
+func (t TLSConfig) HTTPSPort() string {
+	for _, raw := range t.ListenAddrs() {
+		if addr, err := listen.Parse(raw); err == nil && addr.Port() != "" {
+			return addr.Port()
+		}
+	}
+	return t.Port
+}
+
+// QUICConfig enables the QUIC test listener. It serves the TLS certificate
+// when one is configured and a self-signed one otherwise.
+type QUICConfig struct {
+	Listen string `yaml:"listen" toml:"listen"` // UDP address, e.g. ":3444"; empty disables QUIC
+}
+
+// TCPConfig enables the raw TCP throughput test port
+type TCPConfig struct {
+	Listen string `yaml:"listen" toml:"listen"` // TCP address, e.g. ":5201"; empty disables raw TCP tests
+}
+
+// UDPConfig enables the UDP latency test listener
+type UDPConfig struct {
+	Listen string `yaml:"listen" toml:"listen"` // UDP address, e.g. ":3478"; empty disables the UDP test
+}
+
+type CORSConfig struct {
+	AllowedOrigins []string `yaml:"allowedOrigins" toml:"allowedOrigins"`
+}
+
+type LimitsConfig struct {
+	MaxConnections int `yaml:"maxConnections" toml:"maxConnections"`
+}
+
+// SessionsConfig bounds the in-memory test sessions
+type SessionsConfig struct {
+	TTL         time.Duration `yaml:"ttl" toml:"ttl"`                 // How long a session is kept after its last update
+	MaxSessions int           `yaml:"maxSessions" toml:"maxSessions"` // Oldest sessions are evicted beyond this
+}
+
+// StorageConfig locates the result store. An empty path keeps nothing.
+type StorageConfig struct {
+	Path string `yaml:"path" toml:"path"`
+}
+
+type GeoIPConfig struct {
+	CityPath string `yaml:"cityPath" toml:"cityPath"`
+	ASNPath  string `yaml:"asnPath" toml:"asnPath"`
+	ISPPath  string `yaml:"ispPath" toml:"ispPath"`
+}
+
+// TestsConfig holds the tuning knobs for each speed test phase
+type TestsConfig struct {
+	Ping     PingTestConfig     `yaml:"ping" toml:"ping"`
+	UDP      UDPTestConfig      `yaml:"udp" toml:"udp"`
+	Download DownloadTestConfig `yaml:"download" toml:"download"`
+	Upload   UploadTestConfig   `yaml:"upload" toml:"upload"`
+}
+
+type PingTestConfig struct {
+	Count    int           `yaml:"count" toml:"count"`
+	Interval time.Duration `yaml:"interval" toml:"interval"`
+	Timeout  time.Duration `yaml:"timeout" toml:"timeout"`
+}
+
+// UDPTestConfig holds the defaults of the UDP latency test; clients may
+// ask for another count and rate
+type UDPTestConfig struct {
+	Count   int           `yaml:"count" toml:"count"`
+	Rate    int           `yaml:"rate" toml:"rate"`       // Probes per second
+	Timeout time.Duration `yaml:"timeout" toml:"timeout"` // Wait for echoes after the last probe
+}
+
+type DownloadTestConfig struct {
+	Duration          time.Duration `yaml:"duration" toml:"duration"`
+	InitialChunkSize  int           `yaml:"initialChunkSize" toml:"initialChunkSize"`
+	MinChunkSize      int           `yaml:"minChunkSize" toml:"minChunkSize"`
+	MaxChunkSize      int           `yaml:"maxChunkSize" toml:"maxChunkSize"`
+	MaxStreams        int           `yaml:"maxStreams" toml:"maxStreams"`
+	MaxThroughputMbps float64       `yaml:"maxThroughputMbps" toml:"maxThroughputMbps"`
+	ProgressInterval  time.Duration `yaml:"progressInterval" toml:"progressInterval"` // 0 sends no progress messages
+	LatencyInterval   time.Duration `yaml:"latencyInterval" toml:"latencyInterval"`   // Latency probes for clients that ask; 0 sends none
+	HTTPMaxBytes      int64         `yaml:"httpMaxBytes" toml:"httpMaxBytes"`         // Largest GET /api/download
+}
+
+type UploadTestConfig struct {
+	Duration          time.Duration `yaml:"duration" toml:"duration"`
+	InitialChunkSize  int           `yaml:"initialChunkSize" toml:"initialChunkSize"`
+	MinChunkSize      int           `yaml:"minChunkSize" toml:"minChunkSize"`
+	MaxChunkSize      int           `yaml:"maxChunkSize" toml:"maxChunkSize"`
+	MaxThroughputMbps float64       `yaml:"maxThroughputMbps" toml:"maxThroughputMbps"`
+	ProgressInterval  time.Duration `yaml:"progressInterval" toml:"progressInterval"` // 0 sends no progress messages
+	LatencyInterval   time.Duration `yaml:"latencyInterval" toml:"latencyInterval"`   // Latency probes for clients that ask; 0 sends none
+	HTTPMaxBytes      int64         `yaml:"httpMaxBytes" toml:"httpMaxBytes"`         // Largest POST /api/upload body
+}
+
+// Default returns the built-in configuration used when nothing else is set
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Port:          "3001", // Default to 3001 to avoid conflicts with web servers on 8080
+			EnableLogging: true,
+			EnableMetrics: true,
+			LogLevel:      "info",
+			// A reverse proxy on the same host, as in the bundled nginx config
+			TrustedProxies: []string{"127.0.0.0/8", "::1"},
+		},
+		TLS: TLSConfig{
+			Port:           "3443",
+			MinVersion:     "1.2",
+			ReloadInterval: 30 * time.Second,
+		},
+		CORS: CORSConfig{
+			// Production domain and localhost for development, plus common
+			// local network ranges for local testing
+			AllowedOrigins: []string{
+				"https://hashmatrix.dev",
+				"https://www.hashmatrix.dev",
+				"http://localhost:3000",
+				"http://localhost:5173",
+				"http://192.168.0.0/16",
+				"http://10.0.0.0/8",
+				"http://172.16.0.0/12",
+			},
+		},
+		Limits: LimitsConfig{
+			MaxConnections: 1000,
+		},
+		GeoIP: GeoIPConfig{
+			CityPath: "/usr/share/GeoIP/GeoLite2-City.mmdb",
+			ASNPath:  "/usr/share/GeoIP/GeoLite2-ASN.mmdb",
+			ISPPath:  "/usr/share/GeoIP/GeoLite2-ISP.mmdb",
+		},
+		Tests: TestsConfig{
+			Ping: PingTestConfig{
+				Count:    20,
+				Interval: 50 * time.Millisecond,
+				Timeout:  5 * time.Second,
+			},
+			UDP: UDPTestConfig{
+				Count:   200,
+				Rate:    50,
+				Timeout: time.Second,
+			},
+			Download: DownloadTestConfig{
+				Duration:          10 * time.Second,
+				InitialChunkSize:  256 * 1024,
+				MinChunkSize:      64 * 1024,
+				MaxChunkSize:      10 * 1024 * 1024,
+				MaxStreams:        8,
+				MaxThroughputMbps: 10000,
+				ProgressInterval:  250 * time.Millisecond,
+				LatencyInterval:   200 * time.Millisecond,
+				HTTPMaxBytes:      1 << 30,
+			},
+			Upload: UploadTestConfig{
+				Duration:          10 * time.Second,
+				InitialChunkSize:  256 * 1024,
+				MinChunkSize:      64 * 1024,
+				MaxChunkSize:      10 * 1024 * 1024,
+				MaxThroughputMbps: 10000,
+				ProgressInterval:  250 * time.Millisecond,
+				LatencyInterval:   200 * time.Millisecond,
+				HTTPMaxBytes:      1 << 30,
+			},
+		},
+		Sessions: SessionsConfig{
+			TTL:         time.Hour,
+			MaxSessions: 10000,
+		},
+		Scoring: scoring.Default(),
+	}
+}
+
+// Load builds the configuration from defaults, the config file at path (or
+// $NOVA_CONFIG when path is empty) and environment overrides, in that order.
+// A file that cannot be decoded yields a *ParseError; otherwise every bad
+// value is collected into a single *ValidationError.
+func Load(path string) (*Config, error) {
+	cfg := Default()
+	v := &ValidationError{}
+
+	if path == "" {
+		path = os.Getenv(ConfigPathEnv)
+	}
+	if path != "" {
+		if err := cfg.loadFile(path, v); err != nil {
+			return nil, err
+		}
+	}
+
+	cfg.applyEnv(v)
+	cfg.validateInto(v)
+
+	if err := v.err(); err != nil {
+		return nil, err
+	}
+
+	// Both lists were validated above, so these cannot fail
+	cfg.originMatcher, _ = origins.Compile(cfg.CORS.AllowedOrigins)
+	cfg.ipResolver, _ = clientip.NewResolver(cfg.Server.TrustedProxies)
+	return cfg, nil
+}
+
+// loadFile merges a YAML or TOML file over the current values. Keys missing
+// from the file keep whatever was set before; unknown keys are rejected.
+func (c *Config) loadFile(path string, v *ValidationError) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return &ParseError{Path: path, Err: err}
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(c); err != nil && err != io.EOF {
+			return &ParseError{Path: path, Err: err}
+		}
+	case ".toml":
+		md, err := toml.Decode(string(data), c)
+		if err != nil {
+			return &ParseError{Path: path, Err: err}
+		}
+		for _, key := range md.Undecoded() {
+			v.add(key.String(), path, "unknown key")
+		}
+	default:
+		return &ParseError{Path: path, Err: fmt.Errorf("unsupported extension %q (use .yaml, .yml or .toml)", filepath.Ext(path))}
+	}
+
+	return nil
+}
+
+// applyEnv overrides values with the environment variables the server has
+// always honoured, so existing deployments keep working unchanged. Values
+// that fail to parse are recorded under the variable name.
+func (c *Config) applyEnv(v *ValidationError) {
+	if port := os.Getenv("PORT"); port != "" {
+		c.Server.Port = port
+	}
+	if addrs := os.Getenv("LISTEN"); addrs != "" {
+		c.Server.Listen = splitList(addrs)
+	}
+
+	// TLS
+	if p := os.Getenv("TLS_CERT_FILE"); p != "" {
+		c.TLS.CertFile = p
+	}
+	if p := os.Getenv("TLS_KEY_FILE"); p != "" {
+		c.TLS.KeyFile = p
+	}
+	if port := os.Getenv("TLS_PORT"); port != "" {
+		c.TLS.Port = port
+	}
+	if addrs := os.Getenv("TLS_LISTEN"); addrs != "" {
+		c.TLS.Listen = splitList(addrs)
+	}
+	envBool(v, "TLS_REDIRECT_HTTP", &c.TLS.RedirectHTTP)
+
+	if addr, ok := os.LookupEnv("QUIC_LISTEN"); ok {
+		c.QUIC.Listen = addr // Empty disables QUIC
+	}
+	if addr, ok := os.LookupEnv("TCP_LISTEN"); ok {
+		c.TCP.Listen = addr // Empty disables raw TCP tests
+	}
+	if addr, ok := os.LookupEnv("UDP_LISTEN"); ok {
+		c.UDP.Listen = addr // Empty disables the UDP test
+	}
+
+	if origins := os.Getenv("ALLOWED_ORIGINS"); origins != "" {
+		c.CORS.AllowedOrigins = splitList(origins)
 	}
 
-	maxConnections := 1000
 	if maxConns := os.Getenv("MAX_CONNECTIONS"); maxConns != "" {
-		// Parse maxConnections from env if needed
-		_ = maxConns
+		if n, err := strconv.Atoi(maxConns); err != nil {
+			v.add("MAX_CONNECTIONS", maxConns, "must be an integer")
+		} else {
+			c.Limits.MaxConnections = n
+		}
+	}
+
+	envBool(v, "ENABLE_LOGGING", &c.Server.EnableLogging)
+	envBool(v, "ENABLE_METRICS", &c.Server.EnableMetrics)
+
+	if level := os.Getenv("LOG_LEVEL"); level != "" {
+		c.Server.LogLevel = level
+	}
+	if token := os.Getenv("ADMIN_TOKEN"); token != "" {
+		c.Server.AdminToken = token
+	}
+	if proxies, ok := os.LookupEnv("TRUSTED_PROXIES"); ok {
+		c.Server.TrustedProxies = splitList(proxies) // Empty trusts no proxy
 	}
 
-	enableLogging := os.Getenv("ENABLE_LOGGING") != "false"
-	enableMetrics := os.Getenv("ENABLE_METRICS") != "false"
+	// Result store (off unless a path is set)
+	if p, ok := os.LookupEnv("STORAGE_PATH"); ok {
+		c.Storage.Path = p // Empty disables storage
+	}
 
 	// GeoIP database paths
-	geoIPCityPath := os.Getenv("GEOIP_CITY_PATH")
-	if geoIPCityPath == "" {
-		geoIPCityPath = "/usr/share/GeoIP/GeoLite2-City.mmdb"
+	if p := os.Getenv("GEOIP_CITY_PATH"); p != "" {
+		c.GeoIP.CityPath = p
+	}
+	if p := os.Getenv("GEOIP_ASN_PATH"); p != "" {
+		c.GeoIP.ASNPath = p
 	}
+	if p := os.Getenv("GEOIP_ISP_PATH"); p != "" {
+		c.GeoIP.ISPPath = p
+	}
+}
 
-	geoIPASNPath := os.Getenv("GEOIP_ASN_PATH")
-	if geoIPASNPath == "" {
-		geoIPASNPath = "/usr/share/GeoIP/GeoLite2-ASN.mmdb"
+// ListenAddrs returns the plain HTTP listen addresses
+func (c *Config) ListenAddrs() []string {
+	if len(c.Server.Listen) > 0 {
+		return c.Server.Listen
 	}
+	return []string{":" + c.Server.Port}
+}
 
-	geoIPISPPath := os.Getenv("GEOIP_ISP_PATH")
-	if geoIPISPPath == "" {
-		geoIPISPPath = "/usr/share/GeoIP/GeoLite2-ISP.mmdb"
+// IsOriginAllowed reports whether a browser Origin header matches the allow
+// list, honouring wildcard subdomains, CIDR ranges and ports
+func (c *Config) IsOriginAllowed(origin string) bool {
+	m := c.originMatcher
+	if m == nil {
+		// Config built without Load (e.g. Default()); an invalid list allows nothing
+		m, _ = origins.Compile(c.CORS.AllowedOrigins)
 	}
+	return m.Allowed(origin)
+}
 
-	return &Config{
-		Port:           port,
-		AllowedOrigins: allowedOrigins,
-		MaxConnections: maxConnections,
-		EnableLogging:  enableLogging,
-		EnableMetrics:  enableMetrics,
-		GeoIPCityPath:  geoIPCityPath,
-		GeoIPASNPath:   geoIPASNPath,
-		GeoIPISPPath:   geoIPISPPath,
+// ClientIPResolver returns the resolver built from the trusted proxy list
+func (c *Config) ClientIPResolver() *clientip.Resolver {
+	if c.ipResolver == nil {
+		// Config built without Load; an invalid list trusts no proxy
+		r, err := clientip.NewResolver(c.Server.TrustedProxies)
+		if err != nil {
+			r = &clientip.Resolver{}
+		}
+		return r
 	}
+	return c.ipResolver
 }
 
-func (c *Config) GetAllowedOriginsList() []string {
-	if c.AllowedOrigins == "*" {
-		return []string{"*"}
+func envBool(v *ValidationError, name string, dst *bool) {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return
+	}
+	b, err := strconv.ParseBool(raw)
+	if err != nil {
+		v.add(name, raw, "must be true or false")
+		return
 	}
-	return strings.Split(c.AllowedOrigins, ",")
+	*dst = b
 }
 
+func splitList(s string) []string {
+	var out []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
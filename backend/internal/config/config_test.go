@@ -0,0 +1,204 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// envVars lists every variable Load reads
+var envVars = []string{
+	ConfigPathEnv, "PORT", "LISTEN", "TLS_CERT_FILE", "TLS_KEY_FILE", "TLS_PORT",
+	"TLS_LISTEN", "TLS_REDIRECT_HTTP", "QUIC_LISTEN", "TCP_LISTEN", "UDP_LISTEN",
+	"ALLOWED_ORIGINS", "MAX_CONNECTIONS", "ENABLE_LOGGING", "ENABLE_METRICS",
+	"LOG_LEVEL", "ADMIN_TOKEN", "TRUSTED_PROXIES", "STORAGE_PATH",
+	"GEOIP_CITY_PATH", "GEOIP_ASN_PATH", "GEOIP_ISP_PATH",
+}
+
+// clearEnv unsets every variable Load reads for the rest of the test
+func clearEnv(t *testing.T) {
+	t.Helper()
+	for _, name := range envVars {
+		t.Setenv(name, "") // Restores the old value afterwards
+		os.Unsetenv(name)
+	}
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadDefaults(t *testing.T) {
+	clearEnv(t)
+
+	cfg, err := Load("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Default()
+	if !reflect.DeepEqual(cfg.Server, want.Server) || !reflect.DeepEqual(cfg.Tests, want.Tests) || !reflect.DeepEqual(cfg.Scoring, want.Scoring) {
+		t.Errorf("Load(\"\") differs from Default()")
+	}
+	if cfg.Storage.Path != "" {
+		t.Errorf("storage.path = %q, want it off by default", cfg.Storage.Path)
+	}
+}
+
+func TestLoadLayers(t *testing.T) {
+	yamlFile := `
+server:
+  port: "4000"
+  logLevel: debug
+limits:
+  maxConnections: 50
+tests:
+  ping:
+    count: 10
+    interval: 100ms
+`
+	tomlFile := `
+[server]
+port = "4000"
+logLevel = "debug"
+
+[limits]
+maxConnections = 50
+
+[tests.ping]
+count = 10
+interval = "100ms"
+`
+
+	tests := []struct {
+		name string
+		file string
+		body string
+		env  map[string]string
+		port string
+		log  string
+		max  int
+	}{
+		{name: "yaml", file: "nova.yaml", body: yamlFile, port: "4000", log: "debug", max: 50},
+		{name: "yml", file: "nova.yml", body: yamlFile, port: "4000", log: "debug", max: 50},
+		{name: "toml", file: "nova.toml", body: tomlFile, port: "4000", log: "debug", max: 50},
+		{
+			name: "env over file",
+			file: "nova.yaml",
+			body: yamlFile,
+			env:  map[string]string{"PORT": "5000", "MAX_CONNECTIONS": "7"},
+			port: "5000",
+			log:  "debug",
+			max:  7,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			cfg, err := Load(writeFile(t, tt.file, tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Server.Port != tt.port {
+				t.Errorf("server.port = %q, want %q", cfg.Server.Port, tt.port)
+			}
+			if cfg.Server.LogLevel != tt.log {
+				t.Errorf("server.logLevel = %q, want %q", cfg.Server.LogLevel, tt.log)
+			}
+			if cfg.Limits.MaxConnections != tt.max {
+				t.Errorf("limits.maxConnections = %d, want %d", cfg.Limits.MaxConnections, tt.max)
+			}
+
+			// Set in the file, so the file wins over the default
+			if cfg.Tests.Ping.Count != 10 || cfg.Tests.Ping.Interval != 100*time.Millisecond {
+				t.Errorf("tests.ping = %+v, want count 10 every 100ms", cfg.Tests.Ping)
+			}
+			// Missing from the file, so the default stays
+			if cfg.Tests.Ping.Timeout != Default().Tests.Ping.Timeout {
+				t.Errorf("tests.ping.timeout = %v, want the default", cfg.Tests.Ping.Timeout)
+			}
+		})
+	}
+}
+
+func TestLoadPathFromEnv(t *testing.T) {
+	clearEnv(t)
+	t.Setenv(ConfigPathEnv, writeFile(t, "nova.yaml", "server:\n  port: \"4100\"\n"))
+
+	cfg, err := Load("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Server.Port != "4100" {
+		t.Errorf("server.port = %q, want 4100", cfg.Server.Port)
+	}
+}
+
+func TestLoadEnvLists(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("ALLOWED_ORIGINS", "https://a.example, ,https://b.example")
+	t.Setenv("TRUSTED_PROXIES", "")
+	t.Setenv("ENABLE_METRICS", "false")
+
+	cfg, err := Load("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"https://a.example", "https://b.example"}; !reflect.DeepEqual(cfg.CORS.AllowedOrigins, want) {
+		t.Errorf("cors.allowedOrigins = %q, want %q", cfg.CORS.AllowedOrigins, want)
+	}
+	if len(cfg.Server.TrustedProxies) != 0 {
+		t.Errorf("server.trustedProxies = %q, want none", cfg.Server.TrustedProxies)
+	}
+	if cfg.Server.EnableMetrics {
+		t.Error("server.enableMetrics = true, want false")
+	}
+	if !cfg.IsOriginAllowed("https://b.example") || cfg.IsOriginAllowed("https://hashmatrix.dev") {
+		t.Error("origin matcher doesn't follow ALLOWED_ORIGINS")
+	}
+}
+
+func TestLoadParseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		body string
+	}{
+		{name: "unknown yaml key", file: "nova.yaml", body: "server:\n  prot: \"4000\"\n"},
+		{name: "bad yaml", file: "nova.yaml", body: "server: [\n"},
+		{name: "bad toml", file: "nova.toml", body: "[server\n"},
+		{name: "unsupported extension", file: "nova.json", body: "{}"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			_, err := Load(writeFile(t, tt.file, tt.body))
+			var pe *ParseError
+			if !errors.As(err, &pe) {
+				t.Fatalf("Load() error = %v, want a *ParseError", err)
+			}
+		})
+	}
+
+	t.Run("missing file", func(t *testing.T) {
+		clearEnv(t)
+		_, err := Load(filepath.Join(t.TempDir(), "missing.yaml"))
+		var pe *ParseError
+		if !errors.As(err, &pe) || !errors.Is(err, os.ErrNotExist) {
+			t.Fatalf("Load() error = %v, want a *ParseError wrapping os.ErrNotExist", err)
+		}
+	})
+}
@@ -0,0 +1,91 @@
+package config
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Store holds the live configuration and swaps it atomically on reload.
+// Readers should call Get once per request or test and keep that snapshot,
+// so a reload never changes parameters under a test that is already running.
+type Store struct {
+	path      string
+	current   atomic.Pointer[Config]
+	mu        sync.Mutex // Serializes reloads and listener registration
+	listeners []func(old, updated *Config)
+}
+
+func NewStore(path string, cfg *Config) *Store {
+	s := &Store{path: path}
+	s.current.Store(cfg)
+	return s
+}
+
+// Get returns the current configuration snapshot
+func (s *Store) Get() *Config {
+	return s.current.Load()
+}
+
+// OnReload registers fn to be called after every successful reload
+func (s *Store) OnReload(fn func(old, updated *Config)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listeners = append(s.listeners, fn)
+}
+
+// Reload re-reads the configuration from the same sources used at startup.
+// On error the current configuration is kept. The returned list names
+// changed settings that only take effect after a restart.
+func (s *Store) Reload() (restartRequired []string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	updated, err := Load(s.path)
+	if err != nil {
+		return nil, err
+	}
+
+	old := s.current.Swap(updated)
+	if !equalStrings(old.ListenAddrs(), updated.ListenAddrs()) {
+		restartRequired = append(restartRequired, "server.listen")
+	}
+	if old.TLS.Enabled() != updated.TLS.Enabled() || !equalStrings(old.TLS.ListenAddrs(), updated.TLS.ListenAddrs()) {
+		restartRequired = append(restartRequired, "tls.listen")
+	}
+	if old.TLS.RedirectHTTP != updated.TLS.RedirectHTTP {
+		restartRequired = append(restartRequired, "tls.redirectHTTP")
+	}
+	if old.TLS.MinVersion != updated.TLS.MinVersion || !equalStrings(old.TLS.CipherSuites, updated.TLS.CipherSuites) ||
+		old.TLS.ReloadInterval != updated.TLS.ReloadInterval {
+		restartRequired = append(restartRequired, "tls.minVersion/cipherSuites/reloadInterval")
+	}
+	if old.QUIC.Listen != updated.QUIC.Listen {
+		restartRequired = append(restartRequired, "quic.listen")
+	}
+	if old.TCP.Listen != updated.TCP.Listen {
+		restartRequired = append(restartRequired, "tcp.listen")
+	}
+	if old.UDP.Listen != updated.UDP.Listen {
+		restartRequired = append(restartRequired, "udp.listen")
+	}
+	if old.Storage.Path != updated.Storage.Path {
+		restartRequired = append(restartRequired, "storage.path")
+	}
+
+	for _, fn := range s.listeners {
+		fn(old, updated)
+	}
+	return restartRequired, nil
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,170 @@
+package config
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"nova-speed/backend/internal/tlsutil"
+
+	"gopkg.in/yaml.v3"
+)
+
+// writeConfig saves cfg as a YAML config file at path
+func writeConfig(t *testing.T, path string, cfg *Config) {
+	t.Helper()
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// writeKeyPair saves a self-signed certificate and its key as PEM files in dir
+func writeKeyPair(t *testing.T, dir string) (certFile, keyFile string) {
+	t.Helper()
+	cert, err := tlsutil.SelfSigned("localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := x509.MarshalPKCS8PrivateKey(cert.PrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile = filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: key})
+	if err := os.WriteFile(certFile, certPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, keyPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func TestStoreReload(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeKeyPair(t, dir)
+	base := func() *Config {
+		cfg := Default()
+		cfg.TLS.CertFile, cfg.TLS.KeyFile = certFile, keyFile
+		cfg.GeoIP = GeoIPConfig{}
+		return cfg
+	}
+
+	tests := []struct {
+		name    string
+		edit    func(c *Config)
+		restart []string
+	}{
+		{name: "unchanged", edit: func(c *Config) {}},
+
+		// Applied live
+		{name: "origins", edit: func(c *Config) { c.CORS.AllowedOrigins = []string{"https://example.com"} }},
+		{name: "limits", edit: func(c *Config) { c.Limits.MaxConnections = 10 }},
+		{name: "log level", edit: func(c *Config) { c.Server.LogLevel = "debug" }},
+		{name: "geoip", edit: func(c *Config) { c.GeoIP.CityPath = certFile }},
+		{name: "test settings", edit: func(c *Config) { c.Tests.Ping.Count = 5 }},
+
+		// Only read at startup
+		{name: "server port", edit: func(c *Config) { c.Server.Port = "4001" }, restart: []string{"server.listen"}},
+		{name: "server listen", edit: func(c *Config) { c.Server.Listen = []string{"127.0.0.1:3001"} }, restart: []string{"server.listen"}},
+		{name: "tls listen", edit: func(c *Config) { c.TLS.Listen = []string{":4443"} }, restart: []string{"tls.listen"}},
+		{name: "tls disabled", edit: func(c *Config) { c.TLS.CertFile, c.TLS.KeyFile = "", "" }, restart: []string{"tls.listen"}},
+		{name: "tls redirect", edit: func(c *Config) { c.TLS.RedirectHTTP = true }, restart: []string{"tls.redirectHTTP"}},
+		{name: "tls policy", edit: func(c *Config) { c.TLS.MinVersion = "1.3" }, restart: []string{"tls.minVersion/cipherSuites/reloadInterval"}},
+		{name: "quic listen", edit: func(c *Config) { c.QUIC.Listen = ":3444" }, restart: []string{"quic.listen"}},
+		{name: "tcp listen", edit: func(c *Config) { c.TCP.Listen = ":5201" }, restart: []string{"tcp.listen"}},
+		{name: "udp listen", edit: func(c *Config) { c.UDP.Listen = ":3478" }, restart: []string{"udp.listen"}},
+		{name: "storage path", edit: func(c *Config) { c.Storage.Path = filepath.Join(dir, "nova.db") }, restart: []string{"storage.path"}},
+		{
+			name: "live and restart-only",
+			edit: func(c *Config) {
+				c.Server.Port = "4001"
+				c.Limits.MaxConnections = 10
+				c.UDP.Listen = ":3478"
+			},
+			restart: []string{"server.listen", "udp.listen"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			path := filepath.Join(t.TempDir(), "nova.yaml")
+			writeConfig(t, path, base())
+			initial, err := Load(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			store := NewStore(path, initial)
+			var calls int
+			store.OnReload(func(old, updated *Config) {
+				calls++
+				if old != initial || updated != store.Get() {
+					t.Error("OnReload listener got the wrong configs")
+				}
+			})
+
+			want := base()
+			tt.edit(want)
+			writeConfig(t, path, want)
+			restart, err := store.Reload()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if fmt.Sprint(restart) != fmt.Sprint(tt.restart) {
+				t.Errorf("Reload() restart required = %q, want %q", restart, tt.restart)
+			}
+			if calls != 1 {
+				t.Errorf("OnReload listener ran %d times, want 1", calls)
+			}
+			got := store.Get()
+			if got == initial {
+				t.Fatal("Get() still returns the initial config")
+			}
+			if fmt.Sprint(got.CORS, got.Limits, got.Server, got.TLS, got.Storage, got.GeoIP, got.Tests.Ping) !=
+				fmt.Sprint(want.CORS, want.Limits, want.Server, want.TLS, want.Storage, want.GeoIP, want.Tests.Ping) {
+				t.Errorf("Get() = %+v, want the edited config %+v", got, want)
+			}
+		})
+	}
+
+	t.Run("invalid", func(t *testing.T) {
+		clearEnv(t)
+		path := filepath.Join(t.TempDir(), "nova.yaml")
+		writeConfig(t, path, base())
+		initial, err := Load(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		store := NewStore(path, initial)
+		store.OnReload(func(old, updated *Config) {
+			t.Error("OnReload listener ran after a failed reload")
+		})
+
+		bad := base()
+		bad.Limits.MaxConnections = 0
+		bad.Server.Port = "4001"
+		writeConfig(t, path, bad)
+		restart, err := store.Reload()
+		var ve *ValidationError
+		if !errors.As(err, &ve) {
+			t.Fatalf("Reload() error = %v, want a *ValidationError", err)
+		}
+		if restart != nil {
+			t.Errorf("Reload() restart required = %q, want none", restart)
+		}
+		if store.Get() != initial {
+			t.Error("Get() no longer returns the previous config")
+		}
+	})
+}
@@ -0,0 +1,389 @@
+package config
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	"nova-speed/backend/internal/clientip"
+	"nova-speed/backend/internal/listen"
+	"nova-speed/backend/internal/origins"
+	"nova-speed/backend/internal/scoring"
+	"nova-speed/backend/internal/tlsutil"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// FieldError describes a single invalid configuration value
+type FieldError struct {
+	Field  string      `json:"field"` // Dotted config key, or the environment variable it came from
+	Value  interface{} `json:"value"`
+	Reason string      `json:"reason"`
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("%s: %s (got %v)", e.Field, e.Reason, e.Value)
+}
+
+// ValidationError aggregates every FieldError found while loading a config
+type ValidationError struct {
+	Errors []*FieldError
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, fe := range e.Errors {
+		msgs[i] = fe.Error()
+	}
+	return fmt.Sprintf("invalid configuration (%d errors):\n  %s", len(e.Errors), strings.Join(msgs, "\n  "))
+}
+
+// Unwrap exposes the individual field errors to errors.Is / errors.As
+func (e *ValidationError) Unwrap() []error {
+	errs := make([]error, len(e.Errors))
+	for i, fe := range e.Errors {
+		errs[i] = fe
+	}
+	return errs
+}
+
+func (e *ValidationError) add(field string, value interface{}, reason string) {
+	e.Errors = append(e.Errors, &FieldError{Field: field, Value: value, Reason: reason})
+}
+
+// err returns nil when nothing was collected so callers can return it directly
+func (e *ValidationError) err() error {
+	if len(e.Errors) == 0 {
+		return nil
+	}
+	return e
+}
+
+// ParseError reports a config file that could not be read or decoded
+type ParseError struct {
+	Path string
+	Err  error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("config file %s: %v", e.Path, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// Validate checks every field and returns a *ValidationError listing all
+// problems, or nil if the configuration is usable.
+func (c *Config) Validate() error {
+	v := &ValidationError{}
+	c.validateInto(v)
+	return v.err()
+}
+
+func (c *Config) validateInto(v *ValidationError) {
+	// Server
+	// server.port is only used when no explicit listen addresses are given
+	portValid := validPort(c.Server.Port)
+	if len(c.Server.Listen) == 0 && !portValid {
+		v.add("server.port", c.Server.Port, "must be a number between 1 and 65535")
+	}
+	if _, err := zapcore.ParseLevel(c.Server.LogLevel); err != nil {
+		v.add("server.logLevel", c.Server.LogLevel, "must be one of debug, info, warn, error")
+	}
+	for i, proxy := range c.Server.TrustedProxies {
+		if _, err := clientip.ParseNetwork(proxy); err != nil {
+			v.add(fmt.Sprintf("server.trustedProxies[%d]", i), proxy, "must be an IP address or CIDR range")
+		}
+	}
+
+	// TLS
+	if c.TLS.Enabled() {
+		c.validateTLS(v)
+	}
+
+	// Listeners: every address must parse and none may be bound twice
+	seen := make(map[string]string)
+	checkListen := func(field string, addrs []string) {
+		for i, raw := range addrs {
+			name := fmt.Sprintf("%s[%d]", field, i)
+			addr, err := listen.Parse(raw)
+			if err != nil {
+				v.add(name, raw, err.Error())
+				continue
+			}
+			if other, dup := seen[addr.String()]; dup {
+				v.add(name, raw, "already used by "+other)
+				continue
+			}
+			seen[addr.String()] = name
+		}
+	}
+	if len(c.Server.Listen) > 0 || portValid {
+		checkListen("server.listen", c.ListenAddrs())
+	}
+	if c.TLS.Enabled() && (len(c.TLS.Listen) > 0 || validPort(c.TLS.Port)) {
+		checkListen("tls.listen", c.TLS.ListenAddrs())
+	}
+	if c.TCP.Listen != "" {
+		checkListen("tcp.listen", []string{c.TCP.Listen})
+	}
+
+	// QUIC listens on UDP, so it may share a port number with a TCP listener
+	if c.QUIC.Listen != "" {
+		if _, err := net.ResolveUDPAddr("udp", c.QUIC.Listen); err != nil {
+			v.add("quic.listen", c.QUIC.Listen, "must be a UDP host:port address")
+		}
+	}
+	if c.UDP.Listen != "" {
+		if _, err := net.ResolveUDPAddr("udp", c.UDP.Listen); err != nil {
+			v.add("udp.listen", c.UDP.Listen, "must be a UDP host:port address")
+		} else if c.UDP.Listen == c.QUIC.Listen {
+			v.add("udp.listen", c.UDP.Listen, "already used by quic.listen")
+		}
+	}
+
+	// CORS
+	if len(c.CORS.AllowedOrigins) == 0 {
+		v.add("cors.allowedOrigins", c.CORS.AllowedOrigins, "must list at least one origin")
+	}
+	for i, origin := range c.CORS.AllowedOrigins {
+		if err := validateOrigin(origin, len(c.CORS.AllowedOrigins)); err != nil {
+			v.add(fmt.Sprintf("cors.allowedOrigins[%d]", i), origin, err.Error())
+		}
+	}
+
+	// Limits
+	if c.Limits.MaxConnections <= 0 {
+		v.add("limits.maxConnections", c.Limits.MaxConnections, "must be positive")
+	}
+
+	// GeoIP: an empty path disables the database. The built-in default paths
+	// may be absent (geolocation degrades gracefully), but any path set
+	// explicitly has to point at a readable file.
+	defaults := Default().GeoIP
+	validateGeoIPPath(v, "geoip.cityPath", c.GeoIP.CityPath, defaults.CityPath)
+	validateGeoIPPath(v, "geoip.asnPath", c.GeoIP.ASNPath, defaults.ASNPath)
+	validateGeoIPPath(v, "geoip.ispPath", c.GeoIP.ISPPath, defaults.ISPPath)
+
+	// Tests
+	ping := c.Tests.Ping
+	if ping.Count <= 0 {
+		v.add("tests.ping.count", ping.Count, "must be positive")
+	}
+	if ping.Interval < 0 {
+		v.add("tests.ping.interval", ping.Interval, "must not be negative")
+	}
+	if ping.Timeout <= 0 {
+		v.add("tests.ping.timeout", ping.Timeout, "must be positive")
+	}
+
+	udp := c.Tests.UDP
+	if udp.Count <= 0 {
+		v.add("tests.udp.count", udp.Count, "must be positive")
+	}
+	if udp.Rate <= 0 {
+		v.add("tests.udp.rate", udp.Rate, "must be positive")
+	}
+	if udp.Timeout <= 0 {
+		v.add("tests.udp.timeout", udp.Timeout, "must be positive")
+	}
+
+	dl := c.Tests.Download
+	if dl.Duration <= 0 {
+		v.add("tests.download.duration", dl.Duration, "must be positive")
+	}
+	validateChunkSizes(v, "tests.download", dl.MinChunkSize, dl.InitialChunkSize, dl.MaxChunkSize)
+	if dl.MaxStreams <= 0 {
+		v.add("tests.download.maxStreams", dl.MaxStreams, "must be positive")
+	}
+	if dl.MaxThroughputMbps <= 0 {
+		v.add("tests.download.maxThroughputMbps", dl.MaxThroughputMbps, "must be positive")
+	}
+	if dl.ProgressInterval < 0 {
+		v.add("tests.download.progressInterval", dl.ProgressInterval, "must not be negative")
+	}
+	if dl.LatencyInterval < 0 {
+		v.add("tests.download.latencyInterval", dl.LatencyInterval, "must not be negative")
+	}
+	if dl.HTTPMaxBytes <= 0 {
+		v.add("tests.download.httpMaxBytes", dl.HTTPMaxBytes, "must be positive")
+	}
+
+	ul := c.Tests.Upload
+	if ul.Duration <= 0 {
+		v.add("tests.upload.duration", ul.Duration, "must be positive")
+	}
+	validateChunkSizes(v, "tests.upload", ul.MinChunkSize, ul.InitialChunkSize, ul.MaxChunkSize)
+	if ul.MaxThroughputMbps <= 0 {
+		v.add("tests.upload.maxThroughputMbps", ul.MaxThroughputMbps, "must be positive")
+	}
+	if ul.ProgressInterval < 0 {
+		v.add("tests.upload.progressInterval", ul.ProgressInterval, "must not be negative")
+	}
+	if ul.LatencyInterval < 0 {
+		v.add("tests.upload.latencyInterval", ul.LatencyInterval, "must not be negative")
+	}
+	if ul.HTTPMaxBytes <= 0 {
+		v.add("tests.upload.httpMaxBytes", ul.HTTPMaxBytes, "must be positive")
+	}
+
+	// Sessions
+	if c.Sessions.TTL <= 0 {
+		v.add("sessions.ttl", c.Sessions.TTL, "must be positive")
+	}
+	if c.Sessions.MaxSessions <= 0 {
+		v.add("sessions.maxSessions", c.Sessions.MaxSessions, "must be positive")
+	}
+
+	c.validateScoring(v)
+}
+
+func (c *Config) validateScoring(v *ValidationError) {
+	s := c.Scoring
+	if len(s.Streaming) == 0 {
+		v.add("scoring.streaming", s.Streaming, "must list at least one quality")
+	}
+	for i, tier := range s.Streaming {
+		field := fmt.Sprintf("scoring.streaming[%d]", i)
+		if tier.Quality == "" {
+			v.add(field+".quality", tier.Quality, "must not be empty")
+		}
+		if tier.MinDownloadMbps < 0 {
+			v.add(field+".minDownloadMbps", tier.MinDownloadMbps, "must not be negative")
+		}
+		if tier.MaxLatencyMs < 0 {
+			v.add(field+".maxLatencyMs", tier.MaxLatencyMs, "must not be negative")
+		}
+		if i > 0 && tier.MinDownloadMbps > s.Streaming[i-1].MinDownloadMbps {
+			v.add(field+".minDownloadMbps", tier.MinDownloadMbps, "must not exceed the previous quality's; list the best quality first")
+		}
+	}
+
+	validateLimits(v, "scoring.gaming.latencyMs", s.Gaming.LatencyMs)
+	validateLimits(v, "scoring.gaming.jitterMs", s.Gaming.JitterMs)
+	validateLimits(v, "scoring.gaming.packetLoss", s.Gaming.PacketLoss)
+	if s.Gaming.MinDownloadMbps < 0 {
+		v.add("scoring.gaming.minDownloadMbps", s.Gaming.MinDownloadMbps, "must not be negative")
+	}
+
+	vc := s.VideoCall
+	if vc.MinUploadMbps <= 0 {
+		v.add("scoring.videoCall.minUploadMbps", vc.MinUploadMbps, "must be positive")
+	}
+	if vc.LowUploadMbps < 0 || vc.LowUploadMbps > vc.MinUploadMbps {
+		v.add("scoring.videoCall.lowUploadMbps", vc.LowUploadMbps, "must be between 0 and minUploadMbps")
+	}
+	if vc.ExcellentUploadMbps < vc.MinUploadMbps {
+		v.add("scoring.videoCall.excellentUploadMbps", vc.ExcellentUploadMbps, "must not be smaller than minUploadMbps")
+	}
+	if vc.MaxLatencyMs <= 0 {
+		v.add("scoring.videoCall.maxLatencyMs", vc.MaxLatencyMs, "must be positive")
+	}
+	if vc.ExcellentLatencyMs < 0 || vc.ExcellentLatencyMs > vc.MaxLatencyMs {
+		v.add("scoring.videoCall.excellentLatencyMs", vc.ExcellentLatencyMs, "must be between 0 and maxLatencyMs")
+	}
+	if vc.ZeroScoreLatencyMs <= 0 {
+		v.add("scoring.videoCall.zeroScoreLatencyMs", vc.ZeroScoreLatencyMs, "must be positive")
+	}
+	if vc.MinStability < 0 || vc.MinStability > 100 {
+		v.add("scoring.videoCall.minStability", vc.MinStability, "must be between 0 and 100")
+	}
+
+	if len(s.Bufferbloat) == 0 {
+		v.add("scoring.bufferbloat", s.Bufferbloat, "must list at least one grade")
+	}
+	for i, g := range s.Bufferbloat {
+		field := fmt.Sprintf("scoring.bufferbloat[%d]", i)
+		if g.Grade == "" {
+			v.add(field+".grade", g.Grade, "must not be empty")
+		}
+		if g.MaxIncreaseMs < 0 {
+			v.add(field+".maxIncreaseMs", g.MaxIncreaseMs, "must not be negative")
+		}
+		if i > 0 && (s.Bufferbloat[i-1].MaxIncreaseMs == 0 || g.MaxIncreaseMs != 0 && g.MaxIncreaseMs <= s.Bufferbloat[i-1].MaxIncreaseMs) {
+			v.add(field+".maxIncreaseMs", g.MaxIncreaseMs, "must exceed the previous grade's; list the best grade first")
+		}
+	}
+}
+
+// validateLimits requires 0 <= excellent <= warn <= max, with max positive
+func validateLimits(v *ValidationError, prefix string, l scoring.Limits) {
+	if l.Max <= 0 {
+		v.add(prefix+".max", l.Max, "must be positive")
+	}
+	if l.Warn < 0 || l.Warn > l.Max {
+		v.add(prefix+".warn", l.Warn, "must be between 0 and max")
+	}
+	if l.Excellent < 0 || l.Excellent > l.Warn {
+		v.add(prefix+".excellent", l.Excellent, "must be between 0 and warn")
+	}
+}
+
+func (c *Config) validateTLS(v *ValidationError) {
+	t := c.TLS
+	if t.CertFile == "" || t.KeyFile == "" {
+		v.add("tls", fmt.Sprintf("certFile=%q keyFile=%q", t.CertFile, t.KeyFile), "certFile and keyFile must be set together")
+	} else if _, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile); err != nil {
+		v.add("tls.certFile", t.CertFile, "cannot load key pair: "+err.Error())
+	}
+	if len(t.Listen) == 0 && !validPort(t.Port) {
+		v.add("tls.port", t.Port, "must be a number between 1 and 65535")
+	}
+	if _, err := tlsutil.ParseVersion(t.MinVersion); err != nil {
+		v.add("tls.minVersion", t.MinVersion, err.Error())
+	}
+	if _, err := tlsutil.ParseCipherSuites(t.CipherSuites); err != nil {
+		v.add("tls.cipherSuites", t.CipherSuites, err.Error())
+	}
+	if t.ReloadInterval <= 0 {
+		v.add("tls.reloadInterval", t.ReloadInterval, "must be positive")
+	}
+}
+
+// validateOrigin accepts "*" on its own, or any pattern understood by the
+// origins package (exact hosts, *.wildcards, CIDR ranges, ports)
+func validateOrigin(origin string, total int) error {
+	if origin == "*" && total > 1 {
+		return fmt.Errorf(`"*" cannot be combined with other origins`)
+	}
+	_, err := origins.ParsePattern(origin)
+	return err
+}
+
+func validPort(s string) bool {
+	port, err := strconv.Atoi(s)
+	return err == nil && port >= 1 && port <= 65535
+}
+
+func validateGeoIPPath(v *ValidationError, field, path, defaultPath string) {
+	if path == "" {
+		return
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) && path == defaultPath {
+			return
+		}
+		v.add(field, path, "file is not accessible: "+err.Error())
+		return
+	}
+	if info.IsDir() {
+		v.add(field, path, "must be a file, not a directory")
+	}
+}
+
+func validateChunkSizes(v *ValidationError, prefix string, min, initial, max int) {
+	if min <= 0 {
+		v.add(prefix+".minChunkSize", min, "must be positive")
+	}
+	if max < min {
+		v.add(prefix+".maxChunkSize", max, "must not be smaller than minChunkSize")
+	}
+	if initial < min || initial > max {
+		v.add(prefix+".initialChunkSize", initial, "must be between minChunkSize and maxChunkSize")
+	}
+}
@@ -0,0 +1,165 @@
+package config
+
+import (
+	"errors"
+	"sort"
+	"testing"
+)
+
+// fields returns the sorted field names of a *ValidationError
+func fields(t *testing.T, err error) []string {
+	t.Helper()
+	var ve *ValidationError
+	if !errors.As(err, &ve) {
+		t.Fatalf("error = %v, want a *ValidationError", err)
+	}
+	var names []string
+	for _, fe := range ve.Errors {
+		names = append(names, fe.Field)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestLoadValidation(t *testing.T) {
+	tests := []struct {
+		name   string
+		file   string
+		body   string
+		env    map[string]string
+		fields []string
+	}{
+		{
+			name:   "bad env values",
+			env:    map[string]string{"MAX_CONNECTIONS": "many", "ENABLE_LOGGING": "maybe"},
+			fields: []string{"ENABLE_LOGGING", "MAX_CONNECTIONS"},
+		},
+		{
+			name:   "every bad file value is collected",
+			file:   "nova.yaml",
+			body:   "server:\n  port: \"0\"\n  logLevel: loud\nlimits:\n  maxConnections: 0\n",
+			fields: []string{"limits.maxConnections", "server.logLevel", "server.port"},
+		},
+		{
+			name:   "env fixes a file value",
+			file:   "nova.yaml",
+			body:   "server:\n  port: \"0\"\n",
+			env:    map[string]string{"PORT": "4000"},
+			fields: nil,
+		},
+		{
+			name:   "unknown toml key",
+			file:   "nova.toml",
+			body:   "[server]\nprot = \"4000\"\n",
+			fields: []string{"server.prot"},
+		},
+		{
+			name:   "trusted proxies",
+			env:    map[string]string{"TRUSTED_PROXIES": "10.0.0.0/8,proxy.local"},
+			fields: []string{"server.trustedProxies[1]"},
+		},
+		{
+			name:   "origins",
+			env:    map[string]string{"ALLOWED_ORIGINS": "*,https://a.example,a.example"},
+			fields: []string{"cors.allowedOrigins[0]", "cors.allowedOrigins[2]"},
+		},
+		{
+			name:   "duplicate listen address",
+			env:    map[string]string{"LISTEN": "127.0.0.1:4000,127.0.0.1:4000", "TCP_LISTEN": "127.0.0.1:4000"},
+			fields: []string{"server.listen[1]", "tcp.listen[0]"},
+		},
+		{
+			name:   "UDP and QUIC on one port",
+			env:    map[string]string{"QUIC_LISTEN": ":4433", "UDP_LISTEN": ":4433"},
+			fields: []string{"udp.listen"},
+		},
+		{
+			name:   "explicit GeoIP path must exist",
+			env:    map[string]string{"GEOIP_CITY_PATH": "/nonexistent/city.mmdb"},
+			fields: []string{"geoip.cityPath"},
+		},
+		{
+			name:   "TLS needs both files",
+			env:    map[string]string{"TLS_CERT_FILE": "/nonexistent/cert.pem"},
+			fields: []string{"tls"},
+		},
+		{
+			name: "chunk sizes",
+			file: "nova.yaml",
+			body: "tests:\n  download:\n    minChunkSize: 1024\n    initialChunkSize: 512\n    maxChunkSize: 256\n",
+			fields: []string{
+				"tests.download.initialChunkSize",
+				"tests.download.maxChunkSize",
+			},
+		},
+		{
+			name:   "tests",
+			file:   "nova.yaml",
+			body:   "tests:\n  ping:\n    count: 0\n    interval: -1s\n  udp:\n    rate: 0\n",
+			fields: []string{"tests.ping.count", "tests.ping.interval", "tests.udp.rate"},
+		},
+		{
+			name: "scoring order",
+			file: "nova.yaml",
+			body: `
+scoring:
+  streaming:
+    - {quality: HD, minDownloadMbps: 5}
+    - {quality: 4K, minDownloadMbps: 25}
+  bufferbloat:
+    - {grade: A, maxIncreaseMs: 30}
+    - {grade: B, maxIncreaseMs: 10}
+`,
+			fields: []string{"scoring.bufferbloat[1].maxIncreaseMs", "scoring.streaming[1].minDownloadMbps"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			path := ""
+			if tt.file != "" {
+				path = writeFile(t, tt.file, tt.body)
+			}
+
+			_, err := Load(path)
+			if tt.fields == nil {
+				if err != nil {
+					t.Fatalf("Load() = %v, want no error", err)
+				}
+				return
+			}
+			got := fields(t, err)
+			if len(got) != len(tt.fields) {
+				t.Fatalf("invalid fields = %q, want %q", got, tt.fields)
+			}
+			for i := range got {
+				if got[i] != tt.fields[i] {
+					t.Fatalf("invalid fields = %q, want %q", got, tt.fields)
+				}
+			}
+		})
+	}
+}
+
+func TestValidationErrorUnwrap(t *testing.T) {
+	cfg := Default()
+	cfg.Limits.MaxConnections = -1
+	cfg.Sessions.TTL = 0
+
+	err := cfg.Validate()
+	var fe *FieldError
+	if !errors.As(err, &fe) {
+		t.Fatalf("Validate() = %v, want a *FieldError inside", err)
+	}
+	if got := fields(t, err); len(got) != 2 {
+		t.Errorf("invalid fields = %q, want limits.maxConnections and sessions.ttl", got)
+	}
+
+	if err := Default().Validate(); err != nil {
+		t.Errorf("Default().Validate() = %v", err)
+	}
+}
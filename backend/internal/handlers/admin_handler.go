@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"errors"
+	"strings"
+
+	"nova-speed/backend/internal/config"
+	"nova-speed/backend/internal/middleware"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+)
+
+type AdminHandler struct {
+	logger *zap.Logger
+	config *config.Store
+}
+
+func NewAdminHandler(logger *zap.Logger, cfg *config.Store) *AdminHandler {
+	return &AdminHandler{
+		logger: logger,
+		config: cfg,
+	}
+}
+
+// RegisterRoutes registers the admin endpoints. They are always mounted but
+// answer 404 while no admin token is configured.
+func (h *AdminHandler) RegisterRoutes(app *fiber.App) {
+	app.Post("/admin/reload", h.RequireToken, h.HandleReload)
+}
+
+// RequireToken checks the bearer token against the current admin token. It
+// also guards other operator-only endpoints such as /api/results.
+func (h *AdminHandler) RequireToken(c *fiber.Ctx) error {
+	token := h.config.Get().Server.AdminToken
+	if token == "" {
+		return fiber.ErrNotFound
+	}
+
+	provided := strings.TrimPrefix(c.Get(fiber.HeaderAuthorization), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Invalid or missing admin token",
+		})
+	}
+	return c.Next()
+}
+
+// HandleReload reloads the configuration, same as sending SIGHUP
+func (h *AdminHandler) HandleReload(c *fiber.Ctx) error {
+	restartRequired, err := h.config.Reload()
+	if err != nil {
+		h.logger.Error("Configuration reload failed", zap.Error(err), zap.String("ip", middleware.GetClientIP(c)))
+
+		response := fiber.Map{
+			"status": "failed",
+			"error":  err.Error(),
+		}
+		var validationErr *config.ValidationError
+		if errors.As(err, &validationErr) {
+			response["errors"] = validationErr.Errors
+		}
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(response)
+	}
+
+	h.logger.Info("Configuration reloaded via admin endpoint",
+		zap.String("ip", middleware.GetClientIP(c)),
+		zap.Strings("restartRequired", restartRequired),
+	)
+
+	return c.JSON(fiber.Map{
+		"status":          "reloaded",
+		"restartRequired": restartRequired,
+	})
+}
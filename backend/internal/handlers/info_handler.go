@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"nova-speed/backend/internal/config"
+	"nova-speed/backend/internal/middleware"
 	"nova-speed/backend/internal/services"
 
 	"github.com/gofiber/fiber/v2"
@@ -10,11 +11,11 @@ import (
 
 type InfoHandler struct {
 	logger          *zap.Logger
-	config          *config.Config
+	config          *config.Store
 	geolocationService *services.GeolocationService
 }
 
-func NewInfoHandler(logger *zap.Logger, cfg *config.Config, geoService *services.GeolocationService) *InfoHandler {
+func NewInfoHandler(logger *zap.Logger, cfg *config.Store, geoService *services.GeolocationService) *InfoHandler {
 	return &InfoHandler{
 		logger:          logger,
 		config:          cfg,
@@ -25,7 +26,7 @@ func NewInfoHandler(logger *zap.Logger, cfg *config.Config, geoService *services
 // HandleInfo returns client IP and geolocation information
 func (h *InfoHandler) HandleInfo(c *fiber.Ctx) error {
 	// Get client IP
-	clientIP := h.geolocationService.GetClientIP(c)
+	clientIP := middleware.GetClientIP(c)
 
 	// Get geolocation info
 	info, err := h.geolocationService.GetIPInfo(clientIP)
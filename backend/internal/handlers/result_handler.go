@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"nova-speed/backend/internal/services"
+	"nova-speed/backend/internal/storage"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+)
+
+type ResultHandler struct {
+	logger        *zap.Logger
+	resultService *services.ResultService
+}
+
+func NewResultHandler(logger *zap.Logger, resultService *services.ResultService) *ResultHandler {
+	return &ResultHandler{
+		logger:        logger,
+		resultService: resultService,
+	}
+}
+
+// RegisterRoutes registers the result history endpoints behind auth, since
+// they expose every client's IP and location
+func (h *ResultHandler) RegisterRoutes(app *fiber.App, auth fiber.Handler) {
+	app.Get("/api/results", auth, h.HandleList)
+	app.Get("/api/results/:id", auth, h.HandleGet)
+}
+
+// HandleList returns stored results, newest first. Query parameters:
+// limit, offset, from and to (Unix seconds or RFC 3339), clientIp,
+// country (ISO code), asn and phase.
+func (h *ResultHandler) HandleList(c *fiber.Ctx) error {
+	query, err := parseResultQuery(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	page, err := h.resultService.List(c.Context(), query)
+	if err != nil {
+		h.logger.Error("Failed to list results", zap.Error(err))
+		return fiber.ErrInternalServerError
+	}
+	return c.JSON(page)
+}
+
+// HandleGet returns one stored result by ID (the session ID for sessions)
+func (h *ResultHandler) HandleGet(c *fiber.Ctx) error {
+	result, err := h.resultService.Get(c.Context(), c.Params("id"))
+	if errors.Is(err, storage.ErrNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Result not found",
+		})
+	}
+	if err != nil {
+		h.logger.Error("Failed to get result", zap.Error(err))
+		return fiber.ErrInternalServerError
+	}
+	return c.JSON(result)
+}
+
+func parseResultQuery(c *fiber.Ctx) (storage.Query, error) {
+	var q storage.Query
+	var err error
+
+	if q.Limit, err = queryInt(c, "limit"); err != nil {
+		return q, err
+	}
+	if q.Offset, err = queryInt(c, "offset"); err != nil {
+		return q, err
+	}
+	if q.From, err = queryTime(c, "from"); err != nil {
+		return q, err
+	}
+	if q.To, err = queryTime(c, "to"); err != nil {
+		return q, err
+	}
+	if asn := c.Query("asn"); asn != "" {
+		n, err := strconv.ParseUint(strings.TrimPrefix(strings.ToUpper(asn), "AS"), 10, 32)
+		if err != nil {
+			return q, fmt.Errorf("invalid asn %q", asn)
+		}
+		q.ASN = uint(n)
+	}
+
+	q.ClientIP = c.Query("clientIp")
+	q.CountryCode = strings.ToUpper(c.Query("country"))
+	q.Phase = c.Query("phase")
+	if q.Phase != "" {
+		if _, err := parsePhases([]string{q.Phase}); err != nil {
+			return q, err
+		}
+	}
+	return q, nil
+}
+
+func queryInt(c *fiber.Ctx, name string) (int, error) {
+	value := c.Query(name)
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid %s %q", name, value)
+	}
+	return n, nil
+}
+
+// queryTime accepts Unix seconds or an RFC 3339 time
+func queryTime(c *fiber.Ctx, name string) (int64, error) {
+	value := c.Query(name)
+	if value == "" {
+		return 0, nil
+	}
+	if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return n, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q (use Unix seconds or RFC 3339)", name, value)
+	}
+	return t.Unix(), nil
+}
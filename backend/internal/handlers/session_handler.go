@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"errors"
+
+	"nova-speed/backend/internal/middleware"
+	"nova-speed/backend/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+)
+
+type SessionHandler struct {
+	logger         *zap.Logger
+	sessionService *services.SessionService
+}
+
+func NewSessionHandler(logger *zap.Logger, sessionService *services.SessionService) *SessionHandler {
+	return &SessionHandler{
+		logger:         logger,
+		sessionService: sessionService,
+	}
+}
+
+// RegisterRoutes registers the session endpoints
+func (h *SessionHandler) RegisterRoutes(app *fiber.App) {
+	app.Post("/api/sessions", h.HandleCreate)
+	app.Get("/api/sessions/:id", h.HandleGet)
+}
+
+// HandleCreate starts a session. The client passes its ID to each phase
+// endpoint (?session=<id>) to have the results aggregated.
+func (h *SessionHandler) HandleCreate(c *fiber.Ctx) error {
+	session, err := h.sessionService.Create(middleware.GetClientIP(c))
+	if err != nil {
+		h.logger.Error("Failed to create test session", zap.Error(err))
+		return fiber.ErrInternalServerError
+	}
+
+	h.logger.Info("Test session created",
+		zap.String("session", session.ID),
+		zap.String("ip", session.ClientIP),
+	)
+	return c.Status(fiber.StatusCreated).JSON(session)
+}
+
+// HandleGet returns a session with the results recorded so far
+func (h *SessionHandler) HandleGet(c *fiber.Ctx) error {
+	session, err := h.sessionService.Get(c.Params("id"))
+	if errors.Is(err, services.ErrSessionNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Session not found or expired",
+		})
+	}
+	if err != nil {
+		return err
+	}
+	return c.JSON(session)
+}
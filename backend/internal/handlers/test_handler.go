@@ -2,9 +2,18 @@ package handlers
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
 	"sync"
+	"time"
 
 	"nova-speed/backend/internal/config"
+	"nova-speed/backend/internal/middleware"
+	"nova-speed/backend/internal/models"
+	"nova-speed/backend/internal/quicproto"
+	"nova-speed/backend/internal/scoring"
 	"nova-speed/backend/internal/services"
 
 	"github.com/gofiber/fiber/v2"
@@ -14,15 +23,21 @@ import (
 
 type TestHandler struct {
 	logger           *zap.Logger
-	config           *config.Config
+	config           *config.Store
 	pingService      *services.PingService
 	downloadService  *services.DownloadService
 	uploadService    *services.UploadService
 	metricsService   *services.MetricsService
+	sessionService   *services.SessionService
+	resultService    *services.ResultService
+	downloadGroups   *services.DownloadGroups
+	httpTransfer     *services.HTTPTransferService
+	tcpService       *services.TCPService
+	udpService       *services.UDPService
 	activeConnections sync.Map
 }
 
-func NewTestHandler(logger *zap.Logger, cfg *config.Config) *TestHandler {
+func NewTestHandler(logger *zap.Logger, cfg *config.Store, sessionService *services.SessionService, resultService *services.ResultService) *TestHandler {
 	return &TestHandler{
 		logger:          logger,
 		config:          cfg,
@@ -30,11 +45,22 @@ func NewTestHandler(logger *zap.Logger, cfg *config.Config) *TestHandler {
 		downloadService: services.NewDownloadService(logger),
 		uploadService:   services.NewUploadService(logger),
 		metricsService:  services.NewMetricsService(logger),
+		sessionService:  sessionService,
+		resultService:   resultService,
+		downloadGroups:  services.NewDownloadGroups(),
+		httpTransfer:    services.NewHTTPTransferService(logger),
+		tcpService:      services.NewTCPService(logger),
+		udpService:      services.NewUDPService(logger),
 	}
 }
 
 // RegisterWebSocketRoutes registers WebSocket routes with actual handlers
 func (h *TestHandler) RegisterWebSocketRoutes(app *fiber.App) {
+	// Same allow list as CORS, read live so reloads apply
+	app.Use("/ws", middleware.WebSocketOrigin(func(origin string) bool {
+		return h.config.Get().IsOriginAllowed(origin)
+	}))
+
 	// Ping test WebSocket handler
 	app.Get("/ws/ping", websocket.New(func(c *websocket.Conn) {
 		h.handlePingWebSocket(c)
@@ -49,6 +75,16 @@ func (h *TestHandler) RegisterWebSocketRoutes(app *fiber.App) {
 	app.Get("/ws/upload", websocket.New(func(c *websocket.Conn) {
 		h.handleUploadWebSocket(c)
 	}))
+
+	// UDP latency test control channel
+	app.Get("/ws/udp", websocket.New(func(c *websocket.Conn) {
+		h.handleUDPWebSocket(c)
+	}))
+
+	// Full test session (ping, download, upload) over one socket
+	app.Get("/ws/test", websocket.New(func(c *websocket.Conn) {
+		h.handleTestSessionWebSocket(c)
+	}))
 }
 
 func (h *TestHandler) handlePingWebSocket(c *websocket.Conn) {
@@ -58,22 +94,99 @@ func (h *TestHandler) handlePingWebSocket(c *websocket.Conn) {
 	h.activeConnections.Store(connID, true)
 	defer h.activeConnections.Delete(connID)
 
-	h.logger.Info("Ping test started", zap.String("remote", connID))
+	h.logger.Info("Ping test started", zap.String("remote", connID), zap.String("client", wsClientIP(c)))
+
+	sessionID := c.Query("session")
+	if !h.checkSession(c, sessionID) {
+		return
+	}
+
+	// Clients may tune the probes in a start message, as in /ws/test
+	conn, start := readPingStart(c)
+	result, _ := h.runPingPhase(conn, requestedPingOptions(h.config.Get(), start.PingCount, start.PingInterval))
+	if result != nil {
+		h.recordPhase(wsClientIP(c), sessionID, func(r *models.TestResult) { r.AddPing(result) })
+	}
+}
+
+// pingStartTimeout bounds the wait for /ws/ping's optional start message
+const pingStartTimeout = 500 * time.Millisecond
+
+// readPingStart waits up to pingStartTimeout for a start message on c. A
+// WebSocket read that times out can't be retried, so the read runs in the
+// background rather than under a deadline. If nothing arrives in time, or
+// the first message is something else, the returned connection hands that
+// read's outcome to the ping test, and the defaults apply.
+func readPingStart(c *websocket.Conn) (testConn, models.TestStartMessage) {
+	first := make(chan pendingRead, 1)
+	go func() {
+		messageType, data, err := c.ReadMessage()
+		first <- pendingRead{messageType: messageType, data: data, err: err}
+	}()
+
+	var start models.TestStartMessage
+	select {
+	case r := <-first:
+		if r.err == nil && json.Unmarshal(r.data, &start) == nil && start.Type == "start" {
+			return c, start
+		}
+		first <- r
+	case <-time.After(pingStartTimeout):
+	}
+	return &pendingReadConn{testConn: c, pending: first}, models.TestStartMessage{}
+}
+
+// pendingRead is the outcome of a ReadMessage call
+type pendingRead struct {
+	messageType int
+	data        []byte
+	err         error
+}
+
+// pendingReadConn returns the outcome of a read already in flight before
+// reading from the connection again. Like the connection, it allows one
+// reader at a time.
+type pendingReadConn struct {
+	testConn
+	pending chan pendingRead // nil once taken
+}
+
+func (c *pendingReadConn) ReadMessage() (int, []byte, error) {
+	if c.pending != nil {
+		r := <-c.pending
+		c.pending = nil
+		return r.messageType, r.data, r.err
+	}
+	return c.testConn.ReadMessage()
+}
+
+func (c *pendingReadConn) ReadJSON(v interface{}) error {
+	_, data, err := c.ReadMessage()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// runPingPhase runs the ping test and sends its result, returning it once
+// sent. An error alongside a result means the client missed a pong and c
+// can't be read again.
+func (h *TestHandler) runPingPhase(c testConn, opts services.PingOptions) (*models.PingResult, error) {
+	result, testErr := h.pingService.RunTest(c, opts)
+	result.Transport = transportOf(c)
 
-	// Run ping test
-	result := h.pingService.RunTest(c)
-	
 	// Send result
 	if err := c.WriteJSON(result); err != nil {
 		h.logger.Error("Failed to send ping result", zap.Error(err))
-		return
+		return nil, err
 	}
 
 	h.logger.Info("Ping test completed",
 		zap.Float64("latency", result.Latency),
 		zap.Float64("jitter", result.Jitter),
-		zap.String("remote", connID),
+		zap.String("remote", c.RemoteAddr().String()),
 	)
+	return result, testErr
 }
 
 func (h *TestHandler) handleDownloadWebSocket(c *websocket.Conn) {
@@ -83,46 +196,140 @@ func (h *TestHandler) handleDownloadWebSocket(c *websocket.Conn) {
 	h.activeConnections.Store(connID, true)
 	defer h.activeConnections.Delete(connID)
 
-	h.logger.Info("Download test started", zap.String("remote", connID))
+	h.logger.Info("Download test started", zap.String("remote", connID), zap.String("client", wsClientIP(c)))
 
+	// Snapshot the config so a reload doesn't affect this test
+	cfg := h.config.Get()
+
+	// Read start message (optional, use default if not provided)
+	var startMsg models.DownloadMessage
+	_ = c.ReadJSON(&startMsg) // Ignore error, use default if not provided
+
+	// The session may come from the query string or the start message
+	sessionID := c.Query("session", startMsg.SessionID)
+	if !h.checkSession(c, sessionID) {
+		return
+	}
+
+	if startMsg.Streams > 1 {
+		h.handleDownloadStream(c, cfg, sessionID, startMsg)
+		return
+	}
+
+	result, _ := h.runDownloadPhase([]testConn{c}, cfg, startMsg.ChunkSize, wantsLoadedLatency(c))
+	if result != nil {
+		h.recordPhase(wsClientIP(c), sessionID, func(r *models.TestResult) { r.AddDownload(result) })
+	}
+}
+
+// handleDownloadStream adds a connection to a multi-stream download. The
+// session ID binds the streams together; the first connection to arrive runs
+// the test over all of them and records the result, and every stream is
+// sent the same result.
+func (h *TestHandler) handleDownloadStream(c *websocket.Conn, cfg *config.Config, sessionID string, startMsg models.DownloadMessage) {
+	maxStreams := cfg.Tests.Download.MaxStreams
+	if sessionID == "" {
+		h.sendError(c, "multi-stream downloads require a session")
+		return
+	}
+	if startMsg.Streams > maxStreams {
+		h.sendError(c, fmt.Sprintf("streams must be between 1 and %d", maxStreams))
+		return
+	}
+
+	group, stream, err := h.downloadGroups.Join(sessionID, startMsg.Streams, c)
+	if err != nil {
+		h.sendError(c, err.Error())
+		return
+	}
+
+	if stream > 0 {
+		result := group.Wait()
+		if result == nil {
+			h.sendError(c, "download failed")
+			return
+		}
+		if err := c.WriteJSON(result); err != nil {
+			h.logger.Debug("Failed to send download result", zap.Error(err), zap.Int("stream", stream))
+		}
+		return
+	}
+
+	var result *models.DownloadResult
+	defer func() { h.downloadGroups.Finish(group, result) }()
+
+	conns := h.downloadGroups.Start(group, services.StreamJoinTimeout)
+	h.logger.Info("Multi-stream download starting",
+		zap.String("session", sessionID),
+		zap.Int("requested", startMsg.Streams),
+		zap.Int("joined", len(conns)),
+	)
+
+	streams := make([]testConn, len(conns))
+	for i, conn := range conns {
+		streams[i] = conn
+	}
+	result, _ = h.runDownloadPhase(streams, cfg, startMsg.ChunkSize, wantsLoadedLatency(c))
+	if result != nil {
+		h.recordPhase(wsClientIP(c), sessionID, func(r *models.TestResult) { r.AddDownload(result) })
+	}
+}
+
+// runDownloadPhase runs the download test over conns and sends the result on
+// conns[0], returning it once sent. chunkSize is the client's requested
+// initial chunk size, 0 for the default. With loadedLatency the client
+// answers latency probes on conns[0]; an error alongside a result means it
+// stopped answering and conns[0] can't be read again.
+func (h *TestHandler) runDownloadPhase(conns []testConn, cfg *config.Config, chunkSize int, loadedLatency bool) (*models.DownloadResult, error) {
+	c := conns[0]
 	// Log CPU usage if enabled
-	if h.config.EnableMetrics {
+	if cfg.Server.EnableMetrics {
 		go func() {
 			ctx := context.Background()
 			h.metricsService.LogCPUUsage(ctx)
 		}()
 	}
 
-	// Read start message (optional, use default if not provided)
-	var startMsg struct {
-		ChunkSize int `json:"chunkSize"`
+	opts := downloadOptions(cfg)
+	if chunkSize > 0 {
+		opts.InitialChunkSize = chunkSize
 	}
-	_ = c.ReadJSON(&startMsg) // Ignore error, use default if not provided
-	
-	chunkSize := startMsg.ChunkSize
-	if chunkSize == 0 {
-		chunkSize = 256 * 1024 // Default 256 KB
+	idle, err := h.idleLatency(c, cfg, loadedLatency && opts.LatencyInterval > 0)
+	if err != nil {
+		h.sendError(c, err.Error())
+		return nil, err
+	}
+	if idle == 0 {
+		opts.LatencyInterval = 0
 	}
 
 	// Run download test
-	result := h.downloadService.RunTest(c, chunkSize)
+	streams := make([]services.MessageConn, len(conns))
+	for i, conn := range conns {
+		streams[i] = conn
+	}
+	result, testErr := h.downloadService.RunTest(streams, opts)
+	result.Transport = transportOf(c)
+	result.IdleLatency = idle
+	scoring.GradeLatencyUnderLoad(cfg.Scoring, &result.LatencyUnderLoad)
 
 	// Log traffic if enabled
-	if h.config.EnableLogging {
+	if cfg.Server.EnableLogging {
 		h.metricsService.LogTraffic(result.Bytes, "download", result.Duration)
 	}
 
 	// Send result
 	if err := c.WriteJSON(result); err != nil {
 		h.logger.Error("Failed to send download result", zap.Error(err))
-		return
+		return nil, err
 	}
 
 	h.logger.Info("Download test completed",
 		zap.Float64("throughput", result.Throughput),
 		zap.Int64("bytes", result.Bytes),
-		zap.String("remote", connID),
+		zap.String("remote", c.RemoteAddr().String()),
 	)
+	return result, testErr
 }
 
 func (h *TestHandler) handleUploadWebSocket(c *websocket.Conn) {
@@ -132,35 +339,219 @@ func (h *TestHandler) handleUploadWebSocket(c *websocket.Conn) {
 	h.activeConnections.Store(connID, true)
 	defer h.activeConnections.Delete(connID)
 
-	h.logger.Info("Upload test started", zap.String("remote", connID))
+	h.logger.Info("Upload test started", zap.String("remote", connID), zap.String("client", wsClientIP(c)))
+
+	// Snapshot the config so a reload doesn't affect this test
+	cfg := h.config.Get()
+
+	sessionID := c.Query("session")
+	if !h.checkSession(c, sessionID) {
+		return
+	}
+
+	result, _ := h.runUploadPhase(c, cfg, wantsLoadedLatency(c))
+	if result != nil {
+		h.recordPhase(wsClientIP(c), sessionID, func(r *models.TestResult) { r.AddUpload(result) })
+	}
+}
 
+// runUploadPhase runs the upload test and sends its result, returning it
+// once sent. With loadedLatency the client answers latency probes.
+func (h *TestHandler) runUploadPhase(c testConn, cfg *config.Config, loadedLatency bool) (*models.UploadResult, error) {
 	// Log CPU usage if enabled
-	if h.config.EnableMetrics {
+	if cfg.Server.EnableMetrics {
 		go func() {
 			ctx := context.Background()
 			h.metricsService.LogCPUUsage(ctx)
 		}()
 	}
 
+	opts := uploadOptions(cfg)
+	idle, err := h.idleLatency(c, cfg, loadedLatency && opts.LatencyInterval > 0)
+	if err != nil {
+		h.sendError(c, err.Error())
+		return nil, err
+	}
+	if idle == 0 {
+		opts.LatencyInterval = 0
+	}
+
 	// Run upload test
-	result := h.uploadService.RunTest(c)
+	result := h.uploadService.RunTest(c, opts)
+	result.Transport = transportOf(c)
+	result.IdleLatency = idle
+	scoring.GradeLatencyUnderLoad(cfg.Scoring, &result.LatencyUnderLoad)
 
 	// Log traffic if enabled
-	if h.config.EnableLogging {
+	if cfg.Server.EnableLogging {
 		h.metricsService.LogTraffic(result.Bytes, "upload", result.Duration)
 	}
 
 	// Send result
 	if err := c.WriteJSON(result); err != nil {
 		h.logger.Error("Failed to send upload result", zap.Error(err))
-		return
+		return nil, err
 	}
 
 	h.logger.Info("Upload test completed",
 		zap.Float64("throughput", result.Throughput),
 		zap.Int64("bytes", result.Bytes),
-		zap.String("remote", connID),
+		zap.String("remote", c.RemoteAddr().String()),
 	)
+	return result, nil
+}
+
+// idleLatencyProbes is how many pings measure the idle latency that latency
+// under load is compared with
+const idleLatencyProbes = 5
+
+// idleLatency measures the latency of c before a transfer, with the ping
+// test's own exchange, when enabled. It returns 0 when disabled, and an
+// error when the client missed a pong, since c can't be read after that.
+func (h *TestHandler) idleLatency(c testConn, cfg *config.Config, enabled bool) (float64, error) {
+	if !enabled {
+		return 0, nil
+	}
+	opts := pingOptions(cfg)
+	opts.Count = idleLatencyProbes
+	opts.Interval = 0
+	result, err := h.pingService.RunTest(c, opts)
+	if err != nil {
+		return 0, err
+	}
+	return result.Latency, nil
+}
+
+// wantsLoadedLatency reports whether a WebSocket test asked for latency
+// probes with ?latency=1
+func wantsLoadedLatency(c *websocket.Conn) bool {
+	return c.Query("latency") == "1"
+}
+
+// checkSession verifies an optional session ID before a phase starts, so a
+// client with a stale ID finds out before spending a whole test on it
+func (h *TestHandler) checkSession(c testConn, sessionID string) bool {
+	if sessionID == "" || h.sessionService.Exists(sessionID) {
+		return true
+	}
+	h.sendError(c, services.ErrSessionNotFound.Error())
+	return false
+}
+
+// recordPhase adds a phase result to the test's session, reassesses it and
+// stores the updated session. A test without a session is stored on its own
+// under clientIP.
+func (h *TestHandler) recordPhase(clientIP, sessionID string, record func(r *models.TestResult)) {
+	cfg := h.config.Get()
+	fn := func(r *models.TestResult) {
+		record(r)
+		assess(cfg, r)
+	}
+
+	if sessionID == "" {
+		result := models.TestResult{Type: "summary"}
+		fn(&result)
+		result.Timestamp = time.Now().Unix()
+		h.resultService.SaveNew(clientIP, result)
+		return
+	}
+
+	if err := h.sessionService.Update(sessionID, fn); err != nil {
+		h.logger.Warn("Failed to record phase result", zap.Error(err), zap.String("session", sessionID))
+		return
+	}
+	if session, err := h.sessionService.Get(sessionID); err == nil {
+		h.resultService.Save(session.ID, session.ClientIP, session.CreatedAt, session.Result)
+	}
+}
+
+// assess recomputes the connection quality and application verdicts of a
+// result after a phase was added
+func assess(cfg *config.Config, r *models.TestResult) {
+	r.Quality = services.AssessConnectionQuality(r)
+	r.Readiness = scoring.Assess(cfg.Scoring, r)
+}
+
+// testConn is the connection a test phase runs over: a WebSocket or a QUIC
+// stream
+type testConn interface {
+	services.MessageConn
+	RemoteAddr() net.Addr
+}
+
+// transportOf names the transport of c for the result
+func transportOf(c testConn) string {
+	if _, ok := c.(*quicproto.Conn); ok {
+		return models.TransportQUIC
+	}
+	return models.TransportWebSocket
+}
+
+// wsClientIP returns the client IP resolved by middleware.ClientIP before the upgrade
+func wsClientIP(c *websocket.Conn) string {
+	if ip, ok := c.Locals(middleware.ClientIPLocal).(string); ok {
+		return ip
+	}
+	return c.RemoteAddr().String()
+}
+
+func pingOptions(cfg *config.Config) services.PingOptions {
+	p := cfg.Tests.Ping
+	return services.PingOptions{
+		Count:    p.Count,
+		Interval: p.Interval,
+		Timeout:  p.Timeout,
+	}
+}
+
+// requestedPingOptions applies the probe count and interval (in ms) a client
+// asked for; zero keeps the server's setting. The service clamps them.
+func requestedPingOptions(cfg *config.Config, count int, intervalMs float64) services.PingOptions {
+	opts := pingOptions(cfg)
+	if count > 0 {
+		opts.Count = count
+	}
+	if intervalMs > 0 {
+		// Cap before converting so huge values can't overflow
+		opts.Interval = time.Duration(math.Min(intervalMs, float64(time.Hour/time.Millisecond)) * float64(time.Millisecond))
+	}
+	return opts
+}
+
+func udpOptions(cfg *config.Config) services.UDPOptions {
+	u := cfg.Tests.UDP
+	return services.UDPOptions{
+		Count:   u.Count,
+		Rate:    u.Rate,
+		Timeout: u.Timeout,
+	}
+}
+
+func downloadOptions(cfg *config.Config) services.DownloadOptions {
+	d := cfg.Tests.Download
+	return services.DownloadOptions{
+		Duration:          d.Duration,
+		InitialChunkSize:  d.InitialChunkSize,
+		MinChunkSize:      d.MinChunkSize,
+		MaxChunkSize:      d.MaxChunkSize,
+		MaxStreams:        d.MaxStreams,
+		MaxThroughputMbps: d.MaxThroughputMbps,
+		ProgressInterval:  d.ProgressInterval,
+		LatencyInterval:   d.LatencyInterval,
+	}
+}
+
+func uploadOptions(cfg *config.Config) services.UploadOptions {
+	u := cfg.Tests.Upload
+	return services.UploadOptions{
+		Duration:          u.Duration,
+		InitialChunkSize:  u.InitialChunkSize,
+		MinChunkSize:      u.MinChunkSize,
+		MaxChunkSize:      u.MaxChunkSize,
+		MaxThroughputMbps: u.MaxThroughputMbps,
+		ProgressInterval:  u.ProgressInterval,
+		LatencyInterval:   u.LatencyInterval,
+	}
 }
 
 // GetActiveConnections returns the number of active connections
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"nova-speed/backend/internal/middleware"
+	"nova-speed/backend/internal/models"
+	"nova-speed/backend/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+)
+
+// DefaultHTTPDownloadBytes is the GET /api/download size when ?bytes is omitted
+const DefaultHTTPDownloadBytes = 25 * 1024 * 1024
+
+// RegisterHTTPRoutes registers the plain HTTP download and upload tests, for
+// clients and networks where WebSockets are not an option
+func (h *TestHandler) RegisterHTTPRoutes(app *fiber.App) {
+	app.Get("/api/download", h.HandleHTTPDownload)
+	app.Post("/api/upload", h.HandleHTTPUpload)
+}
+
+// HandleHTTPDownload streams ?bytes=N of random data. The server-side result
+// is recorded into ?session=<id> once the body has been sent.
+func (h *TestHandler) HandleHTTPDownload(c *fiber.Ctx) error {
+	cfg := h.config.Get()
+
+	size := int64(DefaultHTTPDownloadBytes)
+	if v := c.Query("bytes"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n <= 0 || n > cfg.Tests.Download.HTTPMaxBytes {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": fmt.Sprintf("bytes must be between 1 and %d", cfg.Tests.Download.HTTPMaxBytes),
+			})
+		}
+		size = n
+	}
+
+	sessionID := c.Query("session")
+	if sessionID != "" && !h.sessionService.Exists(sessionID) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Session not found or expired",
+		})
+	}
+
+	clientIP := middleware.GetClientIP(c)
+	body := h.httpTransfer.Download(size, downloadOptions(cfg), func(result *models.DownloadResult) {
+		h.recordPhase(clientIP, sessionID, func(r *models.TestResult) { r.AddDownload(result) })
+	})
+
+	c.Set(fiber.HeaderContentType, "application/octet-stream")
+	c.Set(fiber.HeaderCacheControl, "no-store, no-cache, must-revalidate, max-age=0")
+	c.Set("Pragma", "no-cache")
+	c.Set("Expires", "0")
+	return c.SendStream(body, int(size))
+}
+
+// HandleHTTPUpload reads and discards the request body and returns an
+// UploadResult timed by the server
+func (h *TestHandler) HandleHTTPUpload(c *fiber.Ctx) error {
+	cfg := h.config.Get()
+	start := c.Context().Time()
+
+	sessionID := c.Query("session")
+	if sessionID != "" && !h.sessionService.Exists(sessionID) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Session not found or expired",
+		})
+	}
+
+	maxBytes := cfg.Tests.Upload.HTTPMaxBytes
+	if c.Request().Header.ContentLength() > 0 && int64(c.Request().Header.ContentLength()) > maxBytes {
+		return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{
+			"error": fmt.Sprintf("upload must not exceed %d bytes", maxBytes),
+		})
+	}
+
+	// Bodies are streamed (StreamRequestBody), so only the first few KB
+	// have been read when the handler runs
+	var body io.Reader = c.Context().RequestBodyStream()
+	if body == nil {
+		body = bytes.NewReader(c.Body())
+	}
+
+	result, err := h.httpTransfer.Upload(body, start, maxBytes, uploadOptions(cfg))
+	if errors.Is(err, services.ErrUploadTooLarge) {
+		return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{
+			"error": fmt.Sprintf("upload must not exceed %d bytes", maxBytes),
+		})
+	}
+	if err != nil {
+		h.logger.Debug("Failed to read upload body", zap.Error(err))
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "failed to read request body",
+		})
+	}
+
+	h.recordPhase(middleware.GetClientIP(c), sessionID, func(r *models.TestResult) { r.AddUpload(result) })
+
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	return c.JSON(result)
+}
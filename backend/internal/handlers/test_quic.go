@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"nova-speed/backend/internal/middleware"
+	"nova-speed/backend/internal/models"
+	"nova-speed/backend/internal/quicproto"
+
+	"github.com/quic-go/quic-go"
+	"go.uber.org/zap"
+)
+
+// quicStartTimeout bounds the wait for a stream's start message
+const quicStartTimeout = 10 * time.Second
+
+// ServeQUIC runs tests for the connections of ln until it is closed. Each
+// bidirectional stream is one test, opened with a StreamStartMessage.
+// Every stream counts against limiter, like a WebSocket test.
+func (h *TestHandler) ServeQUIC(ln *quic.Listener, limiter *middleware.ConnectionLimiter) {
+	for {
+		conn, err := ln.Accept(context.Background())
+		if err != nil {
+			h.logger.Debug("QUIC listener stopped", zap.Error(err))
+			return
+		}
+		go h.handleQUICConn(conn, limiter)
+	}
+}
+
+func (h *TestHandler) handleQUICConn(conn quic.Connection, limiter *middleware.ConnectionLimiter) {
+	for {
+		stream, err := conn.AcceptStream(conn.Context())
+		if err != nil {
+			return
+		}
+		c := quicproto.NewConn(conn, stream)
+		if !limiter.Acquire() {
+			go func() {
+				defer c.Close()
+				c.SetWriteDeadline(time.Now().Add(quicStartTimeout))
+				h.sendError(c, middleware.ErrAtCapacity.Error())
+			}()
+			continue
+		}
+		go func() {
+			defer limiter.Release()
+			h.handleQUICStream(c)
+		}()
+	}
+}
+
+func (h *TestHandler) handleQUICStream(c *quicproto.Conn) {
+	defer c.Close()
+
+	h.activeConnections.Store(c, true)
+	defer h.activeConnections.Delete(c)
+
+	// Forwarding headers don't exist here; the peer is the client
+	clientIP := c.RemoteAddr().String()
+	if host, _, err := net.SplitHostPort(clientIP); err == nil {
+		clientIP = host
+	}
+
+	// Snapshot the config so a reload doesn't affect this test
+	cfg := h.config.Get()
+
+	var start models.StreamStartMessage
+	c.SetReadDeadline(time.Now().Add(quicStartTimeout))
+	if err := c.ReadJSON(&start); err != nil || start.Type != "start" {
+		h.sendError(c, "expected a start message")
+		return
+	}
+	c.SetReadDeadline(time.Time{})
+
+	h.logger.Info("QUIC test started",
+		zap.String("test", start.Test),
+		zap.String("remote", c.RemoteAddr().String()),
+	)
+
+	if !h.checkSession(c, start.SessionID) {
+		return
+	}
+
+	switch start.Test {
+	case "ping":
+		result, _ := h.runPingPhase(c, requestedPingOptions(cfg, start.PingCount, start.PingInterval))
+		if result != nil {
+			h.recordPhase(clientIP, start.SessionID, func(r *models.TestResult) { r.AddPing(result) })
+		}
+	case "download":
+		result, _ := h.runDownloadPhase([]testConn{c}, cfg, start.ChunkSize, start.LoadedLatency)
+		if result != nil {
+			h.recordPhase(clientIP, start.SessionID, func(r *models.TestResult) { r.AddDownload(result) })
+		}
+	case "upload":
+		result, _ := h.runUploadPhase(c, cfg, start.LoadedLatency)
+		if result != nil {
+			h.recordPhase(clientIP, start.SessionID, func(r *models.TestResult) { r.AddUpload(result) })
+		}
+	default:
+		h.sendError(c, fmt.Sprintf("unknown test %q", start.Test))
+	}
+}
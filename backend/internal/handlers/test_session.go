@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"fmt"
+	"time"
+
+	"nova-speed/backend/internal/config"
+	"nova-speed/backend/internal/models"
+
+	"github.com/gofiber/websocket/v2"
+	"go.uber.org/zap"
+)
+
+// Session phases, in the order they always run
+const (
+	PhasePing     = "ping"
+	PhaseDownload = "download"
+	PhaseUpload   = "upload"
+)
+
+var allPhases = []string{PhasePing, PhaseDownload, PhaseUpload}
+
+// startMessageTimeout bounds the wait for the client's start message
+const startMessageTimeout = 10 * time.Second
+
+// handleTestSessionWebSocket runs the selected phases over one socket. The
+// server first confirms the session ID, then each phase speaks exactly the
+// protocol of its own endpoint (the download start message is replaced by
+// the session's), bracketed by "phase" messages; the session ends with a
+// "summary" TestResult.
+func (h *TestHandler) handleTestSessionWebSocket(c *websocket.Conn) {
+	defer c.Close()
+
+	connID := c.RemoteAddr().String()
+	h.activeConnections.Store(connID, true)
+	defer h.activeConnections.Delete(connID)
+
+	// Snapshot the config so a reload doesn't affect this session
+	cfg := h.config.Get()
+
+	c.SetReadDeadline(time.Now().Add(startMessageTimeout))
+	var startMsg models.TestStartMessage
+	if err := c.ReadJSON(&startMsg); err != nil || startMsg.Type != "start" {
+		h.sendError(c, "expected a start message")
+		return
+	}
+	c.SetReadDeadline(time.Time{})
+
+	phases, err := parsePhases(startMsg.Phases)
+	if err != nil {
+		h.sendError(c, err.Error())
+		return
+	}
+
+	// Record into the client's session, or start one
+	sessionID := startMsg.SessionID
+	if sessionID == "" {
+		session, err := h.sessionService.Create(wsClientIP(c))
+		if err != nil {
+			h.logger.Error("Failed to create test session", zap.Error(err))
+			h.sendError(c, "failed to create session")
+			return
+		}
+		sessionID = session.ID
+	} else if !h.checkSession(c, sessionID) {
+		return
+	}
+	if err := c.WriteJSON(models.SessionMessage{Type: "session", SessionID: sessionID}); err != nil {
+		return
+	}
+
+	h.logger.Info("Test session started",
+		zap.String("remote", connID),
+		zap.String("client", wsClientIP(c)),
+		zap.String("session", sessionID),
+		zap.Strings("phases", phases),
+	)
+
+	summary, err := h.runSession(c, cfg, sessionID, phases, startMsg)
+	if err != nil {
+		h.logger.Warn("Test session aborted", zap.Error(err), zap.String("session", sessionID))
+		return
+	}
+
+	if err := c.WriteJSON(summary); err != nil {
+		h.logger.Error("Failed to send test summary", zap.Error(err))
+		return
+	}
+
+	h.logger.Info("Test session completed",
+		zap.Float64("latency", summary.Latency),
+		zap.Float64("download", summary.Download),
+		zap.Float64("upload", summary.Upload),
+		zap.String("session", sessionID),
+	)
+}
+
+// runSession runs each phase in turn, recording each result into the
+// session as it completes. It stops at the first phase whose messages can't
+// be delivered, or that leaves c unreadable for the next.
+func (h *TestHandler) runSession(c *websocket.Conn, cfg *config.Config, sessionID string, phases []string, start models.TestStartMessage) (*models.TestResult, error) {
+	summary := &models.TestResult{Type: "summary"}
+
+	for i, phase := range phases {
+		msg := models.PhaseMessage{Type: "phase", Phase: phase, Status: "started", Index: i, Total: len(phases)}
+		if err := c.WriteJSON(msg); err != nil {
+			return nil, err
+		}
+
+		// A phase returns its result once sent, and an error if c can't be
+		// used after it
+		var record func(r *models.TestResult)
+		var phaseErr error
+		switch phase {
+		case PhasePing:
+			result, err := h.runPingPhase(c, requestedPingOptions(cfg, start.PingCount, start.PingInterval))
+			if result != nil {
+				record = func(r *models.TestResult) { r.AddPing(result) }
+			}
+			phaseErr = err
+		case PhaseDownload:
+			result, err := h.runDownloadPhase([]testConn{c}, cfg, start.ChunkSize, start.LoadedLatency)
+			if result != nil {
+				record = func(r *models.TestResult) { r.AddDownload(result) }
+			}
+			phaseErr = err
+		case PhaseUpload:
+			result, err := h.runUploadPhase(c, cfg, start.LoadedLatency)
+			if result != nil {
+				record = func(r *models.TestResult) { r.AddUpload(result) }
+			}
+			phaseErr = err
+		}
+		if record == nil {
+			return nil, phaseErr
+		}
+		record(summary)
+		assess(cfg, summary)
+		h.recordPhase(wsClientIP(c), sessionID, record)
+
+		msg.Status = "completed"
+		if err := c.WriteJSON(msg); err != nil {
+			return nil, err
+		}
+		if phaseErr != nil {
+			h.sendError(c, phaseErr.Error())
+			return nil, phaseErr
+		}
+	}
+
+	summary.Timestamp = time.Now().Unix()
+	return summary, nil
+}
+
+// parsePhases validates the requested phases and puts them in run order.
+// An empty list selects every phase.
+func parsePhases(requested []string) ([]string, error) {
+	if len(requested) == 0 {
+		return allPhases, nil
+	}
+
+	wanted := make(map[string]bool)
+	for _, p := range requested {
+		known := false
+		for _, phase := range allPhases {
+			if p == phase {
+				known = true
+			}
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown phase %q", p)
+		}
+		wanted[p] = true
+	}
+
+	var phases []string
+	for _, phase := range allPhases {
+		if wanted[phase] {
+			phases = append(phases, phase)
+		}
+	}
+	return phases, nil
+}
+
+func (h *TestHandler) sendError(c testConn, message string) {
+	if err := c.WriteJSON(models.ErrorMessage{Type: "error", Message: message}); err != nil {
+		h.logger.Debug("Failed to send error message", zap.Error(err))
+	}
+}
@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"nova-speed/backend/internal/middleware"
+	"nova-speed/backend/internal/models"
+	"nova-speed/backend/internal/services"
+
+	"go.uber.org/zap"
+)
+
+// tcpFlowJoinTimeout bounds the wait for a raw TCP test's data connections
+const tcpFlowJoinTimeout = 5 * time.Second
+
+// ServeTCP runs raw TCP tests for the connections of ln until it is closed.
+// A connection's first line decides its role: "start" opens a test on a
+// control connection, "join" adds a data connection to one. Every
+// connection counts against limiter, like the HTTP server's.
+func (h *TestHandler) ServeTCP(ln net.Listener, limiter *middleware.ConnectionLimiter) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			h.logger.Debug("Raw TCP listener stopped", zap.Error(err))
+			return
+		}
+		if !limiter.Acquire() {
+			go func() {
+				conn.SetWriteDeadline(time.Now().Add(startMessageTimeout))
+				writeTCPMessage(conn, models.ErrorMessage{Type: "error", Message: middleware.ErrAtCapacity.Error()})
+				conn.Close()
+			}()
+			continue
+		}
+		go h.handleTCPConn(&limitedConn{Conn: conn, release: limiter.Release})
+	}
+}
+
+// limitedConn gives back its connection limiter slot when closed, which
+// for a data connection happens once the test it joined is over
+type limitedConn struct {
+	net.Conn
+	once    sync.Once
+	release func()
+}
+
+func (c *limitedConn) Close() error {
+	c.once.Do(c.release)
+	return c.Conn.Close()
+}
+
+func (h *TestHandler) handleTCPConn(conn net.Conn) {
+	reader := bufio.NewReader(conn)
+
+	var msg models.TCPMessage
+	conn.SetReadDeadline(time.Now().Add(startMessageTimeout))
+	line, err := reader.ReadBytes('\n')
+	if err != nil || json.Unmarshal(line, &msg) != nil {
+		conn.Close()
+		return
+	}
+	conn.SetReadDeadline(time.Time{})
+
+	switch msg.Type {
+	case "start":
+		h.handleTCPControl(conn, msg)
+	case "join":
+		// The flow carries nothing but test data from here on, so the
+		// bufio reader can't be holding any of it yet
+		if err := h.tcpService.Join(msg.Token, conn); err != nil {
+			writeTCPMessage(conn, models.ErrorMessage{Type: "error", Message: err.Error()})
+			conn.Close()
+		}
+	default:
+		writeTCPMessage(conn, models.ErrorMessage{Type: "error", Message: "expected a start or join message"})
+		conn.Close()
+	}
+}
+
+// handleTCPControl runs one raw TCP test from its control connection
+func (h *TestHandler) handleTCPControl(conn net.Conn, start models.TCPMessage) {
+	defer conn.Close()
+
+	h.activeConnections.Store(conn, true)
+	defer h.activeConnections.Delete(conn)
+
+	// Forwarding headers don't exist here; the peer is the client
+	clientIP := conn.RemoteAddr().String()
+	if host, _, err := net.SplitHostPort(clientIP); err == nil {
+		clientIP = host
+	}
+
+	// Snapshot the config so a reload doesn't affect this test
+	cfg := h.config.Get()
+
+	sendError := func(message string) {
+		writeTCPMessage(conn, models.ErrorMessage{Type: "error", Message: message})
+	}
+
+	if start.Test != "download" && start.Test != "upload" {
+		sendError(fmt.Sprintf("unknown test %q", start.Test))
+		return
+	}
+	if start.SessionID != "" && !h.sessionService.Exists(start.SessionID) {
+		sendError(services.ErrSessionNotFound.Error())
+		return
+	}
+
+	// Both directions share the download's stream limit
+	downloadOpts := downloadOptions(cfg)
+	uploadOpts := uploadOptions(cfg)
+	if start.Duration > 0 {
+		downloadOpts.Duration = time.Duration(start.Duration * float64(time.Second))
+		uploadOpts.Duration = downloadOpts.Duration
+	}
+	downloadOpts = downloadOpts.Clamped()
+	uploadOpts = uploadOpts.Clamped()
+
+	flows := start.Flows
+	if flows < 1 {
+		flows = 1
+	}
+	if flows > downloadOpts.MaxStreams {
+		flows = downloadOpts.MaxStreams
+	}
+	duration := downloadOpts.Duration
+	if start.Test == "upload" {
+		duration = uploadOpts.Duration
+	}
+
+	test, err := h.tcpService.Prepare(flows)
+	if err != nil {
+		h.logger.Error("Failed to prepare raw TCP test", zap.Error(err))
+		sendError("failed to prepare test")
+		return
+	}
+	ready := models.TCPMessage{Type: "ready", Test: start.Test, Duration: duration.Seconds(), Flows: flows, Token: test.Token}
+	if err := writeTCPMessage(conn, ready); err != nil {
+		closeAll(h.tcpService.Wait(test, 0))
+		return
+	}
+
+	conns := h.tcpService.Wait(test, tcpFlowJoinTimeout)
+	if len(conns) == 0 {
+		sendError("no data connections joined")
+		return
+	}
+	if err := writeTCPMessage(conn, models.TCPMessage{Type: "running", Flows: len(conns)}); err != nil {
+		closeAll(conns)
+		return
+	}
+
+	h.logger.Info("Raw TCP test started",
+		zap.String("test", start.Test),
+		zap.String("remote", conn.RemoteAddr().String()),
+		zap.Int("flows", len(conns)),
+		zap.Duration("duration", duration),
+	)
+
+	if start.Test == "download" {
+		result := h.tcpService.RunDownload(conns, downloadOpts)
+		if err := writeTCPMessage(conn, result); err != nil {
+			h.logger.Error("Failed to send raw TCP download result", zap.Error(err))
+			return
+		}
+		h.recordPhase(clientIP, start.SessionID, func(r *models.TestResult) { r.AddDownload(result) })
+		return
+	}
+
+	result := h.tcpService.RunUpload(conns, uploadOpts)
+	if err := writeTCPMessage(conn, result); err != nil {
+		h.logger.Error("Failed to send raw TCP upload result", zap.Error(err))
+		return
+	}
+	h.recordPhase(clientIP, start.SessionID, func(r *models.TestResult) { r.AddUpload(result) })
+}
+
+func closeAll(conns []net.Conn) {
+	for _, c := range conns {
+		c.Close()
+	}
+}
+
+// writeTCPMessage sends v as one line of JSON
+func writeTCPMessage(conn net.Conn, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	conn.SetWriteDeadline(time.Now().Add(startMessageTimeout))
+	defer conn.SetWriteDeadline(time.Time{})
+	_, err = conn.Write(append(data, '\n'))
+	return err
+}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"net"
+	"time"
+
+	"nova-speed/backend/internal/models"
+
+	"github.com/gofiber/websocket/v2"
+	"go.uber.org/zap"
+)
+
+// ServeUDP runs the UDP latency tests arriving on conn until it is closed
+func (h *TestHandler) ServeUDP(conn net.PacketConn) {
+	h.udpService.Serve(conn)
+}
+
+// handleUDPWebSocket is the control channel of a UDP latency test. The
+// client asks for a count and rate, gets a token and port, and the probes
+// then run over UDP; the result comes back over the socket.
+func (h *TestHandler) handleUDPWebSocket(c *websocket.Conn) {
+	defer c.Close()
+
+	connID := c.RemoteAddr().String()
+	h.activeConnections.Store(connID, true)
+	defer h.activeConnections.Delete(connID)
+
+	h.logger.Info("UDP latency test started", zap.String("remote", connID), zap.String("client", wsClientIP(c)))
+
+	// Snapshot the config so a reload doesn't affect this test
+	cfg := h.config.Get()
+
+	sessionID := c.Query("session")
+	if !h.checkSession(c, sessionID) {
+		return
+	}
+
+	c.SetReadDeadline(time.Now().Add(startMessageTimeout))
+	var start models.UDPMessage
+	if err := c.ReadJSON(&start); err != nil || start.Type != "start" {
+		h.sendError(c, "expected a start message")
+		return
+	}
+	c.SetReadDeadline(time.Time{})
+
+	opts := udpOptions(cfg)
+	if start.Count > 0 {
+		opts.Count = start.Count
+	}
+	if start.Rate > 0 {
+		opts.Rate = start.Rate
+	}
+	opts = opts.Clamped()
+
+	// Probes only go back to the IP that opened this control channel
+	clientIP := net.ParseIP(wsClientIP(c))
+	if clientIP == nil {
+		h.sendError(c, "cannot determine the client's IP address")
+		return
+	}
+
+	test, err := h.udpService.Prepare(opts, clientIP)
+	if err != nil {
+		h.sendError(c, err.Error())
+		return
+	}
+	defer h.udpService.Release(test)
+
+	ready := models.UDPMessage{Type: "ready", Count: opts.Count, Rate: opts.Rate, Token: test.Token, Port: h.udpService.Port()}
+	if err := c.WriteJSON(ready); err != nil {
+		return
+	}
+
+	result, err := h.udpService.RunTest(test, opts)
+	if err != nil {
+		h.sendError(c, err.Error())
+		return
+	}
+	if err := c.WriteJSON(result); err != nil {
+		h.logger.Error("Failed to send UDP latency result", zap.Error(err))
+		return
+	}
+	h.recordPhase(wsClientIP(c), sessionID, func(r *models.TestResult) { r.AddPing(result) })
+}
@@ -0,0 +1,124 @@
+// Package listen parses listen addresses from the config and opens them.
+//
+// Accepted forms:
+//
+//	:3001                    all interfaces, IPv4 and IPv6 (dual-stack)
+//	0.0.0.0:3001             all IPv4 interfaces only
+//	[::]:3001                all IPv6 interfaces only
+//	192.168.1.10:3001        one IPv4 address
+//	[2001:db8::10]:3001      one IPv6 address
+//	localhost:3001           whatever the hostname resolves to
+//	unix:/run/nova.sock      a Unix domain socket
+package listen
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const unixPrefix = "unix:"
+
+// Addr is a parsed listen address
+type Addr struct {
+	Network string // "tcp", "tcp4", "tcp6" or "unix"
+	Address string
+}
+
+// String returns the address in config form
+func (a Addr) String() string {
+	if a.Network == "unix" {
+		return unixPrefix + a.Address
+	}
+	return a.Address
+}
+
+// Port returns the TCP port, or "" for Unix sockets
+func (a Addr) Port() string {
+	if a.Network == "unix" {
+		return ""
+	}
+	_, port, _ := net.SplitHostPort(a.Address)
+	return port
+}
+
+// Parse validates a listen address and picks the network for it. A literal
+// IPv4 or IPv6 host binds that family only; an empty host or a hostname
+// lets the OS decide (dual-stack for an empty host).
+func Parse(s string) (Addr, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, unixPrefix) {
+		path := strings.TrimPrefix(s, unixPrefix)
+		if path == "" {
+			return Addr{}, fmt.Errorf("missing socket path in %q", s)
+		}
+		return Addr{Network: "unix", Address: path}, nil
+	}
+
+	host, portStr, err := net.SplitHostPort(s)
+	if err != nil {
+		return Addr{}, fmt.Errorf("invalid listen address %q: %v", s, err)
+	}
+	if port, err := strconv.Atoi(portStr); err != nil || port < 1 || port > 65535 {
+		return Addr{}, fmt.Errorf("invalid port in listen address %q", s)
+	}
+
+	network := "tcp"
+	if ip := net.ParseIP(host); ip != nil {
+		if ip.To4() != nil {
+			network = "tcp4"
+		} else {
+			network = "tcp6"
+		}
+	}
+	return Addr{Network: network, Address: s}, nil
+}
+
+// Listen opens the address. A stale Unix socket left behind by a previous
+// run is removed first; any other existing file is left alone. Unix sockets
+// are made world-connectable (0666) so a reverse proxy running as another
+// user can reach them; the endpoints are public HTTP anyway.
+func (a Addr) Listen() (net.Listener, error) {
+	if a.Network == "unix" {
+		if err := removeStaleSocket(a.Address); err != nil {
+			return nil, err
+		}
+	}
+
+	ln, err := net.Listen(a.Network, a.Address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", a, err)
+	}
+
+	if a.Network == "unix" {
+		if err := os.Chmod(a.Address, 0o666); err != nil {
+			ln.Close()
+			return nil, fmt.Errorf("failed to set permissions on %s: %w", a.Address, err)
+		}
+	}
+	return ln, nil
+}
+
+func removeStaleSocket(path string) error {
+	info, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Mode()&fs.ModeSocket == 0 {
+		return fmt.Errorf("%s exists and is not a socket", path)
+	}
+
+	// Only remove it if nobody is accepting on it
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return fmt.Errorf("%s is in use by another process", path)
+	}
+	return os.Remove(path)
+}
@@ -0,0 +1,112 @@
+package listen
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in      string
+		network string
+		address string
+		port    string
+		wantErr bool
+	}{
+		{in: ":3001", network: "tcp", address: ":3001", port: "3001"},
+		{in: " :3001 ", network: "tcp", address: ":3001", port: "3001"},
+		{in: "0.0.0.0:3001", network: "tcp4", address: "0.0.0.0:3001", port: "3001"},
+		{in: "192.168.1.10:80", network: "tcp4", address: "192.168.1.10:80", port: "80"},
+		{in: "[::]:3001", network: "tcp6", address: "[::]:3001", port: "3001"},
+		{in: "[2001:db8::10]:443", network: "tcp6", address: "[2001:db8::10]:443", port: "443"},
+		{in: "localhost:3001", network: "tcp", address: "localhost:3001", port: "3001"},
+		{in: "unix:/run/nova.sock", network: "unix", address: "/run/nova.sock", port: ""},
+
+		{in: "unix:", wantErr: true},
+		{in: "3001", wantErr: true},
+		{in: ":0", wantErr: true},
+		{in: ":65536", wantErr: true},
+		{in: ":http", wantErr: true},
+		{in: "::1:3001", wantErr: true},
+		{in: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		addr, err := Parse(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("Parse(%q) = %+v, want an error", tt.in, addr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.in, err)
+			continue
+		}
+		if addr.Network != tt.network || addr.Address != tt.address {
+			t.Errorf("Parse(%q) = %+v, want %s %s", tt.in, addr, tt.network, tt.address)
+		}
+		if got := addr.Port(); got != tt.port {
+			t.Errorf("Parse(%q).Port() = %q, want %q", tt.in, got, tt.port)
+		}
+		// String round-trips through Parse
+		if again, err := Parse(addr.String()); err != nil || again != addr {
+			t.Errorf("Parse(%q) = %+v, %v, want %+v", addr.String(), again, err, addr)
+		}
+	}
+}
+
+func TestListenUnix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nova.sock")
+	addr, err := Parse("unix:" + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ln, err := addr.Listen()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o666 {
+		t.Errorf("socket mode = %v, %v, want 0666", info.Mode().Perm(), err)
+	}
+
+	// A socket someone is accepting on is left alone
+	if _, err := addr.Listen(); err == nil {
+		t.Error("Listen() on a socket in use succeeded")
+	}
+	ln.Close()
+
+	// Closing a Unix listener removes its socket, so leave a stale one behind
+	// by hand
+	stale, err := addr.Listen()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("stale socket missing: %v", err)
+	}
+	ln, err = addr.Listen()
+	if err != nil {
+		t.Fatalf("Listen() over a stale socket: %v", err)
+	}
+	ln.Close()
+}
+
+func TestListenUnixNotSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nova.sock")
+	if err := os.WriteFile(path, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := (Addr{Network: "unix", Address: path}).Listen(); err == nil {
+		t.Error("Listen() replaced a regular file")
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("regular file removed: %v", err)
+	}
+}
@@ -7,7 +7,9 @@ import (
 	"go.uber.org/zap/zapcore"
 )
 
-func NewLogger() *zap.Logger {
+// NewLogger builds the application logger. The level is shared so it can be
+// changed at runtime (e.g. on config reload) without rebuilding the logger.
+func NewLogger(level zap.AtomicLevel) *zap.Logger {
 	config := zap.NewProductionConfig()
 	config.EncoderConfig.TimeKey = "timestamp"
 	config.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
@@ -20,6 +22,7 @@ func NewLogger() *zap.Logger {
 		config = zap.NewDevelopmentConfig()
 		config.EncoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
 	}
+	config.Level = level
 
 	logger, err := config.Build()
 	if err != nil {
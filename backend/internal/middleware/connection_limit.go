@@ -1,11 +1,15 @@
 package middleware
 
 import (
+	"errors"
 	"sync"
 
 	"github.com/gofiber/fiber/v2"
 )
 
+// ErrAtCapacity is the error sent to clients turned away by the limiter
+var ErrAtCapacity = errors.New("Server is at maximum capacity. Please try again later.")
+
 type ConnectionLimiter struct {
 	activeConnections int
 	maxConnections    int
@@ -20,27 +24,47 @@ func NewConnectionLimiter(maxConnections int) *ConnectionLimiter {
 
 func (cl *ConnectionLimiter) Middleware() fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		cl.mu.Lock()
-		if cl.activeConnections >= cl.maxConnections {
-			cl.mu.Unlock()
+		if !cl.Acquire() {
 			return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
-				"error": "Server is at maximum capacity. Please try again later.",
+				"error": ErrAtCapacity.Error(),
 			})
 		}
-		cl.activeConnections++
-		cl.mu.Unlock()
 
 		// Decrement on connection close
-		defer func() {
-			cl.mu.Lock()
-			cl.activeConnections--
-			cl.mu.Unlock()
-		}()
+		defer cl.Release()
 
 		return c.Next()
 	}
 }
 
+// Acquire counts a connection that doesn't pass through the middleware,
+// such as one on the raw TCP or QUIC listener. It reports false when the
+// server is full; otherwise Release must be called once the connection ends.
+func (cl *ConnectionLimiter) Acquire() bool {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	if cl.activeConnections >= cl.maxConnections {
+		return false
+	}
+	cl.activeConnections++
+	return true
+}
+
+// Release frees the slot taken by Acquire
+func (cl *ConnectionLimiter) Release() {
+	cl.mu.Lock()
+	cl.activeConnections--
+	cl.mu.Unlock()
+}
+
+// SetMaxConnections changes the limit at runtime. Connections already
+// accepted are never dropped; a lower limit only rejects new ones.
+func (cl *ConnectionLimiter) SetMaxConnections(maxConnections int) {
+	cl.mu.Lock()
+	cl.maxConnections = maxConnections
+	cl.mu.Unlock()
+}
+
 func (cl *ConnectionLimiter) GetActiveConnections() int {
 	cl.mu.Lock()
 	defer cl.mu.Unlock()
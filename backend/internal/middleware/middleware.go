@@ -1,9 +1,15 @@
 package middleware
 
 import (
+	"net"
+	"strings"
+
+	"nova-speed/backend/internal/clientip"
+
 	"go.uber.org/zap"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/websocket/v2"
 )
 
 func SecurityHeaders() fiber.Handler {
@@ -11,12 +17,113 @@ func SecurityHeaders() fiber.Handler {
 		c.Set("X-Content-Type-Options", "nosniff")
 		c.Set("X-Frame-Options", "DENY")
 		c.Set("X-XSS-Protection", "1; mode=block")
-		c.Set("Strict-Transport-Security", "max-age=31536000; includeSubDomains")
+		// Browsers ignore HSTS on plain HTTP, and sending it there would
+		// pin a host that may not serve HTTPS at all
+		if IsSecure(c) {
+			c.Set("Strict-Transport-Security", "max-age=31536000; includeSubDomains")
+		}
 		c.Set("Content-Security-Policy", "default-src 'self'")
 		return c.Next()
 	}
 }
 
+// WebSocketOrigin rejects WebSocket upgrades whose Origin header is not
+// allowed. Browsers can't be stopped from opening cross-origin sockets by
+// CORS, so the check has to happen here. Requests without an Origin header
+// (CLI and server-side clients) are let through.
+func WebSocketOrigin(allowed func(origin string) bool) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if !websocket.IsWebSocketUpgrade(c) {
+			return c.Next()
+		}
+		origin := c.Get(fiber.HeaderOrigin)
+		if origin != "" && !allowed(origin) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "Origin not allowed",
+			})
+		}
+		return c.Next()
+	}
+}
+
+// Fiber Locals keys set by ClientIP. They are also visible on
+// websocket.Conn via Locals.
+const (
+	ClientIPLocal = "clientIP"
+	SecureLocal   = "secure"
+)
+
+// ClientIP resolves the real client address once per request, honouring
+// forwarding headers only from trusted proxies, and stores it in Locals.
+// It also records whether the client connection used TLS, either directly
+// or as reported by a trusted proxy's X-Forwarded-Proto.
+// The resolver is fetched per request so config reloads apply.
+func ClientIP(resolver func() *clientip.Resolver) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		r := resolver()
+		// Only a local reverse proxy can reach us over a Unix socket. It has
+		// no IP address (fasthttp reports 0.0.0.0), and the resolver trusts
+		// a nil peer.
+		var peer net.IP
+		_, viaUnixSocket := c.Context().RemoteAddr().(*net.UnixAddr)
+		if !viaUnixSocket {
+			peer = c.Context().RemoteIP()
+		}
+		trusted := viaUnixSocket || r.IsTrusted(peer)
+
+		ip := r.ResolveFrom(peer, trusted, func(name string) string {
+			return c.Get(name)
+		})
+		c.Locals(ClientIPLocal, ip)
+
+		secure := c.Context().IsTLS() ||
+			(trusted && strings.EqualFold(c.Get(fiber.HeaderXForwardedProto), "https"))
+		c.Locals(SecureLocal, secure)
+		return c.Next()
+	}
+}
+
+// IsSecure reports whether the client reached us over HTTPS
+func IsSecure(c *fiber.Ctx) bool {
+	if secure, ok := c.Locals(SecureLocal).(bool); ok {
+		return secure
+	}
+	return c.Context().IsTLS()
+}
+
+// HTTPSRedirect permanently redirects plain HTTP requests to the HTTPS
+// listener while target reports ok. /health is exempt so container and
+// load balancer probes keep working over plain HTTP.
+func HTTPSRedirect(target func() (httpsPort string, ok bool)) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		httpsPort, ok := target()
+		if !ok || IsSecure(c) || c.Path() == "/health" {
+			return c.Next()
+		}
+
+		host := string(c.Request().Host())
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if strings.Contains(host, ":") {
+			host = "[" + host + "]" // IPv6 literal
+		}
+		if httpsPort != "443" {
+			host += ":" + httpsPort
+		}
+		return c.Redirect("https://"+host+c.OriginalURL(), fiber.StatusPermanentRedirect)
+	}
+}
+
+// GetClientIP returns the address stored by ClientIP, falling back to the
+// TCP peer address when the middleware did not run
+func GetClientIP(c *fiber.Ctx) string {
+	if ip, ok := c.Locals(ClientIPLocal).(string); ok && ip != "" {
+		return ip
+	}
+	return c.IP()
+}
+
 func RequestLogger(logger *zap.Logger) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		err := c.Next()
@@ -24,7 +131,7 @@ func RequestLogger(logger *zap.Logger) fiber.Handler {
 			zap.String("method", c.Method()),
 			zap.String("path", c.Path()),
 			zap.Int("status", c.Response().StatusCode()),
-			zap.String("ip", c.IP()),
+			zap.String("ip", GetClientIP(c)),
 		)
 		return err
 	}
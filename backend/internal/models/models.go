@@ -4,11 +4,146 @@ import "time"
 
 // TestResult represents the result of a speed test
 type TestResult struct {
+	Type      string  `json:"type"`      // "summary"
 	Latency   float64 `json:"latency"`   // in milliseconds
 	Jitter    float64 `json:"jitter"`    // in milliseconds
 	Download  float64 `json:"download"`  // in Mbps
 	Upload    float64 `json:"upload"`    // in Mbps
 	Timestamp int64   `json:"timestamp"` // Unix timestamp
+
+	// Full results of the phases that ran
+	Phases         []string        `json:"phases"`
+	PingResult     *PingResult     `json:"pingResult,omitempty"`
+	DownloadResult *DownloadResult `json:"downloadResult,omitempty"`
+	UploadResult   *UploadResult   `json:"uploadResult,omitempty"`
+
+	// Stability verdict, once a ping result is available
+	Quality *ConnectionQuality `json:"quality,omitempty"`
+	// Application verdicts for the phases that ran
+	Readiness *Readiness `json:"readiness,omitempty"`
+}
+
+// AddPing records a ping phase result
+func (r *TestResult) AddPing(p *PingResult) {
+	r.addPhase("ping")
+	r.PingResult = p
+	r.Latency = p.Latency
+	r.Jitter = p.Jitter
+}
+
+// AddDownload records a download phase result
+func (r *TestResult) AddDownload(d *DownloadResult) {
+	r.addPhase("download")
+	r.DownloadResult = d
+	r.Download = d.Throughput
+}
+
+// AddUpload records an upload phase result
+func (r *TestResult) AddUpload(u *UploadResult) {
+	r.addPhase("upload")
+	r.UploadResult = u
+	r.Upload = u.Throughput
+}
+
+func (r *TestResult) addPhase(phase string) {
+	for _, p := range r.Phases {
+		if p == phase {
+			return
+		}
+	}
+	r.Phases = append(r.Phases, phase)
+}
+
+// Session aggregates the phases run under one session ID, whether over
+// /ws/test or the individual phase endpoints
+type Session struct {
+	ID        string     `json:"id"`
+	ClientIP  string     `json:"clientIp"`
+	CreatedAt int64      `json:"createdAt"` // Unix timestamp
+	UpdatedAt int64      `json:"updatedAt"` // Unix timestamp
+	Result    TestResult `json:"result"`
+}
+
+// StoredResult is a test as kept in the result store. Geolocation fields
+// are empty when no GeoIP database is available.
+type StoredResult struct {
+	ID          string     `json:"id"`        // Session ID, or a new ID for a stand-alone test
+	Timestamp   int64      `json:"timestamp"` // Unix timestamp of the first result
+	UpdatedAt   int64      `json:"updatedAt"` // Unix timestamp of the latest result
+	ClientIP    string     `json:"clientIp"`
+	Country     string     `json:"country,omitempty"`
+	CountryCode string     `json:"countryCode,omitempty"`
+	City        string     `json:"city,omitempty"`
+	ASN         uint       `json:"asn,omitempty"`
+	ISP         string     `json:"isp,omitempty"`
+	Result      TestResult `json:"result"`
+}
+
+// SessionMessage tells a /ws/test client its session ID
+type SessionMessage struct {
+	Type      string `json:"type"`      // "session"
+	SessionID string `json:"sessionId"`
+}
+
+// TestStartMessage starts a session on /ws/test
+type TestStartMessage struct {
+	Type      string   `json:"type"`      // "start"
+	Phases    []string `json:"phases"`    // Any of "ping", "download", "upload"; empty means all
+	ChunkSize int      `json:"chunkSize"` // Initial download chunk size in bytes (optional)
+	SessionID string   `json:"sessionId"` // Existing session to record into (optional)
+
+	LoadedLatency bool `json:"loadedLatency,omitempty"` // Probe latency during download and upload (optional)
+
+	PingCount    int     `json:"pingCount,omitempty"`    // Ping probes to send (optional)
+	PingInterval float64 `json:"pingInterval,omitempty"` // Delay between ping probes in ms (optional)
+}
+
+// PhaseMessage announces a phase transition during a /ws/test session
+type PhaseMessage struct {
+	Type   string `json:"type"`   // "phase"
+	Phase  string `json:"phase"`  // "ping", "download" or "upload"
+	Status string `json:"status"` // "started" or "completed"
+	Index  int    `json:"index"`  // Position of the phase in the session (0-based)
+	Total  int    `json:"total"`  // Number of phases in the session
+}
+
+// Readiness holds how well the connection suits common applications. Each
+// verdict is omitted when the phases it needs didn't run.
+type Readiness struct {
+	Streaming *StreamingReadiness `json:"streaming,omitempty"`
+	Gaming    *GamingReadiness    `json:"gaming,omitempty"`
+	VideoCall *VideoCallReadiness `json:"videoCall,omitempty"`
+}
+
+// StreamingReadiness is the video streaming verdict
+type StreamingReadiness struct {
+	CanStream1080p     bool     `json:"canStream1080p"`
+	CanStream4K        bool     `json:"canStream4K"`
+	RecommendedQuality string   `json:"recommendedQuality"` // Best quality the connection supports, e.g. "1080p"
+	Qualities          []string `json:"qualities"`          // Every configured quality it supports, best first
+	Score              float64  `json:"score"`              // 0-100
+}
+
+// GamingReadiness is the online gaming verdict
+type GamingReadiness struct {
+	Suitable        bool     `json:"suitable"`
+	Rating          string   `json:"rating"`          // "excellent", "good" or "poor"
+	LatencyScore    float64  `json:"latencyScore"`    // 0-100
+	JitterScore     float64  `json:"jitterScore"`     // 0-100
+	PacketLossScore float64  `json:"packetLossScore"` // 0-100
+	OverallScore    float64  `json:"overallScore"`    // 0-100
+	Recommendations []string `json:"recommendations"` // Recommendation codes, e.g. "elevated_jitter"
+}
+
+// VideoCallReadiness is the video call verdict
+type VideoCallReadiness struct {
+	Suitable        bool     `json:"suitable"`
+	Rating          string   `json:"rating"`          // "excellent", "good" or "poor"
+	UploadScore     float64  `json:"uploadScore"`     // 0-100
+	LatencyScore    float64  `json:"latencyScore"`    // 0-100
+	StabilityScore  float64  `json:"stabilityScore"`  // 0-100, from the connection quality
+	OverallScore    float64  `json:"overallScore"`    // 0-100
+	Recommendations []string `json:"recommendations"` // Recommendation codes, e.g. "low_upload"
 }
 
 // PingMessage represents a ping test message
@@ -16,6 +151,11 @@ type PingMessage struct {
 	Type      string  `json:"type"`      // "ping" or "pong"
 	Timestamp int64   `json:"timestamp"` // Unix timestamp in nanoseconds
 	Sequence  int     `json:"sequence"` // Sequence number
+
+	// Set by the client on pongs, from its own clock, so the server can
+	// estimate clock offset and one-way delays
+	ClientReceive int64 `json:"clientReceive,omitempty"` // When the ping arrived, Unix ns
+	ClientSend    int64 `json:"clientSend,omitempty"`    // When the pong was sent, Unix ns
 }
 
 // PingResult represents the result of a ping test
@@ -28,6 +168,35 @@ type PingResult struct {
 	MinLatency float64 `json:"minLatency"` // Minimum latency in ms
 	MaxLatency float64 `json:"maxLatency"` // Maximum latency in ms
 	Timestamp  int64   `json:"timestamp"`  // Unix timestamp
+
+	Transport string `json:"transport,omitempty"` // TransportWebSocket, TransportQUIC or TransportUDP
+
+	// Distribution of the round-trip times, in ms
+	P50Latency    float64 `json:"p50Latency,omitempty"`    // Median
+	P90Latency    float64 `json:"p90Latency,omitempty"`    // 90th percentile
+	P95Latency    float64 `json:"p95Latency,omitempty"`    // 95th percentile
+	P99Latency    float64 `json:"p99Latency,omitempty"`    // 99th percentile
+	LatencyStdDev float64 `json:"latencyStdDev,omitempty"` // Standard deviation
+	JitterRFC3550 float64 `json:"jitterRfc3550,omitempty"` // Smoothed interarrival jitter (RFC 3550)
+
+	// RTTs holds every probe's round-trip time in ms, indexed by sequence
+	// number; lost probes are null
+	RTTs []*float64 `json:"rtts,omitempty"`
+
+	// Delivery counts. Over UDP, datagrams can really be lost, duplicated
+	// or reordered; over the ping test these show replies the client
+	// dropped, repeated or sent out of order.
+	Sent       int `json:"sent,omitempty"`       // Probes sent
+	Late       int `json:"late,omitempty"`       // Replies that came after the timeout; not counted as lost
+	Duplicates int `json:"duplicates,omitempty"` // Extra copies of replies already received
+	Reordered  int `json:"reordered,omitempty"`  // Replies that arrived after a later probe's reply
+
+	// Reported when the client timestamps its pongs. The offset comes from
+	// the fastest exchange, assuming it was symmetric; one-way delays are
+	// means over all probes, relative to that assumption.
+	ClockOffset       float64 `json:"clockOffset,omitempty"`       // Client clock minus server clock, in ms
+	UpstreamLatency   float64 `json:"upstreamLatency,omitempty"`   // Client to server, in ms
+	DownstreamLatency float64 `json:"downstreamLatency,omitempty"` // Server to client, in ms
 }
 
 // DownloadMessage represents a download test message
@@ -35,6 +204,8 @@ type DownloadMessage struct {
 	Type      string `json:"type"`      // "start", "chunk", "complete"
 	ChunkSize int    `json:"chunkSize"` // Size of chunk in bytes
 	Sequence  int    `json:"sequence"`  // Sequence number
+	SessionID string `json:"sessionId,omitempty"` // Session to record into (start only, optional)
+	Streams   int    `json:"streams,omitempty"`   // Connections in a multi-stream download (start only, optional)
 }
 
 // DownloadResult represents the result of a download test
@@ -47,6 +218,31 @@ type DownloadResult struct {
 	SpeedVariance float64  `json:"speedVariance"` // Variance in speed measurements
 	SpeedSamples []float64 `json:"speedSamples"`  // Speed samples for graphing (optional)
 	Timestamp    int64     `json:"timestamp"`     // Unix timestamp
+
+	Streams       int            `json:"streams"`       // Connections the data was sent over
+	StreamResults []StreamResult `json:"streamResults"` // Per-connection share of the transfer
+
+	Transport string `json:"transport,omitempty"` // TransportWebSocket, TransportHTTP, TransportQUIC or TransportTCP
+
+	LatencyUnderLoad
+}
+
+// LatencyUnderLoad compares latency before and during a download or upload.
+// It is only filled in when the client asked for latency probes and
+// answered them.
+type LatencyUnderLoad struct {
+	IdleLatency      float64 `json:"idleLatency,omitempty"`      // Mean RTT in ms before the transfer
+	LoadedLatency    float64 `json:"loadedLatency,omitempty"`    // Mean RTT in ms during the transfer
+	LatencyIncrease  float64 `json:"latencyIncrease,omitempty"`  // LoadedLatency - IdleLatency, at least 0
+	LatencyProbes    int     `json:"latencyProbes,omitempty"`    // Probes answered during the transfer
+	BufferbloatGrade string  `json:"bufferbloatGrade,omitempty"` // "A" (no bufferbloat) to "F"
+}
+
+// StreamResult is one connection's part of a multi-stream transfer
+type StreamResult struct {
+	Stream     int     `json:"stream"`     // 0-based; stream 0 also carries the progress messages
+	Bytes      int64   `json:"bytes"`      // Bytes sent on this connection
+	Throughput float64 `json:"throughput"` // in Mbps, over the whole test duration
 }
 
 // UploadMessage represents an upload test message
@@ -66,13 +262,80 @@ type UploadResult struct {
 	SpeedVariance float64  `json:"speedVariance"` // Variance in speed measurements
 	SpeedSamples []float64 `json:"speedSamples"`  // Speed samples for graphing (optional)
 	Timestamp    int64     `json:"timestamp"`     // Unix timestamp
+
+	Transport string `json:"transport,omitempty"` // TransportWebSocket, TransportHTTP, TransportQUIC or TransportTCP
+
+	Streams       int            `json:"streams,omitempty"`       // Connections the data was received over (raw TCP only)
+	StreamResults []StreamResult `json:"streamResults,omitempty"` // Per-connection share of the transfer (raw TCP only)
+
+	LatencyUnderLoad
+}
+
+// Transports a test result can be measured over
+const (
+	TransportWebSocket = "websocket"
+	TransportHTTP      = "http"
+	TransportQUIC      = "quic"
+	TransportTCP       = "tcp"
+	TransportUDP       = "udp"
+)
+
+// UDPMessage is a control message of the UDP test on /ws/udp. The client
+// sends "start"; the server answers "ready" with the token and port the
+// datagrams must use, runs the probes and sends a PingResult.
+type UDPMessage struct {
+	Type  string `json:"type"`            // "start" or "ready"
+	Count int    `json:"count,omitempty"` // Probes to send; 0 uses the server default
+	Rate  int    `json:"rate,omitempty"`  // Probes per second; 0 uses the server default
+	Token string `json:"token,omitempty"` // Authorizes the client's datagrams (ready)
+	Port  int    `json:"port,omitempty"`  // UDP port of the test listener (ready)
+}
+
+// TCPMessage is one line of JSON on the raw TCP test port. The client's
+// control connection sends "start"; the server answers "ready" with a token,
+// which each data connection sends back in a "join" line. Once the flows
+// have joined the server sends "running", moves raw bytes for the test's
+// duration, closes the flows and sends the result.
+type TCPMessage struct {
+	Type      string  `json:"type"`                // "start", "ready", "join" or "running"
+	Test      string  `json:"test,omitempty"`      // "download" or "upload" (start)
+	Duration  float64 `json:"duration,omitempty"`  // Seconds (start, ready); 0 uses the server default
+	Flows     int     `json:"flows,omitempty"`     // Parallel data connections (start, ready, running)
+	SessionID string  `json:"sessionId,omitempty"` // Session to record into (start, optional)
+	Token     string  `json:"token,omitempty"`     // Binds the data connections to the test (ready, join)
+}
+
+// StreamStartMessage opens a test on a QUIC stream. The stream then speaks
+// the protocol of the matching WebSocket endpoint, minus the download start
+// message.
+type StreamStartMessage struct {
+	Type      string `json:"type"`      // "start"
+	Test      string `json:"test"`      // "ping", "download" or "upload"
+	ChunkSize int    `json:"chunkSize"` // Initial download chunk size in bytes (optional)
+	SessionID string `json:"sessionId"` // Session to record into (optional)
+
+	LoadedLatency bool `json:"loadedLatency,omitempty"` // Probe latency during a download or upload (optional)
+
+	PingCount    int     `json:"pingCount,omitempty"`    // Ping probes to send (optional)
+	PingInterval float64 `json:"pingInterval,omitempty"` // Delay between ping probes in ms (optional)
 }
 
 // ConnectionQuality represents overall connection quality metrics
 type ConnectionQuality struct {
-	StabilityScore float64 `json:"stabilityScore"` // 0-100 score
-	IsStable       bool    `json:"isStable"`      // Whether connection is stable
-	Recommendations []string `json:"recommendations"` // List of recommendations
+	StabilityScore  float64  `json:"stabilityScore"`  // 0-100 score
+	IsStable        bool     `json:"isStable"`        // Whether connection is stable
+	Recommendations []string `json:"recommendations"` // Recommendation codes, e.g. "high_jitter"
+}
+
+// ProgressMessage reports the server's measurements while a download or
+// upload test runs
+type ProgressMessage struct {
+	Type      string  `json:"type"`      // "progress"
+	Mbps      float64 `json:"mbps"`      // Throughput over the last interval
+	Bytes     int64   `json:"bytes"`     // Bytes transferred so far
+	Elapsed   float64 `json:"elapsed"`   // Seconds since the test started
+	ChunkSize int     `json:"chunkSize"` // Current chunk size in bytes
+	Streams   int     `json:"streams"`   // Parallel streams in use
 }
 
 // ErrorMessage represents an error message
@@ -0,0 +1,211 @@
+// Package origins matches browser Origin headers against allow-list patterns.
+//
+// Supported patterns:
+//
+//	*                              any origin
+//	https://hashmatrix.dev         exact host, default port for the scheme
+//	http://localhost:5173          exact host and port
+//	http://localhost:*             exact host, any port
+//	https://*.hashmatrix.dev       any subdomain (not the apex), default port
+//	http://192.168.0.0/16          any IP in the range, any port
+//	http://192.168.0.0/16:8080     any IP in the range, that port only
+//	http://[fd00::]/8              IPv6 ranges use brackets
+package origins
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const anyPort = -1
+
+// Pattern is a single parsed allow-list entry
+type Pattern struct {
+	raw      string
+	any      bool
+	scheme   string
+	host     string     // Lowercased host, or the parent domain for wildcards
+	wildcard bool       // host matches subdomains of this domain
+	network  *net.IPNet // Set for CIDR patterns
+	port     int        // anyPort, or the port that must match
+}
+
+// ParsePattern parses one allow-list entry
+func ParsePattern(raw string) (*Pattern, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "*" {
+		return &Pattern{raw: raw, any: true}, nil
+	}
+
+	scheme, rest, ok := strings.Cut(raw, "://")
+	if !ok {
+		return nil, fmt.Errorf("missing scheme")
+	}
+	scheme = strings.ToLower(scheme)
+	if scheme != "http" && scheme != "https" {
+		return nil, fmt.Errorf("scheme must be http or https")
+	}
+
+	p := &Pattern{raw: raw, scheme: scheme}
+
+	// Split host, optional /prefix and optional :port
+	var host string
+	if strings.HasPrefix(rest, "[") {
+		end := strings.Index(rest, "]")
+		if end == -1 {
+			return nil, fmt.Errorf("unterminated IPv6 address")
+		}
+		host, rest = rest[1:end], rest[end+1:]
+	} else {
+		end := strings.IndexAny(rest, ":/")
+		if end == -1 {
+			end = len(rest)
+		}
+		host, rest = rest[:end], rest[end:]
+	}
+	if host == "" {
+		return nil, fmt.Errorf("missing host")
+	}
+
+	var prefix string
+	if strings.HasPrefix(rest, "/") {
+		end := strings.Index(rest, ":")
+		if end == -1 {
+			end = len(rest)
+		}
+		prefix, rest = rest[1:end], rest[end:]
+	}
+
+	portSet := false
+	if strings.HasPrefix(rest, ":") {
+		portStr := rest[1:]
+		rest = ""
+		portSet = true
+		if portStr == "*" {
+			p.port = anyPort
+		} else {
+			port, err := strconv.Atoi(portStr)
+			if err != nil || port < 1 || port > 65535 {
+				return nil, fmt.Errorf("invalid port %q", portStr)
+			}
+			p.port = port
+		}
+	}
+	if rest != "" {
+		return nil, fmt.Errorf("unexpected %q after host (paths, queries and fragments are not allowed)", rest)
+	}
+
+	switch {
+	case prefix != "":
+		_, network, err := net.ParseCIDR(host + "/" + prefix)
+		if err != nil {
+			return nil, fmt.Errorf("invalid IP range: %v", err)
+		}
+		p.network = network
+		// A range names many hosts, typically dev servers on arbitrary ports
+		if !portSet {
+			p.port = anyPort
+		}
+	case strings.HasPrefix(host, "*."):
+		p.wildcard = true
+		p.host = strings.ToLower(host[2:])
+		if p.host == "" || strings.Contains(p.host, "*") {
+			return nil, fmt.Errorf("invalid wildcard host %q", host)
+		}
+	default:
+		if strings.Contains(host, "*") {
+			return nil, fmt.Errorf("wildcards are only allowed as the leftmost label (*.example.com)")
+		}
+		p.host = strings.ToLower(host)
+	}
+
+	if !portSet && p.network == nil {
+		p.port = defaultPort(scheme)
+	}
+
+	return p, nil
+}
+
+// String returns the pattern as written in the config
+func (p *Pattern) String() string {
+	return p.raw
+}
+
+// Match reports whether a parsed origin satisfies the pattern
+func (p *Pattern) Match(scheme, host string, port int) bool {
+	if p.any {
+		return true
+	}
+	if scheme != p.scheme {
+		return false
+	}
+	if p.port != anyPort && p.port != port {
+		return false
+	}
+
+	switch {
+	case p.network != nil:
+		ip := net.ParseIP(host)
+		return ip != nil && p.network.Contains(ip)
+	case p.wildcard:
+		return strings.HasSuffix(host, "."+p.host)
+	default:
+		return host == p.host
+	}
+}
+
+// Matcher checks origins against a compiled allow list
+type Matcher struct {
+	patterns []*Pattern
+}
+
+// Compile parses every pattern, returning the first error encountered
+func Compile(patterns []string) (*Matcher, error) {
+	m := &Matcher{}
+	for _, raw := range patterns {
+		p, err := ParsePattern(raw)
+		if err != nil {
+			return nil, fmt.Errorf("origin %q: %w", raw, err)
+		}
+		m.patterns = append(m.patterns, p)
+	}
+	return m, nil
+}
+
+// Allowed reports whether the value of an Origin header is allowed.
+// Malformed and opaque ("null") origins are never allowed.
+func (m *Matcher) Allowed(origin string) bool {
+	if m == nil || origin == "" || origin == "null" {
+		return false
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	scheme := strings.ToLower(u.Scheme)
+	host := strings.ToLower(u.Hostname())
+	port := defaultPort(scheme)
+	if p := u.Port(); p != "" {
+		if port, err = strconv.Atoi(p); err != nil {
+			return false
+		}
+	}
+
+	for _, p := range m.patterns {
+		if p.Match(scheme, host, port) {
+			return true
+		}
+	}
+	return false
+}
+
+func defaultPort(scheme string) int {
+	if scheme == "https" {
+		return 443
+	}
+	return 80
+}
@@ -0,0 +1,98 @@
+package origins
+
+import "testing"
+
+func TestAllowed(t *testing.T) {
+	tests := []struct {
+		pattern string
+		origin  string
+		want    bool
+	}{
+		{"*", "https://anything.example", true},
+		{"*", "null", false},
+
+		{"https://hashmatrix.dev", "https://hashmatrix.dev", true},
+		{"https://hashmatrix.dev", "https://HashMatrix.dev", true},
+		{"https://hashmatrix.dev", "https://hashmatrix.dev:443", true},
+		{"https://hashmatrix.dev", "https://hashmatrix.dev:8443", false},
+		{"https://hashmatrix.dev", "http://hashmatrix.dev", false},
+		{"https://hashmatrix.dev", "https://www.hashmatrix.dev", false},
+		{"HTTPS://HashMatrix.dev", "https://hashmatrix.dev", true},
+
+		{"http://localhost:5173", "http://localhost:5173", true},
+		{"http://localhost:5173", "http://localhost:5174", false},
+		{"http://localhost:5173", "http://localhost", false},
+		{"http://localhost:*", "http://localhost:3000", true},
+		{"http://localhost:*", "http://localhost", true},
+
+		{"https://*.hashmatrix.dev", "https://www.hashmatrix.dev", true},
+		{"https://*.hashmatrix.dev", "https://a.b.hashmatrix.dev", true},
+		{"https://*.hashmatrix.dev", "https://hashmatrix.dev", false},
+		{"https://*.hashmatrix.dev", "https://evilhashmatrix.dev", false},
+		{"https://*.hashmatrix.dev", "https://www.hashmatrix.dev:8443", false},
+
+		{"http://192.168.0.0/16", "http://192.168.1.20:5173", true},
+		{"http://192.168.0.0/16", "http://192.168.1.20", true},
+		{"http://192.168.0.0/16", "http://10.0.0.1:5173", false},
+		{"http://192.168.0.0/16", "http://printer.local", false},
+		{"http://192.168.0.0/16:8080", "http://192.168.1.20:8080", true},
+		{"http://192.168.0.0/16:8080", "http://192.168.1.20:8081", false},
+		{"http://[fd00::]/8", "http://[fd12::1]:3000", true},
+		{"http://[fd00::]/8", "http://[fe80::1]:3000", false},
+
+		{"https://hashmatrix.dev", "", false},
+		{"https://hashmatrix.dev", "null", false},
+		{"https://hashmatrix.dev", "hashmatrix.dev", false},
+		{"http://localhost:*", "http://localhost:port", false},
+	}
+
+	for _, tt := range tests {
+		m, err := Compile([]string{tt.pattern})
+		if err != nil {
+			t.Fatalf("Compile(%q): %v", tt.pattern, err)
+		}
+		if got := m.Allowed(tt.origin); got != tt.want {
+			t.Errorf("%q allows %q = %v, want %v", tt.pattern, tt.origin, got, tt.want)
+		}
+	}
+}
+
+func TestAllowedAnyPattern(t *testing.T) {
+	m, err := Compile([]string{"https://hashmatrix.dev", "http://localhost:*"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, origin := range []string{"https://hashmatrix.dev", "http://localhost:5173"} {
+		if !m.Allowed(origin) {
+			t.Errorf("%q not allowed", origin)
+		}
+	}
+
+	var none *Matcher
+	if none.Allowed("https://hashmatrix.dev") {
+		t.Error("nil matcher allowed an origin")
+	}
+}
+
+func TestParsePatternErrors(t *testing.T) {
+	tests := []string{
+		"hashmatrix.dev",
+		"ftp://hashmatrix.dev",
+		"https://",
+		"https://[fd00::",
+		"https://hashmatrix.dev:0",
+		"https://hashmatrix.dev:70000",
+		"https://hashmatrix.dev:https",
+		"https://hashmatrix.dev/path",
+		"http://192.168.0.0/33",
+		"https://*.",
+		"https://*.*.hashmatrix.dev",
+		"https://www.*.hashmatrix.dev",
+	}
+
+	for _, raw := range tests {
+		if _, err := ParsePattern(raw); err == nil {
+			t.Errorf("ParsePattern(%q) succeeded, want an error", raw)
+		}
+	}
+}
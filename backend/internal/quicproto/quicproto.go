@@ -0,0 +1,137 @@
+// Package quicproto carries the speed test messages over QUIC streams.
+//
+// Each test runs on its own bidirectional stream. A stream is a sequence of
+// frames: a one-byte message type (the WebSocket text and binary opcodes),
+// a big-endian uint32 payload length and the payload. Text frames hold the
+// same JSON messages as the WebSocket endpoints, so the tests and their
+// results are identical over both transports.
+package quicproto
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/quic-go/quic-go"
+)
+
+// ALPN is the application protocol negotiated by the QUIC listener
+const ALPN = "nova-speed"
+
+// Message types, equal to the WebSocket opcodes the tests already use
+const (
+	TextMessage   = 1
+	BinaryMessage = 2
+)
+
+// MaxFrameSize bounds a frame's payload: the largest chunk a test may use
+// plus room for its JSON messages
+const MaxFrameSize = 64*1024*1024 + 64*1024
+
+// NewConfig returns the connection settings shared by the server and the
+// client
+func NewConfig() *quic.Config {
+	return &quic.Config{
+		MaxIdleTimeout:  30 * time.Second,
+		KeepAlivePeriod: 10 * time.Second,
+	}
+}
+
+// Conn is one test stream with WebSocket-style message methods. Writes are
+// serialized; reads must come from a single goroutine.
+type Conn struct {
+	conn   quic.Connection
+	stream quic.Stream
+	reader *bufio.Reader
+	header [5]byte
+
+	writeMu sync.Mutex
+}
+
+// NewConn wraps a stream of conn
+func NewConn(conn quic.Connection, stream quic.Stream) *Conn {
+	return &Conn{
+		conn:   conn,
+		stream: stream,
+		reader: bufio.NewReaderSize(stream, 64*1024),
+	}
+}
+
+// WriteMessage sends one frame
+func (c *Conn) WriteMessage(messageType int, data []byte) error {
+	if len(data) > MaxFrameSize {
+		return fmt.Errorf("frame of %d bytes exceeds the %d byte limit", len(data), MaxFrameSize)
+	}
+	var header [5]byte
+	header[0] = byte(messageType)
+	binary.BigEndian.PutUint32(header[1:], uint32(len(data)))
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if _, err := c.stream.Write(header[:]); err != nil {
+		return err
+	}
+	_, err := c.stream.Write(data)
+	return err
+}
+
+// WriteJSON sends v as a text frame
+func (c *Conn) WriteJSON(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.WriteMessage(TextMessage, data)
+}
+
+// ReadMessage reads the next frame
+func (c *Conn) ReadMessage() (messageType int, data []byte, err error) {
+	if _, err := io.ReadFull(c.reader, c.header[:]); err != nil {
+		return 0, nil, err
+	}
+	messageType = int(c.header[0])
+	if messageType != TextMessage && messageType != BinaryMessage {
+		return 0, nil, fmt.Errorf("unknown frame type %d", messageType)
+	}
+	size := binary.BigEndian.Uint32(c.header[1:])
+	if size > MaxFrameSize {
+		return 0, nil, fmt.Errorf("frame of %d bytes exceeds the %d byte limit", size, MaxFrameSize)
+	}
+	data = make([]byte, size)
+	if _, err := io.ReadFull(c.reader, data); err != nil {
+		return 0, nil, err
+	}
+	return messageType, data, nil
+}
+
+// ReadJSON reads the next frame into v
+func (c *Conn) ReadJSON(v interface{}) error {
+	_, data, err := c.ReadMessage()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	return c.stream.SetReadDeadline(t)
+}
+
+func (c *Conn) SetWriteDeadline(t time.Time) error {
+	return c.stream.SetWriteDeadline(t)
+}
+
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+// Close ends the stream in both directions
+func (c *Conn) Close() error {
+	c.stream.CancelRead(0)
+	return c.stream.Close()
+}
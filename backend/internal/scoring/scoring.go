@@ -0,0 +1,302 @@
+// Package scoring judges how well a connection suits common applications
+// (video streaming, online gaming and video calls) from the results of a
+// test. The thresholds are tables in the server config; the defaults give
+// the same verdicts as the web UI.
+package scoring
+
+import (
+	"math"
+
+	"nova-speed/backend/internal/models"
+)
+
+// Gaming and video call recommendation codes. Clients map them to localized
+// text.
+const (
+	RecHighLatency        = "high_latency"
+	RecElevatedLatency    = "elevated_latency"
+	RecHighJitter         = "high_jitter"
+	RecElevatedJitter     = "elevated_jitter"
+	RecHighPacketLoss     = "high_packet_loss"
+	RecElevatedPacketLoss = "elevated_packet_loss"
+	RecLowDownload        = "low_download"
+	RecLowUpload          = "low_upload"
+	RecVeryLowUpload      = "very_low_upload"
+	RecUnstableConnection = "unstable_connection"
+)
+
+// Ratings given to gaming and video call verdicts
+const (
+	RatingExcellent = "excellent"
+	RatingGood      = "good"
+	RatingPoor      = "poor"
+)
+
+// Thresholds holds the tables every verdict is computed from
+type Thresholds struct {
+	// Streaming qualities, best first. The first tier the connection meets
+	// is recommended, and the last one is the fallback.
+	Streaming []StreamingTier     `yaml:"streaming" toml:"streaming"`
+	Gaming    GamingThresholds    `yaml:"gaming" toml:"gaming"`
+	VideoCall VideoCallThresholds `yaml:"videoCall" toml:"videoCall"`
+
+	// Bufferbloat grades, best first. A transfer gets the first grade whose
+	// limit its latency increase stays under; the last one is the fallback.
+	Bufferbloat []BufferbloatGrade `yaml:"bufferbloat" toml:"bufferbloat"`
+}
+
+// BufferbloatGrade is one grade of latency under load
+type BufferbloatGrade struct {
+	Grade         string  `yaml:"grade" toml:"grade"`                 // e.g. "A"
+	MaxIncreaseMs float64 `yaml:"maxIncreaseMs" toml:"maxIncreaseMs"` // Increase over idle latency; 0 means any
+}
+
+// StreamingTier is one video quality and what it needs
+type StreamingTier struct {
+	Quality         string  `yaml:"quality" toml:"quality"` // e.g. "4K", "1080p"
+	MinDownloadMbps float64 `yaml:"minDownloadMbps" toml:"minDownloadMbps"`
+	MaxLatencyMs    float64 `yaml:"maxLatencyMs" toml:"maxLatencyMs"` // 0 means any latency
+}
+
+// Limits grades one metric where lower is better: a value under Excellent
+// is excellent, from Warn on it is worth a recommendation and from Max on
+// it is unsuitable. The metric's score falls linearly from 100 at zero to
+// 0 at Max.
+type Limits struct {
+	Excellent float64 `yaml:"excellent" toml:"excellent"`
+	Warn      float64 `yaml:"warn" toml:"warn"`
+	Max       float64 `yaml:"max" toml:"max"`
+}
+
+func (l Limits) score(value float64) float64 {
+	return math.Max(0, 100-value/l.Max*100)
+}
+
+// GamingThresholds grade latency and jitter (in ms) and packet loss (in %)
+type GamingThresholds struct {
+	LatencyMs       Limits  `yaml:"latencyMs" toml:"latencyMs"`
+	JitterMs        Limits  `yaml:"jitterMs" toml:"jitterMs"`
+	PacketLoss      Limits  `yaml:"packetLoss" toml:"packetLoss"`
+	MinDownloadMbps float64 `yaml:"minDownloadMbps" toml:"minDownloadMbps"`
+}
+
+// VideoCallThresholds grade upload speed, latency and the connection
+// quality stability score
+type VideoCallThresholds struct {
+	MinUploadMbps       float64 `yaml:"minUploadMbps" toml:"minUploadMbps"`             // Needed for HD calls; full upload score
+	LowUploadMbps       float64 `yaml:"lowUploadMbps" toml:"lowUploadMbps"`             // Below this even SD calls suffer
+	ExcellentUploadMbps float64 `yaml:"excellentUploadMbps" toml:"excellentUploadMbps"` // Needed for an excellent rating
+	MaxLatencyMs        float64 `yaml:"maxLatencyMs" toml:"maxLatencyMs"`
+	ExcellentLatencyMs  float64 `yaml:"excellentLatencyMs" toml:"excellentLatencyMs"`
+	ZeroScoreLatencyMs  float64 `yaml:"zeroScoreLatencyMs" toml:"zeroScoreLatencyMs"` // Latency score falls to 0 here
+	MinStability        float64 `yaml:"minStability" toml:"minStability"`
+}
+
+// Default returns the thresholds the web UI uses
+func Default() Thresholds {
+	return Thresholds{
+		Streaming: []StreamingTier{
+			{Quality: "4K", MinDownloadMbps: 50, MaxLatencyMs: 100},
+			{Quality: "1080p", MinDownloadMbps: 25, MaxLatencyMs: 100},
+			{Quality: "720p", MinDownloadMbps: 5},
+			{Quality: "480p"},
+		},
+		Gaming: GamingThresholds{
+			LatencyMs:       Limits{Excellent: 20, Warn: 50, Max: 100},
+			JitterMs:        Limits{Excellent: 10, Warn: 20, Max: 30},
+			PacketLoss:      Limits{Excellent: 1, Warn: 3, Max: 5},
+			MinDownloadMbps: 3,
+		},
+		VideoCall: VideoCallThresholds{
+			MinUploadMbps:       1.5,
+			LowUploadMbps:       0.5,
+			ExcellentUploadMbps: 2,
+			MaxLatencyMs:        100,
+			ExcellentLatencyMs:  50,
+			ZeroScoreLatencyMs:  200,
+			MinStability:        70,
+		},
+		Bufferbloat: []BufferbloatGrade{
+			{Grade: "A", MaxIncreaseMs: 30},
+			{Grade: "B", MaxIncreaseMs: 60},
+			{Grade: "C", MaxIncreaseMs: 200},
+			{Grade: "D", MaxIncreaseMs: 400},
+			{Grade: "F"},
+		},
+	}
+}
+
+// Assess gives every verdict the phases in r allow: streaming needs a
+// download result, gaming a ping result and video calls a ping and an upload
+// result plus the connection quality, so r.Quality must be assessed first.
+// It returns nil when no verdict is possible.
+func Assess(t Thresholds, r *models.TestResult) *models.Readiness {
+	readiness := &models.Readiness{
+		Streaming: Streaming(t, r.PingResult, r.DownloadResult),
+		Gaming:    Gaming(t, r.PingResult, r.DownloadResult),
+	}
+	if r.Quality != nil {
+		readiness.VideoCall = VideoCall(t, r.PingResult, r.UploadResult, r.Quality.StabilityScore)
+	}
+
+	if readiness.Streaming == nil && readiness.Gaming == nil && readiness.VideoCall == nil {
+		return nil
+	}
+	return readiness
+}
+
+// GradeLatencyUnderLoad fills in the latency increase over idle and the
+// bufferbloat grade of l. It does nothing unless both latencies were
+// measured.
+func GradeLatencyUnderLoad(t Thresholds, l *models.LatencyUnderLoad) {
+	if l.IdleLatency <= 0 || l.LatencyProbes == 0 {
+		return
+	}
+	l.LatencyIncrease = math.Max(0, l.LoadedLatency-l.IdleLatency)
+	for _, g := range t.Bufferbloat {
+		if g.MaxIncreaseMs == 0 || l.LatencyIncrease < g.MaxIncreaseMs {
+			l.BufferbloatGrade = g.Grade
+			return
+		}
+	}
+}
+
+// Streaming picks the best streaming quality the download speed allows.
+// Without a ping result, latency limits are not checked.
+func Streaming(t Thresholds, ping *models.PingResult, download *models.DownloadResult) *models.StreamingReadiness {
+	if download == nil || len(t.Streaming) == 0 {
+		return nil
+	}
+
+	meets := func(tier StreamingTier) bool {
+		if download.Throughput < tier.MinDownloadMbps {
+			return false
+		}
+		return tier.MaxLatencyMs == 0 || ping == nil || ping.Latency < tier.MaxLatencyMs
+	}
+
+	result := &models.StreamingReadiness{
+		RecommendedQuality: t.Streaming[len(t.Streaming)-1].Quality,
+		Qualities:          []string{},
+	}
+	for _, tier := range t.Streaming {
+		if !meets(tier) {
+			continue
+		}
+		if len(result.Qualities) == 0 {
+			result.RecommendedQuality = tier.Quality
+		}
+		result.Qualities = append(result.Qualities, tier.Quality)
+		switch tier.Quality {
+		case "4K":
+			result.CanStream4K = true
+		case "1080p":
+			result.CanStream1080p = true
+		}
+	}
+
+	// Score against the best tier
+	if best := t.Streaming[0].MinDownloadMbps; best > 0 {
+		result.Score = math.Round(math.Min(100, download.Throughput/best*100))
+	} else {
+		result.Score = 100
+	}
+	return result
+}
+
+// Gaming grades latency, jitter and packet loss. Without a download result
+// the download speed is not checked.
+func Gaming(t Thresholds, ping *models.PingResult, download *models.DownloadResult) *models.GamingReadiness {
+	if ping == nil {
+		return nil
+	}
+	g := t.Gaming
+	result := &models.GamingReadiness{
+		LatencyScore:    math.Round(g.LatencyMs.score(ping.Latency)),
+		JitterScore:     math.Round(g.JitterMs.score(ping.Jitter)),
+		PacketLossScore: math.Round(g.PacketLoss.score(ping.PacketLoss)),
+		Recommendations: []string{},
+	}
+	result.OverallScore = math.Round(g.LatencyMs.score(ping.Latency)*0.5 +
+		g.JitterMs.score(ping.Jitter)*0.3 +
+		g.PacketLoss.score(ping.PacketLoss)*0.2)
+
+	add := func(code string) { result.Recommendations = append(result.Recommendations, code) }
+	check := func(value float64, l Limits, high, elevated string) {
+		switch {
+		case value >= l.Max:
+			add(high)
+		case value >= l.Warn:
+			add(elevated)
+		}
+	}
+	check(ping.Latency, g.LatencyMs, RecHighLatency, RecElevatedLatency)
+	check(ping.Jitter, g.JitterMs, RecHighJitter, RecElevatedJitter)
+	check(ping.PacketLoss, g.PacketLoss, RecHighPacketLoss, RecElevatedPacketLoss)
+
+	lowDownload := download != nil && download.Throughput < g.MinDownloadMbps
+	if lowDownload {
+		add(RecLowDownload)
+	}
+
+	result.Suitable = ping.Latency < g.LatencyMs.Max && ping.Jitter < g.JitterMs.Max &&
+		ping.PacketLoss < g.PacketLoss.Max && !lowDownload
+	result.Rating = RatingPoor
+	if result.Suitable {
+		result.Rating = RatingGood
+		if ping.Latency < g.LatencyMs.Excellent && ping.Jitter < g.JitterMs.Excellent &&
+			ping.PacketLoss < g.PacketLoss.Excellent {
+			result.Rating = RatingExcellent
+		}
+	}
+	return result
+}
+
+// VideoCall grades upload speed, latency and the connection's stability
+// score
+func VideoCall(t Thresholds, ping *models.PingResult, upload *models.UploadResult, stability float64) *models.VideoCallReadiness {
+	if ping == nil || upload == nil {
+		return nil
+	}
+	v := t.VideoCall
+	uploadScore := 100.0
+	if v.MinUploadMbps > 0 {
+		uploadScore = math.Min(100, upload.Throughput/v.MinUploadMbps*100)
+	}
+	latencyScore := 0.0
+	if v.ZeroScoreLatencyMs > 0 {
+		latencyScore = math.Max(0, 100-ping.Latency/v.ZeroScoreLatencyMs*100)
+	}
+
+	result := &models.VideoCallReadiness{
+		UploadScore:     math.Round(uploadScore),
+		LatencyScore:    math.Round(latencyScore),
+		StabilityScore:  math.Round(stability),
+		OverallScore:    math.Round(uploadScore*0.4 + latencyScore*0.3 + stability*0.3),
+		Recommendations: []string{},
+	}
+
+	add := func(code string) { result.Recommendations = append(result.Recommendations, code) }
+	switch {
+	case upload.Throughput < v.LowUploadMbps:
+		add(RecVeryLowUpload)
+	case upload.Throughput < v.MinUploadMbps:
+		add(RecLowUpload)
+	}
+	if ping.Latency >= v.MaxLatencyMs {
+		add(RecHighLatency)
+	}
+	if stability < v.MinStability {
+		add(RecUnstableConnection)
+	}
+
+	result.Suitable = upload.Throughput >= v.MinUploadMbps && ping.Latency < v.MaxLatencyMs && stability >= v.MinStability
+	result.Rating = RatingPoor
+	if result.Suitable {
+		result.Rating = RatingGood
+		if upload.Throughput >= v.ExcellentUploadMbps && ping.Latency < v.ExcellentLatencyMs {
+			result.Rating = RatingExcellent
+		}
+	}
+	return result
+}
@@ -0,0 +1,229 @@
+package scoring
+
+import (
+	"fmt"
+	"testing"
+
+	"nova-speed/backend/internal/models"
+)
+
+func TestStreaming(t *testing.T) {
+	tests := []struct {
+		name        string
+		latency     float64 // 0 runs without a ping result
+		download    float64
+		recommended string
+		qualities   []string
+		score       float64
+	}{
+		{name: "every quality", latency: 20, download: 100, recommended: "4K", qualities: []string{"4K", "1080p", "720p", "480p"}, score: 100},
+		{name: "1080p", latency: 20, download: 30, recommended: "1080p", qualities: []string{"1080p", "720p", "480p"}, score: 60},
+		{name: "latency rules out HD", latency: 150, download: 60, recommended: "720p", qualities: []string{"720p", "480p"}, score: 100},
+		{name: "latency unknown", download: 60, recommended: "4K", qualities: []string{"4K", "1080p", "720p", "480p"}, score: 100},
+		{name: "fallback", latency: 20, download: 2, recommended: "480p", qualities: []string{"480p"}, score: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ping *models.PingResult
+			if tt.latency > 0 {
+				ping = &models.PingResult{Latency: tt.latency}
+			}
+			got := Streaming(Default(), ping, &models.DownloadResult{Throughput: tt.download})
+			if got.RecommendedQuality != tt.recommended || fmt.Sprint(got.Qualities) != fmt.Sprint(tt.qualities) || got.Score != tt.score {
+				t.Errorf("Streaming() = %s %v score %v, want %s %v score %v",
+					got.RecommendedQuality, got.Qualities, got.Score, tt.recommended, tt.qualities, tt.score)
+			}
+			if got.CanStream4K != (tt.qualities[0] == "4K") {
+				t.Errorf("CanStream4K = %v", got.CanStream4K)
+			}
+		})
+	}
+
+	if Streaming(Default(), &models.PingResult{}, nil) != nil {
+		t.Error("Streaming() without a download result is not nil")
+	}
+}
+
+func TestGaming(t *testing.T) {
+	tests := []struct {
+		name     string
+		ping     models.PingResult
+		download float64 // 0 runs without a download result
+		rating   string
+		suitable bool
+		overall  float64
+		recs     []string
+	}{
+		{
+			name:     "excellent",
+			ping:     models.PingResult{Latency: 10, Jitter: 5},
+			download: 100,
+			rating:   RatingExcellent,
+			suitable: true,
+			overall:  90,
+			recs:     []string{},
+		},
+		{
+			name:     "elevated",
+			ping:     models.PingResult{Latency: 60, Jitter: 25, PacketLoss: 4},
+			rating:   RatingGood,
+			suitable: true,
+			overall:  29,
+			recs:     []string{RecElevatedLatency, RecElevatedJitter, RecElevatedPacketLoss},
+		},
+		{
+			name:    "unsuitable",
+			ping:    models.PingResult{Latency: 120, Jitter: 40, PacketLoss: 6},
+			rating:  RatingPoor,
+			overall: 0,
+			recs:    []string{RecHighLatency, RecHighJitter, RecHighPacketLoss},
+		},
+		{
+			name:     "slow download",
+			ping:     models.PingResult{Latency: 10, Jitter: 5},
+			download: 2,
+			rating:   RatingPoor,
+			overall:  90,
+			recs:     []string{RecLowDownload},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var download *models.DownloadResult
+			if tt.download > 0 {
+				download = &models.DownloadResult{Throughput: tt.download}
+			}
+			got := Gaming(Default(), &tt.ping, download)
+			if got.Rating != tt.rating || got.Suitable != tt.suitable || got.OverallScore != tt.overall {
+				t.Errorf("Gaming() = %s suitable %v score %v, want %s suitable %v score %v",
+					got.Rating, got.Suitable, got.OverallScore, tt.rating, tt.suitable, tt.overall)
+			}
+			if fmt.Sprint(got.Recommendations) != fmt.Sprint(tt.recs) {
+				t.Errorf("recommendations = %q, want %q", got.Recommendations, tt.recs)
+			}
+		})
+	}
+}
+
+func TestVideoCall(t *testing.T) {
+	tests := []struct {
+		name      string
+		latency   float64
+		upload    float64
+		stability float64
+		rating    string
+		suitable  bool
+		overall   float64
+		recs      []string
+	}{
+		{name: "excellent", latency: 30, upload: 5, stability: 90, rating: RatingExcellent, suitable: true, overall: 93, recs: []string{}},
+		{name: "good", latency: 60, upload: 1.5, stability: 80, rating: RatingGood, suitable: true, overall: 85, recs: []string{}},
+		{
+			name: "poor", latency: 120, upload: 1, stability: 50, rating: RatingPoor, overall: 54,
+			recs: []string{RecLowUpload, RecHighLatency, RecUnstableConnection},
+		},
+		{name: "very low upload", latency: 20, upload: 0.2, stability: 100, rating: RatingPoor, overall: 62, recs: []string{RecVeryLowUpload}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := VideoCall(Default(), &models.PingResult{Latency: tt.latency}, &models.UploadResult{Throughput: tt.upload}, tt.stability)
+			if got.Rating != tt.rating || got.Suitable != tt.suitable || got.OverallScore != tt.overall {
+				t.Errorf("VideoCall() = %s suitable %v score %v, want %s suitable %v score %v",
+					got.Rating, got.Suitable, got.OverallScore, tt.rating, tt.suitable, tt.overall)
+			}
+			if fmt.Sprint(got.Recommendations) != fmt.Sprint(tt.recs) {
+				t.Errorf("recommendations = %q, want %q", got.Recommendations, tt.recs)
+			}
+		})
+	}
+}
+
+func TestAssess(t *testing.T) {
+	ping := &models.PingResult{Latency: 10, Jitter: 2}
+	download := &models.DownloadResult{Throughput: 100}
+	upload := &models.UploadResult{Throughput: 20}
+	quality := &models.ConnectionQuality{StabilityScore: 95}
+
+	tests := []struct {
+		name   string
+		result models.TestResult
+		want   string // Verdicts present: s(treaming), g(aming), v(ideo call)
+	}{
+		{name: "nothing ran", result: models.TestResult{}, want: ""},
+		{name: "ping only", result: models.TestResult{PingResult: ping}, want: "g"},
+		{name: "download only", result: models.TestResult{DownloadResult: download}, want: "s"},
+		{name: "no quality yet", result: models.TestResult{PingResult: ping, UploadResult: upload}, want: "g"},
+		{name: "upload without ping", result: models.TestResult{UploadResult: upload, Quality: quality}, want: ""},
+		{
+			name:   "every phase",
+			result: models.TestResult{PingResult: ping, DownloadResult: download, UploadResult: upload, Quality: quality},
+			want:   "sgv",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Assess(Default(), &tt.result)
+			got := ""
+			if r != nil {
+				if r.Streaming != nil {
+					got += "s"
+				}
+				if r.Gaming != nil {
+					got += "g"
+				}
+				if r.VideoCall != nil {
+					got += "v"
+				}
+				if got == "" {
+					t.Error("Assess() returned an empty verdict instead of nil")
+				}
+			}
+			if got != tt.want {
+				t.Errorf("Assess() verdicts = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGradeLatencyUnderLoad(t *testing.T) {
+	// A catch-all in the middle ends the search there
+	catchAll := Thresholds{Bufferbloat: []BufferbloatGrade{{Grade: "A", MaxIncreaseMs: 10}, {Grade: "B"}, {Grade: "C", MaxIncreaseMs: 50}}}
+	noCatchAll := Thresholds{Bufferbloat: []BufferbloatGrade{{Grade: "A", MaxIncreaseMs: 10}}}
+
+	tests := []struct {
+		name       string
+		thresholds Thresholds
+		idle       float64
+		loaded     float64
+		probes     int
+		increase   float64
+		grade      string
+	}{
+		{name: "A", thresholds: Default(), idle: 20, loaded: 45, probes: 5, increase: 25, grade: "A"},
+		{name: "B at the A limit", thresholds: Default(), idle: 20, loaded: 50, probes: 5, increase: 30, grade: "B"},
+		{name: "C at the B limit", thresholds: Default(), idle: 20, loaded: 80, probes: 5, increase: 60, grade: "C"},
+		{name: "D at the C limit", thresholds: Default(), idle: 20, loaded: 220, probes: 5, increase: 200, grade: "D"},
+		{name: "F at the D limit", thresholds: Default(), idle: 20, loaded: 420, probes: 5, increase: 400, grade: "F"},
+		{name: "F far beyond", thresholds: Default(), idle: 20, loaded: 3000, probes: 5, increase: 2980, grade: "F"},
+		{name: "faster under load", thresholds: Default(), idle: 20, loaded: 15, probes: 5, increase: 0, grade: "A"},
+		{name: "catch-all", thresholds: catchAll, idle: 20, loaded: 45, probes: 5, increase: 25, grade: "B"},
+		{name: "no catch-all", thresholds: noCatchAll, idle: 20, loaded: 45, probes: 5, increase: 25, grade: ""},
+		{name: "no idle latency", thresholds: Default(), loaded: 45, probes: 5},
+		{name: "no probes under load", thresholds: Default(), idle: 20},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := &models.LatencyUnderLoad{IdleLatency: tt.idle, LoadedLatency: tt.loaded, LatencyProbes: tt.probes}
+			GradeLatencyUnderLoad(tt.thresholds, l)
+			if l.LatencyIncrease != tt.increase || l.BufferbloatGrade != tt.grade {
+				t.Errorf("GradeLatencyUnderLoad() = +%vms grade %q, want +%vms grade %q",
+					l.LatencyIncrease, l.BufferbloatGrade, tt.increase, tt.grade)
+			}
+		})
+	}
+}
@@ -0,0 +1,14 @@
+package services
+
+import "time"
+
+// MessageConn is the connection a test runs over: a WebSocket, or a QUIC
+// stream framed by quicproto. Message types are the WebSocket ones
+// (websocket.TextMessage, websocket.BinaryMessage).
+type MessageConn interface {
+	WriteMessage(messageType int, data []byte) error
+	WriteJSON(v interface{}) error
+	ReadMessage() (messageType int, data []byte, err error)
+	ReadJSON(v interface{}) error
+	SetReadDeadline(t time.Time) error
+}
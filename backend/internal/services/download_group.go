@@ -0,0 +1,113 @@
+package services
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"nova-speed/backend/internal/models"
+
+	"github.com/gofiber/websocket/v2"
+)
+
+// StreamJoinTimeout is how long a multi-stream download waits for all of
+// its connections before starting with the ones that arrived
+const StreamJoinTimeout = 5 * time.Second
+
+var (
+	// ErrStreamCountMismatch is returned when a connection asks for a
+	// different number of streams than the download it would join
+	ErrStreamCountMismatch = errors.New("stream count does not match the download being set up for this session")
+	// ErrDownloadRunning is returned when a connection arrives after the
+	// session's multi-stream download has started
+	ErrDownloadRunning = errors.New("a download is already running for this session")
+)
+
+// DownloadGroups binds the connections of multi-stream downloads together
+// by session ID, so one test can send over several TCP connections
+type DownloadGroups struct {
+	mu     sync.Mutex
+	groups map[string]*DownloadGroup
+}
+
+func NewDownloadGroups() *DownloadGroups {
+	return &DownloadGroups{groups: make(map[string]*DownloadGroup)}
+}
+
+// DownloadGroup is one multi-stream download. Stream 0, the first
+// connection to join, runs the test over every connection; the others wait
+// for its result.
+type DownloadGroup struct {
+	sessionID string
+	streams   int
+	conns     []*websocket.Conn
+	started   bool
+	full      chan struct{} // Closed once every stream has joined
+	done      chan struct{} // Closed by Finish
+	result    *models.DownloadResult
+}
+
+// Join adds a connection to the session's download, creating it if this is
+// the first connection, and returns the connection's stream index
+func (g *DownloadGroups) Join(sessionID string, streams int, c *websocket.Conn) (*DownloadGroup, int, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	group, ok := g.groups[sessionID]
+	if !ok {
+		group = &DownloadGroup{
+			sessionID: sessionID,
+			streams:   streams,
+			full:      make(chan struct{}),
+			done:      make(chan struct{}),
+		}
+		g.groups[sessionID] = group
+	}
+	if group.started || len(group.conns) == group.streams {
+		return nil, 0, ErrDownloadRunning
+	}
+	if group.streams != streams {
+		return nil, 0, ErrStreamCountMismatch
+	}
+
+	group.conns = append(group.conns, c)
+	if len(group.conns) == group.streams {
+		close(group.full)
+	}
+	return group, len(group.conns) - 1, nil
+}
+
+// Start waits until every stream has joined or timeout has passed, then
+// closes the group to latecomers and returns the connections to send on,
+// stream 0 first
+func (g *DownloadGroups) Start(group *DownloadGroup, timeout time.Duration) []*websocket.Conn {
+	select {
+	case <-group.full:
+	case <-time.After(timeout):
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	group.started = true
+	return append([]*websocket.Conn(nil), group.conns...)
+}
+
+// Finish publishes the result (nil if the test failed) to the waiting
+// streams and removes the group. Stream 0 must call it exactly once.
+func (g *DownloadGroups) Finish(group *DownloadGroup, result *models.DownloadResult) {
+	g.mu.Lock()
+	group.started = true
+	if g.groups[group.sessionID] == group {
+		delete(g.groups, group.sessionID)
+	}
+	g.mu.Unlock()
+
+	group.result = result
+	close(group.done)
+}
+
+// Wait blocks until stream 0 has finished the test and returns its result
+func (group *DownloadGroup) Wait() *models.DownloadResult {
+	<-group.done
+	return group.result
+}
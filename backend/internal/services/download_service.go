@@ -1,6 +1,7 @@
 package services
 
 import (
+	"math"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -22,213 +23,224 @@ func NewDownloadService(logger *zap.Logger) *DownloadService {
 	}
 }
 
-// RunTest executes a download throughput test with parallel streams
-func (s *DownloadService) RunTest(c *websocket.Conn, initialChunkSize int) *models.DownloadResult {
-	const (
-		testDuration    = 10 * time.Second // 10 second test
-		minChunkSize    = 64 * 1024        // 64 KB minimum
-		maxChunkSize    = 10 * 1024 * 1024 // 10 MB maximum
-		maxParallelStreams = 8
-	)
-
-	if initialChunkSize < minChunkSize {
-		initialChunkSize = minChunkSize
-	}
-	if initialChunkSize > maxChunkSize {
-		initialChunkSize = maxChunkSize
+// RunTest executes a download throughput test, sending on every connection
+// in conns in parallel. Each connection is its own TCP stream with a single
+// writer; progress messages go to conns[0] only.
+// opts.InitialChunkSize may carry the client's requested chunk size; it is
+// clamped to the configured bounds like every other option.
+// If the client stops answering latency probes, the result comes with
+// ErrLatencyProbeTimeout and conns[0] must not be read again.
+func (s *DownloadService) RunTest(conns []MessageConn, opts DownloadOptions) (*models.DownloadResult, error) {
+	opts = opts.Clamped()
+	if len(conns) > opts.MaxStreams {
+		conns = conns[:opts.MaxStreams]
 	}
+	testDuration := opts.Duration
+	minChunkSize := opts.MinChunkSize
+	maxChunkSize := opts.MaxChunkSize
 
 	startTime := time.Now()
 	endTime := startTime.Add(testDuration)
-	firstByteTime := time.Time{}
-	ttfbMeasured := false
 	minTestDuration := 3 * time.Second // Minimum test duration
-	maxTestDuration := testDuration     // Maximum test duration
+	if minTestDuration > testDuration {
+		minTestDuration = testDuration
+	}
 
 	var totalBytes int64
-	var currentThroughput float64
-	var previousThroughput float64
+	var activeStreams int32 // Streams currently sending
+	streamBytes := make([]int64, len(conns))
+	writeMus := make([]sync.Mutex, len(conns)) // One writer per connection at a time
+
+	// Guarded by mu
+	var mu sync.Mutex
+	chunkSize := opts.InitialChunkSize
+	firstByteTime := time.Time{}
 	var speedSamples []float64
 	var recentSamples []float64 // Last 5 samples for stability check
-	var wg sync.WaitGroup
-	var mu sync.Mutex
 
-	// Start with 1 stream, will adapt based on performance
-	numStreams := 1
-	chunkSize := initialChunkSize
-
-	// Channel to signal when to stop
+	// Closed to stop every stream: at the end time or once speed stabilizes
 	stopChan := make(chan struct{})
-	defer close(stopChan)
+	var stopOnce sync.Once
+	stop := func() { stopOnce.Do(func() { close(stopChan) }) }
 
-	// Adaptive streaming: adjust chunk size and streams based on performance
-	ticker := time.NewTicker(1 * time.Second) // Check every second for faster adaptation
-	defer ticker.Stop()
+	// Run one sender per connection
+	streamFunc := func(streamID int, c MessageConn) {
+		atomic.AddInt32(&activeStreams, 1)
+		defer atomic.AddInt32(&activeStreams, -1)
 
-	go func() {
 		for {
 			select {
-			case <-ticker.C:
-				mu.Lock()
-				elapsed := time.Since(startTime)
-				
-				// Only check stability after minimum duration
-				if elapsed >= minTestDuration && len(recentSamples) >= 5 {
-					// Check if speed has stabilized
-					if utils.IsSpeedStable(recentSamples, 5, 0.1) { // 10% max variation
-					s.logger.Info("Speed stabilized, stopping test early",
-						zap.Float64("throughput", currentThroughput),
-						zap.Duration("duration", elapsed),
-					)
-					stopChan <- struct{}{}
-						mu.Unlock()
-						return
-					}
-				}
-				
-				// Adapt chunk size progressively based on speed change
-				speedChange := 0.0
-				if previousThroughput > 0 {
-					speedChange = (currentThroughput - previousThroughput) / previousThroughput
-				}
-				
-				// Use progressive chunk size adjustment
-				newChunkSize := utils.ProgressiveChunkSize(minChunkSize, chunkSize, maxChunkSize, speedChange)
-				newNumStreams := utils.CalculateOptimalParallelStreams(currentThroughput)
-				
-				// Only adapt if significant change
-				if newChunkSize != chunkSize || newNumStreams != numStreams {
-					s.logger.Info("Adapting download parameters",
-						zap.Int("oldChunkSize", chunkSize),
-						zap.Int("newChunkSize", newChunkSize),
-						zap.Int("oldStreams", numStreams),
-						zap.Int("newStreams", newNumStreams),
-						zap.Float64("throughput", currentThroughput),
-						zap.Float64("speedChange", speedChange),
-					)
-					chunkSize = newChunkSize
-					numStreams = newNumStreams
-				}
-				
-				previousThroughput = currentThroughput
-				mu.Unlock()
 			case <-stopChan:
 				return
 			default:
-				if time.Now().After(endTime) {
-					stopChan <- struct{}{}
-					return
-				}
 			}
+			if time.Now().After(endTime) {
+				return
+			}
+
+			mu.Lock()
+			currentChunkSize := chunkSize
+			mu.Unlock()
+
+			// Generate random payload to prevent caching
+			payload, err := utils.GenerateRandomPayload(currentChunkSize)
+			if err != nil {
+				s.logger.Error("Failed to generate payload", zap.Error(err))
+				return
+			}
+
+			// Send binary payload directly (more efficient)
+			writeMus[streamID].Lock()
+			err = c.WriteMessage(websocket.BinaryMessage, payload)
+			writeMus[streamID].Unlock()
+			if err != nil {
+				// The other streams carry on without this one
+				s.logger.Error("Failed to send chunk data", zap.Error(err), zap.Int("stream", streamID))
+				return
+			}
+
+			atomic.AddInt64(&streamBytes[streamID], int64(len(payload)))
+			atomic.AddInt64(&totalBytes, int64(len(payload)))
+
+			// Measure TTFB on the first chunk of any stream
+			mu.Lock()
+			if firstByteTime.IsZero() {
+				firstByteTime = time.Now()
+			}
+			mu.Unlock()
 		}
+	}
+
+	var wg sync.WaitGroup
+	for i, c := range conns {
+		wg.Add(1)
+		go func(i int, c MessageConn) {
+			defer wg.Done()
+			streamFunc(i, c)
+		}(i, c)
+	}
+	streamsDone := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(streamsDone)
 	}()
 
-	// Run parallel download streams
-	streamFunc := func(streamID int) {
-		defer wg.Done()
-		localBytes := int64(0)
-		sequence := 0
+	// Collect speed samples every 500ms for variance and stability, and adapt
+	// the chunk size every second
+	go func() {
+		ticker := time.NewTicker(500 * time.Millisecond)
+		defer ticker.Stop()
 
+		var previousThroughput float64
+		lastAdaptationTime := startTime
 		for {
 			select {
 			case <-stopChan:
 				return
-			default:
-				if time.Now().After(endTime) {
-					return
-				}
+			case <-streamsDone:
+				return
+			case now := <-ticker.C:
+				elapsed := now.Sub(startTime)
+				currentThroughput := utils.CalculateThroughput(atomic.LoadInt64(&totalBytes), elapsed.Seconds())
 
 				mu.Lock()
-				currentChunkSize := chunkSize
-				mu.Unlock()
-
-				// Generate random payload to prevent caching
-				payload, err := utils.GenerateRandomPayload(currentChunkSize)
-				if err != nil {
-					s.logger.Error("Failed to generate payload", zap.Error(err))
-					return
+				speedSamples = append(speedSamples, currentThroughput)
+				recentSamples = append(recentSamples, currentThroughput)
+				if len(recentSamples) > 5 {
+					recentSamples = recentSamples[len(recentSamples)-5:]
 				}
 
-				// Send binary payload directly (more efficient)
-				// The client can track sequence by counting received chunks
-				if err := c.WriteMessage(websocket.BinaryMessage, payload); err != nil {
-					s.logger.Error("Failed to send chunk data", zap.Error(err))
+				// Only check stability after minimum duration
+				if elapsed >= minTestDuration && len(recentSamples) >= 5 &&
+					utils.IsSpeedStable(recentSamples, 5, 0.1) { // 10% max variation
+					mu.Unlock()
+					s.logger.Info("Speed stabilized, stopping test early",
+						zap.Float64("throughput", currentThroughput),
+						zap.Duration("duration", elapsed),
+					)
+					stop()
 					return
 				}
 
-				localBytes += int64(len(payload))
-				sequence++
-
-				// Update total bytes atomically
-				atomic.AddInt64(&totalBytes, int64(len(payload)))
-
-				// Measure TTFB on first chunk
-				if !ttfbMeasured && len(payload) > 0 {
-					mu.Lock()
-					if !ttfbMeasured {
-						firstByteTime = time.Now()
-						ttfbMeasured = true
+				if now.Sub(lastAdaptationTime) >= time.Second {
+					// Adapt chunk size progressively based on speed change
+					speedChange := 0.0
+					if previousThroughput > 0 {
+						speedChange = (currentThroughput - previousThroughput) / previousThroughput
 					}
-					mu.Unlock()
-				}
-
-				// Calculate current throughput periodically and collect samples
-				elapsed := time.Since(startTime).Seconds()
-				if elapsed > 0 {
-					mu.Lock()
-					currentThroughput = utils.CalculateThroughput(atomic.LoadInt64(&totalBytes), elapsed)
-					// Collect speed samples every 500ms for variance calculation
-					if len(speedSamples) == 0 || time.Since(startTime).Milliseconds()%500 < 100 {
-						speedSamples = append(speedSamples, currentThroughput)
-						// Keep last 5 samples for stability check
-						recentSamples = append(recentSamples, currentThroughput)
-						if len(recentSamples) > 5 {
-							recentSamples = recentSamples[len(recentSamples)-5:]
-						}
+					newChunkSize := utils.ProgressiveChunkSize(minChunkSize, chunkSize, maxChunkSize, speedChange)
+					if newChunkSize != chunkSize {
+						s.logger.Info("Adapting download chunk size",
+							zap.Int("oldChunkSize", chunkSize),
+							zap.Int("newChunkSize", newChunkSize),
+							zap.Float64("throughput", currentThroughput),
+							zap.Float64("speedChange", speedChange),
+						)
+						chunkSize = newChunkSize
 					}
-					mu.Unlock()
+					previousThroughput = currentThroughput
+					lastAdaptationTime = now
 				}
+				mu.Unlock()
 			}
 		}
-	}
+	}()
 
-	// Start parallel streams
-	for i := 0; i < numStreams; i++ {
-		wg.Add(1)
-		go streamFunc(i)
-	}
+	stopProgress := startProgress(conns[0], &writeMus[0], s.logger, opts.ProgressInterval, opts.MaxThroughputMbps, func() (int64, int, int) {
+		mu.Lock()
+		defer mu.Unlock()
+		return atomic.LoadInt64(&totalBytes), chunkSize, int(atomic.LoadInt32(&activeStreams))
+	})
 
-	// Wait for test duration or early stop
+	// Latency probes share the first connection; nothing else reads it
+	// during the test
+	probe := startLatencyProbe(conns[0], &writeMus[0], s.logger, opts.LatencyInterval)
+	finishPongs := probe.readPongs(conns[0])
+
+	// Wait for test duration, early stop or every stream failing
 	select {
-	case <-time.After(maxTestDuration):
-		// Maximum duration reached
-		stopChan <- struct{}{}
+	case <-time.After(time.Until(endTime)):
 	case <-stopChan:
-		// Early stop due to stability
-		break
+	case <-streamsDone:
 	}
-
-	// Wait for all streams to finish
-	wg.Wait()
+	stop()
+	<-streamsDone
+	stopProgress()
+	probe.stop(true)
+	err := finishPongs()
 
 	duration := time.Since(startTime).Seconds()
-	
+
 	// Ensure minimum duration for accurate measurement
 	if duration < 0.1 {
 		duration = 0.1
 	}
-	
-	finalThroughput := utils.CalculateThroughput(atomic.LoadInt64(&totalBytes), duration)
-	
+
+	bytes := atomic.LoadInt64(&totalBytes)
+	finalThroughput := utils.CalculateThroughput(bytes, duration)
+
 	// Validate throughput - cap unrealistic values (likely localhost loopback)
-	if finalThroughput > 10000 {
+	if finalThroughput > opts.MaxThroughputMbps {
 		s.logger.Warn("Unrealistic throughput detected, likely localhost loopback",
 			zap.Float64("throughput", finalThroughput),
-			zap.Int64("bytes", atomic.LoadInt64(&totalBytes)),
+			zap.Int64("bytes", bytes),
 			zap.Float64("duration", duration))
-		finalThroughput = 10000
+		finalThroughput = opts.MaxThroughputMbps
 	}
 
+	streamResults := make([]models.StreamResult, len(conns))
+	for i := range conns {
+		n := atomic.LoadInt64(&streamBytes[i])
+		streamResults[i] = models.StreamResult{
+			Stream:     i,
+			Bytes:      n,
+			Throughput: math.Min(utils.CalculateThroughput(n, duration), opts.MaxThroughputMbps),
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
 	// Calculate TTFB
 	var ttfb float64
 	if !firstByteTime.IsZero() {
@@ -240,22 +252,25 @@ func (s *DownloadService) RunTest(c *websocket.Conn, initialChunkSize int) *mode
 
 	s.logger.Info("Download test completed",
 		zap.Float64("throughput", finalThroughput),
-		zap.Int64("bytes", atomic.LoadInt64(&totalBytes)),
+		zap.Int64("bytes", bytes),
 		zap.Float64("duration", duration),
 		zap.Float64("ttfb", ttfb),
 		zap.Float64("speedVariance", speedVariance),
-		zap.Int("streams", numStreams),
+		zap.Int("streams", len(conns)),
 	)
 
-	return &models.DownloadResult{
-		Type:          "result",
-		Throughput:    finalThroughput,
-		Bytes:         atomic.LoadInt64(&totalBytes),
-		Duration:      duration,
-		TTFB:          ttfb,
-		SpeedVariance: speedVariance,
-		SpeedSamples:  speedSamples,
-		Timestamp:     time.Now().Unix(),
+	result := &models.DownloadResult{
+		Type:             "result",
+		Throughput:       finalThroughput,
+		Bytes:            bytes,
+		Duration:         duration,
+		TTFB:             ttfb,
+		SpeedVariance:    speedVariance,
+		SpeedSamples:     speedSamples,
+		Timestamp:        time.Now().Unix(),
+		Streams:          len(conns),
+		StreamResults:    streamResults,
+		LatencyUnderLoad: probe.result(),
 	}
+	return result, err
 }
-
@@ -3,11 +3,9 @@ package services
 import (
 	"fmt"
 	"net"
-	"strings"
 	"sync"
 	"time"
 
-	"github.com/gofiber/fiber/v2"
 	"github.com/oschwald/geoip2-golang"
 	"go.uber.org/zap"
 )
@@ -15,6 +13,8 @@ import (
 type GeolocationService struct {
 	logger     *zap.Logger
 	db         *geoip2.Reader
+	dbPath     string
+	dbMutex    sync.RWMutex
 	cache      map[string]*IPInfo
 	cacheMutex sync.RWMutex
 	cacheTTL   time.Duration
@@ -44,6 +44,7 @@ func NewGeolocationService(logger *zap.Logger, dbPath string) (*GeolocationServi
 	service := &GeolocationService{
 		logger:   logger,
 		db:       db,
+		dbPath:   dbPath,
 		cache:    make(map[string]*IPInfo),
 		cacheTTL: 24 * time.Hour, // Cache for 24 hours
 	}
@@ -55,37 +56,44 @@ func NewGeolocationService(logger *zap.Logger, dbPath string) (*GeolocationServi
 }
 
 func (s *GeolocationService) Close() error {
+	s.dbMutex.Lock()
+	defer s.dbMutex.Unlock()
 	if s.db != nil {
 		return s.db.Close()
 	}
 	return nil
 }
 
-// GetClientIP extracts the real client IP from the request
-func (s *GeolocationService) GetClientIP(c *fiber.Ctx) string {
-	// Try various headers for real IP (useful behind proxies/load balancers)
-	ip := c.Get("X-Real-IP")
-	if ip != "" {
-		return ip
+// Reload switches to the database at dbPath. The new database is opened
+// before the old one is closed, so lookups keep working if it fails to open.
+func (s *GeolocationService) Reload(dbPath string) error {
+	db, err := geoip2.Open(dbPath)
+	if err != nil {
+		return fmt.Errorf("failed to open GeoLite2 database: %w", err)
 	}
 
-	ip = c.Get("X-Forwarded-For")
-	if ip != "" {
-		// X-Forwarded-For can contain multiple IPs separated by commas
-		// Take the first one (original client IP)
-		if idx := strings.Index(ip, ","); idx != -1 {
-			ip = strings.TrimSpace(ip[:idx])
-		}
-		return ip
-	}
+	s.dbMutex.Lock()
+	old := s.db
+	s.db = db
+	s.dbPath = dbPath
+	s.dbMutex.Unlock()
+
+	// Cached lookups came from the old database
+	s.cacheMutex.Lock()
+	s.cache = make(map[string]*IPInfo)
+	s.cacheMutex.Unlock()
 
-	ip = c.Get("CF-Connecting-IP") // Cloudflare
-	if ip != "" {
-		return ip
+	if old != nil {
+		return old.Close()
 	}
+	return nil
+}
 
-	// Fallback to RemoteIP
-	return c.IP()
+// DBPath returns the path of the database currently in use
+func (s *GeolocationService) DBPath() string {
+	s.dbMutex.RLock()
+	defer s.dbMutex.RUnlock()
+	return s.dbPath
 }
 
 // GetIPInfo retrieves geolocation information for an IP address
@@ -112,6 +120,9 @@ func (s *GeolocationService) GetIPInfo(ipStr string) (*IPInfo, error) {
 		ExpiresAt: time.Now().Add(s.cacheTTL),
 	}
 
+	s.dbMutex.RLock()
+	defer s.dbMutex.RUnlock()
+
 	// Lookup City database
 	record, err := s.db.City(ip)
 	if err == nil {
@@ -0,0 +1,173 @@
+package services
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"nova-speed/backend/internal/models"
+	"nova-speed/backend/internal/utils"
+
+	"go.uber.org/zap"
+)
+
+// ErrUploadTooLarge is returned when an HTTP upload body exceeds the limit
+var ErrUploadTooLarge = errors.New("upload exceeds the maximum size")
+
+// HTTPTransferService measures plain HTTP downloads and uploads, for
+// clients that cannot use the WebSocket tests
+type HTTPTransferService struct {
+	logger *zap.Logger
+}
+
+func NewHTTPTransferService(logger *zap.Logger) *HTTPTransferService {
+	return &HTTPTransferService{
+		logger: logger,
+	}
+}
+
+// Download returns a response body of size random bytes, generated
+// opts.InitialChunkSize at a time. done is called with the result when the
+// body is closed: after the last byte was written, or early if the client
+// went away. The clock starts at the first read, once headers are sent.
+func (s *HTTPTransferService) Download(size int64, opts DownloadOptions, done func(*models.DownloadResult)) io.ReadCloser {
+	opts = opts.Clamped()
+	return &randomBody{
+		logger:    s.logger,
+		remaining: size,
+		chunkSize: opts.InitialChunkSize,
+		maxMbps:   opts.MaxThroughputMbps,
+		done:      done,
+	}
+}
+
+// randomBody streams random payloads and measures how fast they are read
+type randomBody struct {
+	logger    *zap.Logger
+	remaining int64
+	chunkSize int
+	maxMbps   float64
+	done      func(*models.DownloadResult)
+
+	chunk     []byte // Unread part of the current payload
+	sent      int64
+	startTime time.Time
+	err       error
+	closeOnce sync.Once
+}
+
+func (b *randomBody) Read(p []byte) (int, error) {
+	if b.err != nil {
+		return 0, b.err
+	}
+	if b.startTime.IsZero() {
+		b.startTime = time.Now()
+	}
+	if len(b.chunk) == 0 {
+		if b.remaining == 0 {
+			return 0, io.EOF
+		}
+		size := int64(b.chunkSize)
+		if size > b.remaining {
+			size = b.remaining
+		}
+		// Fresh random data for every chunk so nothing can be cached or compressed
+		chunk, err := utils.GenerateRandomPayload(int(size))
+		if err != nil {
+			b.err = err
+			return 0, err
+		}
+		b.chunk = chunk
+		b.remaining -= size
+	}
+
+	n := copy(p, b.chunk)
+	b.chunk = b.chunk[n:]
+	b.sent += int64(n)
+	return n, nil
+}
+
+func (b *randomBody) Close() error {
+	b.closeOnce.Do(func() {
+		duration := 0.0
+		if !b.startTime.IsZero() {
+			duration = time.Since(b.startTime).Seconds()
+		}
+		// Ensure minimum duration for accurate measurement
+		if duration < 0.1 {
+			duration = 0.1
+		}
+
+		throughput := utils.CalculateThroughput(b.sent, duration)
+		if throughput > b.maxMbps {
+			throughput = b.maxMbps
+		}
+
+		b.logger.Info("HTTP download completed",
+			zap.Float64("throughput", throughput),
+			zap.Int64("bytes", b.sent),
+			zap.Float64("duration", duration),
+			zap.Bool("complete", b.remaining == 0 && len(b.chunk) == 0),
+		)
+
+		if b.done != nil {
+			b.done(&models.DownloadResult{
+				Type:       "result",
+				Throughput: throughput,
+				Bytes:      b.sent,
+				Duration:   duration,
+				Timestamp:  time.Now().Unix(),
+				Streams:    1,
+				Transport:  models.TransportHTTP,
+			})
+		}
+	})
+	return nil
+}
+
+// Upload reads and discards body, measuring how fast it arrives. It stops
+// with ErrUploadTooLarge once more than maxBytes were read. start is when
+// the server began reading the request, since the first part of the body
+// may already be buffered.
+func (s *HTTPTransferService) Upload(body io.Reader, start time.Time, maxBytes int64, opts UploadOptions) (*models.UploadResult, error) {
+	opts = opts.Clamped()
+
+	bytes, err := io.Copy(io.Discard, io.LimitReader(body, maxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if bytes > maxBytes {
+		return nil, ErrUploadTooLarge
+	}
+
+	duration := time.Since(start).Seconds()
+	// Ensure minimum duration for accurate measurement
+	if duration < 0.1 {
+		duration = 0.1
+	}
+
+	throughput := utils.CalculateThroughput(bytes, duration)
+	if throughput > opts.MaxThroughputMbps {
+		s.logger.Warn("Unrealistic throughput detected, likely localhost loopback",
+			zap.Float64("throughput", throughput),
+			zap.Int64("bytes", bytes),
+			zap.Float64("duration", duration))
+		throughput = opts.MaxThroughputMbps
+	}
+
+	s.logger.Info("HTTP upload completed",
+		zap.Float64("throughput", throughput),
+		zap.Int64("bytes", bytes),
+		zap.Float64("duration", duration),
+	)
+
+	return &models.UploadResult{
+		Type:       "result",
+		Throughput: throughput,
+		Bytes:      bytes,
+		Duration:   duration,
+		Timestamp:  time.Now().Unix(),
+		Transport:  models.TransportHTTP,
+	}, nil
+}
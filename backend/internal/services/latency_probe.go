@@ -0,0 +1,187 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"nova-speed/backend/internal/models"
+	"nova-speed/backend/internal/utils"
+
+	"go.uber.org/zap"
+)
+
+// latencyDrainTimeout bounds the wait for the pongs still in flight when a
+// download ends
+const latencyDrainTimeout = 5 * time.Second
+
+// ErrLatencyProbeTimeout is returned, with the result, by a download whose
+// client stopped answering latency probes
+var ErrLatencyProbeTimeout = errors.New("client stopped answering latency probes")
+
+// latencyProbe measures latency under load: it sends the ping test's ping
+// messages on a connection busy with a transfer and times the client's
+// pongs, which queue behind the test data like any other traffic would.
+// A nil probe does nothing.
+type latencyProbe struct {
+	c       MessageConn
+	writeMu *sync.Mutex
+	logger  *zap.Logger
+
+	mu         sync.Mutex
+	sent       []time.Time
+	rtts       []float64
+	final      int           // Sequence of the final probe; -1 until it is sent
+	answered   chan struct{} // Closed once the final probe is answered
+	answerOnce sync.Once
+
+	done    chan struct{}
+	sending chan struct{} // Closed when the sender has exited
+}
+
+// startLatencyProbe sends a ping every interval until stop is called.
+// writeMu must guard every other write to c, as for startProgress. It
+// returns nil when interval is 0.
+func startLatencyProbe(c MessageConn, writeMu *sync.Mutex, logger *zap.Logger, interval time.Duration) *latencyProbe {
+	if interval <= 0 {
+		return nil
+	}
+
+	p := &latencyProbe{
+		c:        c,
+		writeMu:  writeMu,
+		logger:   logger,
+		final:    -1,
+		answered: make(chan struct{}),
+		done:     make(chan struct{}),
+		sending:  make(chan struct{}),
+	}
+	go func() {
+		defer close(p.sending)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-p.done:
+				return
+			case <-ticker.C:
+				if err := p.send(false); err != nil {
+					logger.Debug("Failed to send latency probe", zap.Error(err))
+					return
+				}
+			}
+		}
+	}()
+	return p
+}
+
+func (p *latencyProbe) send(final bool) error {
+	p.mu.Lock()
+	seq := len(p.sent)
+	p.sent = append(p.sent, time.Now())
+	if final {
+		p.final = seq
+	}
+	p.mu.Unlock()
+
+	msg := models.PingMessage{Type: "ping", Timestamp: models.GetMonotonicTime(), Sequence: seq}
+	p.writeMu.Lock()
+	defer p.writeMu.Unlock()
+	return p.c.WriteJSON(msg)
+}
+
+// handle takes a text message read from the connection and reports whether
+// it was a pong for this probe
+func (p *latencyProbe) handle(data []byte) bool {
+	if p == nil {
+		return false
+	}
+	var msg models.PingMessage
+	if err := json.Unmarshal(data, &msg); err != nil || msg.Type != "pong" {
+		return false
+	}
+	now := time.Now()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if msg.Sequence < 0 || msg.Sequence >= len(p.sent) {
+		return true
+	}
+	p.rtts = append(p.rtts, float64(now.Sub(p.sent[msg.Sequence]).Nanoseconds())/1_000_000.0)
+	if msg.Sequence == p.final {
+		p.answerOnce.Do(func() { close(p.answered) })
+	}
+	return true
+}
+
+// stop ends the probes. With final set it sends one last probe, so the
+// connection's reader can tell from its pong that nothing is in flight.
+func (p *latencyProbe) stop(final bool) {
+	if p == nil {
+		return
+	}
+	close(p.done)
+	<-p.sending
+	if !final {
+		return
+	}
+
+	if err := p.send(true); err != nil {
+		p.logger.Debug("Failed to send final latency probe", zap.Error(err))
+		p.answerOnce.Do(func() { close(p.answered) })
+	}
+}
+
+// result returns the mean RTT of the answered probes and how many there were
+func (p *latencyProbe) result() models.LatencyUnderLoad {
+	if p == nil {
+		return models.LatencyUnderLoad{}
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return models.LatencyUnderLoad{
+		LoadedLatency: utils.CalculateAverageLatency(p.rtts),
+		LatencyProbes: len(p.rtts),
+	}
+}
+
+// readPongs reads c until the probe's final pong arrives, for connections
+// nothing else reads during the test. finish returns once that pong is in.
+// If the client stopped answering, it unblocks the read after
+// latencyDrainTimeout and returns ErrLatencyProbeTimeout: c can't be read
+// after that.
+func (p *latencyProbe) readPongs(c MessageConn) (finish func() error) {
+	if p == nil {
+		return func() error { return nil }
+	}
+
+	readerDone := make(chan struct{})
+	go func() {
+		defer close(readerDone)
+		for {
+			select {
+			case <-p.answered:
+				return
+			default:
+			}
+			_, data, err := c.ReadMessage()
+			if err != nil {
+				return
+			}
+			p.handle(data)
+		}
+	}()
+
+	return func() error {
+		select {
+		case <-readerDone:
+			return nil
+		case <-time.After(latencyDrainTimeout):
+			p.logger.Warn("Client stopped answering latency probes")
+			c.SetReadDeadline(time.Now())
+			<-readerDone
+			return ErrLatencyProbeTimeout
+		}
+	}
+}
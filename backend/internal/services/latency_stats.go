@@ -0,0 +1,35 @@
+package services
+
+import (
+	"math"
+
+	"nova-speed/backend/internal/models"
+	"nova-speed/backend/internal/utils"
+)
+
+// addLatencyStats fills in r's round-trip statistics from rtts, which holds
+// one entry per probe sent in sequence order, in ms, with -1 for probes that
+// got no reply. JitterRFC3550 is left to the caller, since it depends on
+// the order replies arrived in.
+func addLatencyStats(r *models.PingResult, rtts []float64) {
+	var latencies []float64
+	r.RTTs = make([]*float64, len(rtts))
+	for i, rtt := range rtts {
+		if rtt < 0 {
+			continue
+		}
+		latencies = append(latencies, rtt)
+		// Microsecond precision keeps long sample arrays compact
+		rounded := math.Round(rtt*1000) / 1000
+		r.RTTs[i] = &rounded
+	}
+
+	r.Latency = utils.CalculateAverageLatency(latencies)
+	r.Jitter = utils.CalculateJitter(latencies)
+	r.MinLatency, r.MaxLatency = utils.CalculateMinMaxLatency(latencies)
+	r.P50Latency = utils.CalculatePercentile(latencies, 50)
+	r.P90Latency = utils.CalculatePercentile(latencies, 90)
+	r.P95Latency = utils.CalculatePercentile(latencies, 95)
+	r.P99Latency = utils.CalculatePercentile(latencies, 99)
+	r.LatencyStdDev = utils.CalculateStdDev(latencies)
+}
@@ -0,0 +1,165 @@
+package services
+
+import "time"
+
+// Hard safety bounds applied to every test option, whatever the config says.
+// They keep a typo (e.g. a 10 GB chunk or a one-hour test) from taking the
+// server down.
+const (
+	minOptionDuration = 1 * time.Second
+	maxOptionDuration = 60 * time.Second
+
+	minChunkSizeLimit = 1024             // 1 KB
+	maxChunkSizeLimit = 64 * 1024 * 1024 // 64 MB
+
+	maxStreamsLimit = 32
+
+	minPingCount    = 1
+	maxPingCount    = 1000
+	maxPingInterval = 5 * time.Second
+	minPingTimeout  = 100 * time.Millisecond
+	maxPingTimeout  = 30 * time.Second
+
+	minUDPRate = 1
+	maxUDPRate = 1000 // Probes per second
+
+	minThroughputCapMbps = 1
+	maxThroughputCapMbps = 400000 // 400 Gbps
+
+	minProgressInterval = 50 * time.Millisecond
+	maxProgressInterval = 5 * time.Second
+)
+
+// PingOptions tunes a single ping test
+type PingOptions struct {
+	Count    int           // Number of probes
+	Interval time.Duration // Delay between probes
+	Timeout  time.Duration // How long to wait for each pong
+}
+
+// Clamped returns a copy with every field forced into the safety bounds.
+// The count is also capped so the intervals fit in the longest test
+// duration.
+func (o PingOptions) Clamped() PingOptions {
+	o.Interval = clampDuration(o.Interval, 0, maxPingInterval)
+	maxCount := maxPingCount
+	if o.Interval > 0 {
+		maxCount = clampInt(int(maxOptionDuration/o.Interval), minPingCount, maxPingCount)
+	}
+	o.Count = clampInt(o.Count, minPingCount, maxCount)
+	o.Timeout = clampDuration(o.Timeout, minPingTimeout, maxPingTimeout)
+	return o
+}
+
+// UDPOptions tunes a single UDP latency test
+type UDPOptions struct {
+	Count   int           // Number of probes
+	Rate    int           // Probes per second
+	Timeout time.Duration // How long to wait for echoes after the last probe
+}
+
+// Clamped returns a copy with every field forced into the safety bounds.
+// The count is also capped so the probes fit in the longest test duration.
+func (o UDPOptions) Clamped() UDPOptions {
+	o.Rate = clampInt(o.Rate, minUDPRate, maxUDPRate)
+	o.Count = clampInt(o.Count, minPingCount, o.Rate*int(maxOptionDuration/time.Second))
+	o.Timeout = clampDuration(o.Timeout, minPingTimeout, maxPingTimeout)
+	return o
+}
+
+// Interval is the time between probes; o must be clamped
+func (o UDPOptions) Interval() time.Duration {
+	return time.Second / time.Duration(o.Rate)
+}
+
+// DownloadOptions tunes a single download test
+type DownloadOptions struct {
+	Duration          time.Duration
+	InitialChunkSize  int
+	MinChunkSize      int
+	MaxChunkSize      int
+	MaxStreams        int
+	MaxThroughputMbps float64       // Results above this are treated as loopback artefacts
+	ProgressInterval  time.Duration // How often progress messages are sent; 0 sends none
+	LatencyInterval   time.Duration // How often latency probes are sent; 0 sends none
+}
+
+// Clamped returns a copy with every field forced into the safety bounds and
+// the chunk sizes ordered min <= initial <= max
+func (o DownloadOptions) Clamped() DownloadOptions {
+	o.Duration = clampDuration(o.Duration, minOptionDuration, maxOptionDuration)
+	o.MinChunkSize, o.InitialChunkSize, o.MaxChunkSize = clampChunkSizes(o.MinChunkSize, o.InitialChunkSize, o.MaxChunkSize)
+	o.MaxStreams = clampInt(o.MaxStreams, 1, maxStreamsLimit)
+	o.MaxThroughputMbps = clampFloat(o.MaxThroughputMbps, minThroughputCapMbps, maxThroughputCapMbps)
+	o.ProgressInterval = clampProgressInterval(o.ProgressInterval)
+	o.LatencyInterval = clampProgressInterval(o.LatencyInterval)
+	return o
+}
+
+// UploadOptions tunes a single upload test
+type UploadOptions struct {
+	Duration          time.Duration
+	InitialChunkSize  int
+	MinChunkSize      int
+	MaxChunkSize      int
+	MaxThroughputMbps float64       // Results above this are treated as loopback artefacts
+	ProgressInterval  time.Duration // How often progress messages are sent; 0 sends none
+	LatencyInterval   time.Duration // How often latency probes are sent; 0 sends none
+}
+
+// Clamped returns a copy with every field forced into the safety bounds and
+// the chunk sizes ordered min <= initial <= max
+func (o UploadOptions) Clamped() UploadOptions {
+	o.Duration = clampDuration(o.Duration, minOptionDuration, maxOptionDuration)
+	o.MinChunkSize, o.InitialChunkSize, o.MaxChunkSize = clampChunkSizes(o.MinChunkSize, o.InitialChunkSize, o.MaxChunkSize)
+	o.MaxThroughputMbps = clampFloat(o.MaxThroughputMbps, minThroughputCapMbps, maxThroughputCapMbps)
+	o.ProgressInterval = clampProgressInterval(o.ProgressInterval)
+	o.LatencyInterval = clampProgressInterval(o.LatencyInterval)
+	return o
+}
+
+// clampProgressInterval keeps 0 (nothing sent) and bounds anything else; it
+// serves progress and latency probe intervals alike
+func clampProgressInterval(v time.Duration) time.Duration {
+	if v <= 0 {
+		return 0
+	}
+	return clampDuration(v, minProgressInterval, maxProgressInterval)
+}
+
+func clampChunkSizes(min, initial, max int) (int, int, int) {
+	min = clampInt(min, minChunkSizeLimit, maxChunkSizeLimit)
+	max = clampInt(max, min, maxChunkSizeLimit)
+	initial = clampInt(initial, min, max)
+	return min, initial, max
+}
+
+func clampInt(v, min, max int) int {
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
+
+func clampFloat(v, min, max float64) float64 {
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
+
+func clampDuration(v, min, max time.Duration) time.Duration {
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
@@ -0,0 +1,185 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPingOptionsClamped(t *testing.T) {
+	tests := []struct {
+		name string
+		in   PingOptions
+		want PingOptions
+	}{
+		{
+			name: "within bounds",
+			in:   PingOptions{Count: 20, Interval: 50 * time.Millisecond, Timeout: 5 * time.Second},
+			want: PingOptions{Count: 20, Interval: 50 * time.Millisecond, Timeout: 5 * time.Second},
+		},
+		{
+			name: "zero values",
+			in:   PingOptions{},
+			want: PingOptions{Count: 1, Interval: 0, Timeout: 100 * time.Millisecond},
+		},
+		{
+			name: "negative values",
+			in:   PingOptions{Count: -5, Interval: -time.Second, Timeout: -time.Second},
+			want: PingOptions{Count: 1, Interval: 0, Timeout: 100 * time.Millisecond},
+		},
+		{
+			name: "too large",
+			in:   PingOptions{Count: 5000, Interval: time.Minute, Timeout: time.Hour},
+			want: PingOptions{Count: 12, Interval: 5 * time.Second, Timeout: 30 * time.Second},
+		},
+		{
+			name: "count capped by the interval",
+			in:   PingOptions{Count: 1000, Interval: 100 * time.Millisecond, Timeout: time.Second},
+			want: PingOptions{Count: 600, Interval: 100 * time.Millisecond, Timeout: time.Second},
+		},
+		{
+			name: "back to back",
+			in:   PingOptions{Count: 5000, Interval: 0, Timeout: time.Second},
+			want: PingOptions{Count: 1000, Interval: 0, Timeout: time.Second},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.in.Clamped(); got != tt.want {
+				t.Errorf("Clamped() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUDPOptionsClamped(t *testing.T) {
+	tests := []struct {
+		name string
+		in   UDPOptions
+		want UDPOptions
+	}{
+		{
+			name: "within bounds",
+			in:   UDPOptions{Count: 200, Rate: 50, Timeout: time.Second},
+			want: UDPOptions{Count: 200, Rate: 50, Timeout: time.Second},
+		},
+		{
+			name: "zero values",
+			in:   UDPOptions{},
+			want: UDPOptions{Count: 1, Rate: 1, Timeout: 100 * time.Millisecond},
+		},
+		{
+			name: "count capped by the rate",
+			in:   UDPOptions{Count: 1000, Rate: 10, Timeout: time.Second},
+			want: UDPOptions{Count: 600, Rate: 10, Timeout: time.Second},
+		},
+		{
+			name: "too fast",
+			in:   UDPOptions{Count: 100, Rate: 5000, Timeout: time.Minute},
+			want: UDPOptions{Count: 100, Rate: 1000, Timeout: 30 * time.Second},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.in.Clamped()
+			if got != tt.want {
+				t.Errorf("Clamped() = %+v, want %+v", got, tt.want)
+			}
+			if want := time.Second / time.Duration(tt.want.Rate); got.Interval() != want {
+				t.Errorf("Interval() = %v, want %v", got.Interval(), want)
+			}
+		})
+	}
+}
+
+func TestDownloadOptionsClamped(t *testing.T) {
+	tests := []struct {
+		name string
+		in   DownloadOptions
+		want DownloadOptions
+	}{
+		{
+			name: "within bounds",
+			in: DownloadOptions{
+				Duration: 10 * time.Second, InitialChunkSize: 256 << 10, MinChunkSize: 64 << 10, MaxChunkSize: 10 << 20,
+				MaxStreams: 8, MaxThroughputMbps: 10000, ProgressInterval: 250 * time.Millisecond, LatencyInterval: 200 * time.Millisecond,
+			},
+			want: DownloadOptions{
+				Duration: 10 * time.Second, InitialChunkSize: 256 << 10, MinChunkSize: 64 << 10, MaxChunkSize: 10 << 20,
+				MaxStreams: 8, MaxThroughputMbps: 10000, ProgressInterval: 250 * time.Millisecond, LatencyInterval: 200 * time.Millisecond,
+			},
+		},
+		{
+			name: "zero values",
+			in:   DownloadOptions{},
+			want: DownloadOptions{
+				Duration: time.Second, InitialChunkSize: 1024, MinChunkSize: 1024, MaxChunkSize: 1024,
+				MaxStreams: 1, MaxThroughputMbps: 1,
+			},
+		},
+		{
+			name: "too large",
+			in: DownloadOptions{
+				Duration: time.Hour, InitialChunkSize: 1 << 30, MinChunkSize: 1 << 30, MaxChunkSize: 1 << 30,
+				MaxStreams: 100, MaxThroughputMbps: 1e9, ProgressInterval: time.Minute, LatencyInterval: time.Millisecond,
+			},
+			want: DownloadOptions{
+				Duration: time.Minute, InitialChunkSize: 64 << 20, MinChunkSize: 64 << 20, MaxChunkSize: 64 << 20,
+				MaxStreams: 32, MaxThroughputMbps: 400000, ProgressInterval: 5 * time.Second, LatencyInterval: 50 * time.Millisecond,
+			},
+		},
+		{
+			name: "chunk sizes out of order",
+			in: DownloadOptions{
+				Duration: 10 * time.Second, InitialChunkSize: 16 << 10, MinChunkSize: 64 << 10, MaxChunkSize: 32 << 10,
+				MaxStreams: 1, MaxThroughputMbps: 100,
+			},
+			want: DownloadOptions{
+				Duration: 10 * time.Second, InitialChunkSize: 64 << 10, MinChunkSize: 64 << 10, MaxChunkSize: 64 << 10,
+				MaxStreams: 1, MaxThroughputMbps: 100,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.in.Clamped(); got != tt.want {
+				t.Errorf("Clamped() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUploadOptionsClamped(t *testing.T) {
+	tests := []struct {
+		name string
+		in   UploadOptions
+		want UploadOptions
+	}{
+		{
+			name: "zero values",
+			in:   UploadOptions{},
+			want: UploadOptions{Duration: time.Second, InitialChunkSize: 1024, MinChunkSize: 1024, MaxChunkSize: 1024, MaxThroughputMbps: 1},
+		},
+		{
+			name: "initial outside the range",
+			in: UploadOptions{
+				Duration: 5 * time.Second, InitialChunkSize: 1 << 30, MinChunkSize: 64 << 10, MaxChunkSize: 1 << 20,
+				MaxThroughputMbps: 100, ProgressInterval: -time.Second, LatencyInterval: time.Second,
+			},
+			want: UploadOptions{
+				Duration: 5 * time.Second, InitialChunkSize: 1 << 20, MinChunkSize: 64 << 10, MaxChunkSize: 1 << 20,
+				MaxThroughputMbps: 100, ProgressInterval: 0, LatencyInterval: time.Second,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.in.Clamped(); got != tt.want {
+				t.Errorf("Clamped() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
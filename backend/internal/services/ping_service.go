@@ -1,15 +1,20 @@
 package services
 
 import (
+	"errors"
+	"sync"
 	"time"
 
 	"nova-speed/backend/internal/models"
 	"nova-speed/backend/internal/utils"
 
-	"github.com/gofiber/websocket/v2"
 	"go.uber.org/zap"
 )
 
+// ErrMissingPongs is returned, with the result, by a ping test that ended
+// before every pong arrived
+var ErrMissingPongs = errors.New("client did not answer every ping in time")
+
 type PingService struct {
 	logger *zap.Logger
 }
@@ -20,101 +25,171 @@ func NewPingService(logger *zap.Logger) *PingService {
 	}
 }
 
-// RunTest executes a ping/latency/jitter test
-func (s *PingService) RunTest(c *websocket.Conn) *models.PingResult {
-	const (
-		numPackets = 20
-		timeout    = 5 * time.Second
-	)
+// pingProbe is a ping as sent: when, by the monotonic clock for the RTT, and
+// the timestamp it carried
+type pingProbe struct {
+	sendTime  time.Time
+	timestamp int64
+}
 
-	var latencies []float64
+// RunTest executes a ping/latency/jitter test. Probes go out every
+// opts.Interval whether or not earlier ones were answered, and pongs are
+// matched to them by sequence number, so one slow pong delays nothing else.
+// The test ends once every probe is answered, or opts.Timeout after the last
+// one was sent: at most (Count-1)*Interval + Timeout.
+//
+// A pong that comes after its probe's timeout, but before the test ends,
+// counts as late rather than lost. Waiting out the timeout leaves a
+// WebSocket unreadable, so a test missing any pong returns ErrMissingPongs
+// along with its result, and c must not be read again.
+func (s *PingService) RunTest(c MessageConn, opts PingOptions) (*models.PingResult, error) {
+	opts = opts.Clamped()
 	startTime := time.Now()
-	packetsSent := 0
-	packetsReceived := 0
-
-	// Send ping packets and measure latency
-	for i := 0; i < numPackets; i++ {
-		packetsSent++
-		// Record send time using monotonic clock
-		sendTime := time.Now()
-		pingTimestamp := models.GetMonotonicTime()
-		
-		// Send ping
-		pingMsg := models.PingMessage{
-			Type:      "ping",
-			Timestamp: pingTimestamp,
-			Sequence:  i,
-		}
 
-		if err := c.WriteJSON(pingMsg); err != nil {
-			s.logger.Error("Failed to send ping", zap.Error(err), zap.Int("sequence", i))
-			continue
-		}
+	// The sender appends each probe before writing it, so the pong always
+	// finds it
+	var mu sync.Mutex
+	probes := make([]pingProbe, 0, opts.Count)
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		s.sendProbes(c, opts, startTime, &mu, &probes, done)
+	}()
+
+	c.SetReadDeadline(startTime.Add(time.Duration(opts.Count-1)*opts.Interval + opts.Timeout))
+
+	rtts := make([]float64, opts.Count) // -1 until the pong arrives
+	for i := range rtts {
+		rtts[i] = -1
+	}
+	var arrivals []float64 // RTTs in the order the pongs arrived
+	var clockSamples []utils.ClockSample
+	received, late, duplicates, reordered := 0, 0, 0, 0
+	highest := -1
 
-		// Wait for pong with timeout
-		c.SetReadDeadline(time.Now().Add(timeout))
-		
+	for received < opts.Count {
 		var pongMsg models.PingMessage
 		if err := c.ReadJSON(&pongMsg); err != nil {
-			s.logger.Warn("Failed to receive pong", zap.Error(err), zap.Int("sequence", i))
+			s.logger.Debug("Ping test stopped reading", zap.Error(err), zap.Int("received", received))
+			break
+		}
+		receiveTime := time.Now()
+		receiveTimestamp := models.GetMonotonicTime()
+
+		seq := pongMsg.Sequence
+		mu.Lock()
+		known := pongMsg.Type == "pong" && seq >= 0 && seq < len(probes)
+		var probe pingProbe
+		if known {
+			probe = probes[seq]
+		}
+		mu.Unlock()
+		// The echoed timestamp isn't checked: JavaScript clients round it
+		if !known {
+			s.logger.Warn("Invalid pong message", zap.Int("sequence", seq))
 			continue
 		}
-
-		// Verify it's a pong response
-		if pongMsg.Type != "pong" || pongMsg.Sequence != i {
-			s.logger.Warn("Invalid pong message", zap.Int("sequence", i))
+		if rtts[seq] >= 0 {
+			duplicates++
 			continue
 		}
 
-		// Calculate latency using wall clock time (more accurate for network)
-		// We measure RTT (Round Trip Time) from when we sent to when we received
-		rtt := time.Since(sendTime)
-		latencyMs := float64(rtt.Nanoseconds()) / 1_000_000.0 // Convert to milliseconds
-		
-		// Validate latency is reasonable (filter out unrealistic values)
-		// Localhost should be < 10ms, normal network < 500ms
-		if latencyMs < 0 || latencyMs > 1000 {
-			s.logger.Warn("Invalid latency measurement, skipping", 
-				zap.Float64("latency", latencyMs),
-				zap.Int("sequence", i))
-			continue
+		rtt := receiveTime.Sub(probe.sendTime)
+		latencyMs := float64(rtt.Nanoseconds()) / 1_000_000.0
+		rtts[seq] = latencyMs
+		arrivals = append(arrivals, latencyMs)
+		received++
+		if rtt > opts.Timeout {
+			late++
+		}
+		if seq < highest {
+			reordered++
+		} else {
+			highest = seq
 		}
 
-		latencies = append(latencies, latencyMs)
-		packetsReceived++
+		// Clients that timestamp their pongs allow an NTP-style exchange
+		if pongMsg.ClientReceive != 0 && pongMsg.ClientSend != 0 {
+			clockSamples = append(clockSamples, utils.ClockSample{
+				ServerSend:    probe.timestamp,
+				ClientReceive: pongMsg.ClientReceive,
+				ClientSend:    pongMsg.ClientSend,
+				ServerReceive: receiveTimestamp,
+			})
+		}
+	}
 
-		// Small delay between packets
-		time.Sleep(50 * time.Millisecond)
+	close(done)
+	wg.Wait()
+	var err error
+	if received == opts.Count {
+		c.SetReadDeadline(time.Time{})
+	} else {
+		err = ErrMissingPongs
 	}
+	sent := len(probes)
 
 	// Calculate results
-	avgLatency := utils.CalculateAverageLatency(latencies)
-	jitter := utils.CalculateJitter(latencies)
-	packetLoss := utils.CalculatePacketLoss(packetsSent, packetsReceived)
-	minLatency, maxLatency := utils.CalculateMinMaxLatency(latencies)
-
-	duration := time.Since(startTime).Seconds()
+	result := &models.PingResult{
+		Type:          "result",
+		Packets:       received,
+		PacketLoss:    utils.CalculatePacketLoss(sent, received),
+		Timestamp:     time.Now().Unix(),
+		JitterRFC3550: utils.CalculateRFC3550Jitter(arrivals),
+		Sent:          sent,
+		Late:          late,
+		Duplicates:    duplicates,
+		Reordered:     reordered,
+	}
+	addLatencyStats(result, rtts[:sent])
+	result.ClockOffset, result.UpstreamLatency, result.DownstreamLatency, _ = utils.OneWayDelays(clockSamples)
 
 	s.logger.Info("Ping test completed",
-		zap.Float64("avgLatency", avgLatency),
-		zap.Float64("jitter", jitter),
-		zap.Float64("packetLoss", packetLoss),
-		zap.Float64("minLatency", minLatency),
-		zap.Float64("maxLatency", maxLatency),
-		zap.Int("packetsSent", packetsSent),
-		zap.Int("packetsReceived", packetsReceived),
-		zap.Float64("duration", duration),
+		zap.Float64("avgLatency", result.Latency),
+		zap.Float64("jitter", result.Jitter),
+		zap.Float64("packetLoss", result.PacketLoss),
+		zap.Float64("minLatency", result.MinLatency),
+		zap.Float64("maxLatency", result.MaxLatency),
+		zap.Float64("p99Latency", result.P99Latency),
+		zap.Float64("clockOffset", result.ClockOffset),
+		zap.Float64("upstreamLatency", result.UpstreamLatency),
+		zap.Float64("downstreamLatency", result.DownstreamLatency),
+		zap.Int("packetsSent", sent),
+		zap.Int("packetsReceived", received),
+		zap.Int("late", late),
+		zap.Int("reordered", reordered),
+		zap.Float64("duration", time.Since(startTime).Seconds()),
 	)
 
-	return &models.PingResult{
-		Type:       "result",
-		Latency:    avgLatency,
-		Jitter:     jitter,
-		Packets:    packetsReceived,
-		PacketLoss: packetLoss,
-		MinLatency: minLatency,
-		MaxLatency: maxLatency,
-		Timestamp:  time.Now().Unix(),
-	}
+	return result, err
 }
 
+// sendProbes sends opts.Count pings on a fixed schedule from start, until
+// done is closed
+func (s *PingService) sendProbes(c MessageConn, opts PingOptions, start time.Time, mu *sync.Mutex, probes *[]pingProbe, done <-chan struct{}) {
+	for i := 0; i < opts.Count; i++ {
+		// Schedule from the start so slow writes don't stretch the test
+		select {
+		case <-done:
+			return
+		case <-time.After(time.Until(start.Add(time.Duration(i) * opts.Interval))):
+		}
+
+		probe := pingProbe{sendTime: time.Now(), timestamp: models.GetMonotonicTime()}
+		mu.Lock()
+		*probes = append(*probes, probe)
+		mu.Unlock()
+
+		pingMsg := models.PingMessage{
+			Type:      "ping",
+			Timestamp: probe.timestamp,
+			Sequence:  i,
+		}
+		if err := c.WriteJSON(pingMsg); err != nil {
+			s.logger.Error("Failed to send ping", zap.Error(err), zap.Int("sequence", i))
+			return
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"nova-speed/backend/internal/models"
+
+	"go.uber.org/zap"
+)
+
+// scriptedPong answers the ping with sequence seq, delay after it was sent
+type scriptedPong struct {
+	seq   int
+	delay time.Duration
+}
+
+// scriptedConn is a ping client that answers in the order of its script.
+// Once the script runs out, reads block until the read deadline.
+type scriptedConn struct {
+	pongs []scriptedPong
+	sent  chan int // Sequence numbers of the pings written
+
+	mu       sync.Mutex
+	deadline time.Time
+
+	pinged map[int]time.Time // When each ping was seen; reader only
+}
+
+func newScriptedConn(count int, pongs ...scriptedPong) *scriptedConn {
+	return &scriptedConn{
+		pongs:  pongs,
+		sent:   make(chan int, count),
+		pinged: make(map[int]time.Time),
+	}
+}
+
+func (c *scriptedConn) WriteMessage(int, []byte) error {
+	return errors.New("unexpected message")
+}
+
+func (c *scriptedConn) WriteJSON(v interface{}) error {
+	c.sent <- v.(models.PingMessage).Sequence
+	return nil
+}
+
+func (c *scriptedConn) ReadMessage() (int, []byte, error) {
+	return 0, nil, errors.New("unexpected read")
+}
+
+func (c *scriptedConn) ReadJSON(v interface{}) error {
+	if len(c.pongs) == 0 {
+		return c.waitUntil(time.Time{})
+	}
+	pong := c.pongs[0]
+	c.pongs = c.pongs[1:]
+
+	// Wait for the ping, then for the pong's delay
+	for {
+		if sentAt, ok := c.pinged[pong.seq]; ok {
+			if err := c.waitUntil(sentAt.Add(pong.delay)); err != nil {
+				return err
+			}
+			break
+		}
+		deadline := c.readDeadline()
+		select {
+		case seq := <-c.sent:
+			c.pinged[seq] = time.Now()
+		case <-time.After(time.Until(deadline)):
+			return os.ErrDeadlineExceeded
+		}
+	}
+
+	*v.(*models.PingMessage) = models.PingMessage{Type: "pong", Sequence: pong.seq}
+	return nil
+}
+
+// waitUntil sleeps until t, or forever if t is zero, and fails if the read
+// deadline comes first
+func (c *scriptedConn) waitUntil(t time.Time) error {
+	deadline := c.readDeadline()
+	if t.IsZero() || deadline.Before(t) {
+		time.Sleep(time.Until(deadline))
+		return os.ErrDeadlineExceeded
+	}
+	time.Sleep(time.Until(t))
+	return nil
+}
+
+func (c *scriptedConn) readDeadline() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.deadline
+}
+
+func (c *scriptedConn) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.deadline = t
+	return nil
+}
+
+func TestPingServiceRunTest(t *testing.T) {
+	// Probes at 0, 50 and 100ms; the test ends 100ms after the last
+	opts := PingOptions{Count: 3, Interval: 50 * time.Millisecond, Timeout: 100 * time.Millisecond}
+	const fast = 5 * time.Millisecond
+
+	tests := []struct {
+		name       string
+		pongs      []scriptedPong
+		late       int
+		reordered  int
+		duplicates int
+		lost       []int // Sequence numbers without an RTT
+		err        error
+	}{
+		{
+			name:  "in order",
+			pongs: []scriptedPong{{0, fast}, {1, fast}, {2, fast}},
+		},
+		{
+			name:      "out of order",
+			pongs:     []scriptedPong{{1, fast}, {0, fast}, {2, fast}},
+			reordered: 1,
+		},
+		{
+			name:       "duplicate",
+			pongs:      []scriptedPong{{0, fast}, {0, fast}, {1, fast}, {2, fast}},
+			duplicates: 1,
+		},
+		{
+			// Answered 130ms after its ping, before the test ends at 200ms
+			name:  "late",
+			pongs: []scriptedPong{{0, 130 * time.Millisecond}, {1, fast}, {2, fast}},
+			late:  1,
+		},
+		{
+			name:  "lost",
+			pongs: []scriptedPong{{0, fast}, {2, fast}},
+			lost:  []int{1},
+			err:   ErrMissingPongs,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := newScriptedConn(opts.Count, tt.pongs...)
+			result, err := NewPingService(zap.NewNop()).RunTest(conn, opts)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("RunTest() error = %v, want %v", err, tt.err)
+			}
+
+			if result.Late != tt.late || result.Reordered != tt.reordered || result.Duplicates != tt.duplicates {
+				t.Errorf("late/reordered/duplicates = %d/%d/%d, want %d/%d/%d",
+					result.Late, result.Reordered, result.Duplicates, tt.late, tt.reordered, tt.duplicates)
+			}
+			if result.Sent != opts.Count {
+				t.Errorf("Sent = %d, want %d", result.Sent, opts.Count)
+			}
+			if want := float64(len(tt.lost)) / float64(opts.Count) * 100; result.PacketLoss != want {
+				t.Errorf("PacketLoss = %v, want %v", result.PacketLoss, want)
+			}
+
+			var lost []int
+			for seq, rtt := range result.RTTs {
+				if rtt == nil {
+					lost = append(lost, seq)
+				}
+			}
+			if len(result.RTTs) != opts.Count || fmt.Sprint(lost) != fmt.Sprint(tt.lost) {
+				t.Errorf("RTTs = %d, lost %v, want %d, lost %v", len(result.RTTs), lost, opts.Count, tt.lost)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package services
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"nova-speed/backend/internal/models"
+	"nova-speed/backend/internal/utils"
+
+	"go.uber.org/zap"
+)
+
+// progressSnapshot returns a running test's totals for a progress message
+type progressSnapshot func() (bytes int64, chunkSize, streams int)
+
+// startProgress sends a progress message every interval until the returned
+// stop function is called; stop returns once the last message is written.
+// writeMu must guard every other write to c during the test, since a
+// websocket connection allows only one writer at a time. Mbps is measured
+// over each interval and capped at maxMbps like the final result.
+func startProgress(c MessageConn, writeMu *sync.Mutex, logger *zap.Logger, interval time.Duration, maxMbps float64, snapshot progressSnapshot) (stop func()) {
+	if interval <= 0 {
+		return func() {}
+	}
+
+	start := time.Now()
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		var lastBytes int64
+		lastTime := start
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-ticker.C:
+				bytes, chunkSize, streams := snapshot()
+				msg := models.ProgressMessage{
+					Type:      "progress",
+					Mbps:      math.Min(utils.CalculateThroughput(bytes-lastBytes, now.Sub(lastTime).Seconds()), maxMbps),
+					Bytes:     bytes,
+					Elapsed:   now.Sub(start).Seconds(),
+					ChunkSize: chunkSize,
+					Streams:   streams,
+				}
+				lastBytes, lastTime = bytes, now
+
+				writeMu.Lock()
+				err := c.WriteJSON(msg)
+				writeMu.Unlock()
+				if err != nil {
+					logger.Debug("Failed to send progress message", zap.Error(err))
+					return
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
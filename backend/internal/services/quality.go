@@ -0,0 +1,140 @@
+package services
+
+import (
+	"math"
+
+	"nova-speed/backend/internal/models"
+	"nova-speed/backend/internal/utils"
+)
+
+// Recommendation codes returned in ConnectionQuality. Clients map them to
+// localized text; the web UI shows the same verdicts.
+const (
+	RecHighPacketLoss       = "high_packet_loss"       // Loss above 5%
+	RecPacketLoss           = "packet_loss"            // Loss above 1%
+	RecVeryHighJitter       = "very_high_jitter"       // Jitter above 50ms
+	RecHighJitter           = "high_jitter"            // Jitter above 20ms
+	RecHighLatency          = "high_latency"           // Latency above 200ms
+	RecVeryUnstableDownload = "very_unstable_download" // Download CV above 50%
+	RecUnstableDownload     = "unstable_download"      // Download CV above 20%
+	RecVeryUnstableUpload   = "very_unstable_upload"   // Upload CV above 50%
+	RecUnstableUpload       = "unstable_upload"        // Upload CV above 20%
+	RecVeryHighTTFB         = "very_high_ttfb"         // TTFB above 1000ms
+	RecHighTTFB             = "high_ttfb"              // TTFB above 500ms
+	RecExcellentConnection  = "excellent_connection"   // Stable with latency below 30ms
+	RecStableConnection     = "stable_connection"
+	RecStreaming4K          = "streaming_4k" // Download of 25 Mbps or more
+	RecStreamingHD          = "streaming_hd" // Download of 5 Mbps or more
+	RecLowDownload          = "low_download" // Download below 3 Mbps
+	RecGamingExcellent      = "gaming_excellent"
+	RecGamingGood           = "gaming_good"
+	RecGamingPoor           = "gaming_poor"
+	RecVideoCalls           = "video_calls"
+	RecLowUpload            = "low_upload" // Upload below 1 Mbps
+)
+
+// AssessConnectionQuality scores the phases recorded in a result the same
+// way the web UI does. Checks for phases that didn't run are skipped, and
+// without a ping result there's nothing to judge stability by, so it
+// returns nil.
+func AssessConnectionQuality(r *models.TestResult) *models.ConnectionQuality {
+	ping := r.PingResult
+	if ping == nil {
+		return nil
+	}
+
+	recs := []string{}
+	add := func(code string) { recs = append(recs, code) }
+
+	switch {
+	case ping.PacketLoss > 5:
+		add(RecHighPacketLoss)
+	case ping.PacketLoss > 1:
+		add(RecPacketLoss)
+	}
+	switch {
+	case ping.Jitter > 50:
+		add(RecVeryHighJitter)
+	case ping.Jitter > 20:
+		add(RecHighJitter)
+	}
+	if ping.Latency > 200 {
+		add(RecHighLatency)
+	}
+
+	var speedCVs []float64
+	var ttfb float64
+	if d := r.DownloadResult; d != nil {
+		cv := utils.CoefficientOfVariation(d.SpeedVariance, d.Throughput)
+		speedCVs = append(speedCVs, cv)
+		switch {
+		case cv > 50:
+			add(RecVeryUnstableDownload)
+		case cv > 20:
+			add(RecUnstableDownload)
+		}
+
+		ttfb = d.TTFB
+		switch {
+		case ttfb > 1000:
+			add(RecVeryHighTTFB)
+		case ttfb > 500:
+			add(RecHighTTFB)
+		}
+	}
+	if u := r.UploadResult; u != nil {
+		cv := utils.CoefficientOfVariation(u.SpeedVariance, u.Throughput)
+		speedCVs = append(speedCVs, cv)
+		switch {
+		case cv > 50:
+			add(RecVeryUnstableUpload)
+		case cv > 20:
+			add(RecUnstableUpload)
+		}
+	}
+
+	score := utils.CalculateStabilityScore(ping.PacketLoss, ping.Jitter, ping.Latency, speedCVs, ttfb)
+	isStable := score >= 70 && ping.PacketLoss < 2 && ping.Jitter < 30
+
+	switch {
+	case isStable && ping.Latency < 30:
+		add(RecExcellentConnection)
+	case isStable:
+		add(RecStableConnection)
+	}
+
+	if d := r.DownloadResult; d != nil {
+		switch {
+		case d.Throughput >= 25:
+			add(RecStreaming4K)
+		case d.Throughput >= 5:
+			add(RecStreamingHD)
+		case d.Throughput < 3:
+			add(RecLowDownload)
+		}
+	}
+
+	switch {
+	case ping.Latency < 20 && ping.Jitter < 10 && ping.PacketLoss < 1:
+		add(RecGamingExcellent)
+	case ping.Latency < 50 && ping.Jitter < 20:
+		add(RecGamingGood)
+	default:
+		add(RecGamingPoor)
+	}
+
+	if u := r.UploadResult; u != nil {
+		switch {
+		case u.Throughput >= 1.5 && ping.Latency < 100 && ping.Jitter < 30:
+			add(RecVideoCalls)
+		case u.Throughput < 1:
+			add(RecLowUpload)
+		}
+	}
+
+	return &models.ConnectionQuality{
+		StabilityScore:  math.Round(score),
+		IsStable:        isStable,
+		Recommendations: recs,
+	}
+}
@@ -0,0 +1,99 @@
+package services
+
+import (
+	"fmt"
+	"testing"
+
+	"nova-speed/backend/internal/models"
+)
+
+func TestAssessConnectionQuality(t *testing.T) {
+	tests := []struct {
+		name     string
+		ping     *models.PingResult
+		download *models.DownloadResult
+		upload   *models.UploadResult
+		score    float64
+		stable   bool
+		recs     []string
+	}{
+		{
+			name:   "ping only",
+			ping:   &models.PingResult{Latency: 10, Jitter: 2},
+			score:  100,
+			stable: true,
+			recs:   []string{RecExcellentConnection, RecGamingExcellent},
+		},
+		{
+			name:     "every phase",
+			ping:     &models.PingResult{Latency: 10, Jitter: 2},
+			download: &models.DownloadResult{Throughput: 100, TTFB: 100},
+			upload:   &models.UploadResult{Throughput: 20},
+			score:    100,
+			stable:   true,
+			recs:     []string{RecExcellentConnection, RecStreaming4K, RecGamingExcellent, RecVideoCalls},
+		},
+		{
+			// Download CV 60% (-8), TTFB 700ms (-4), upload CV 30% (-2)
+			name:     "unsteady transfers",
+			ping:     &models.PingResult{Latency: 40, Jitter: 5},
+			download: &models.DownloadResult{Throughput: 10, SpeedVariance: 36, TTFB: 700},
+			upload:   &models.UploadResult{Throughput: 10, SpeedVariance: 9},
+			score:    86,
+			stable:   true,
+			recs: []string{
+				RecVeryUnstableDownload, RecHighTTFB, RecUnstableUpload,
+				RecStableConnection, RecStreamingHD, RecGamingGood, RecVideoCalls,
+			},
+		},
+		{
+			// Loss -40, jitter -8, latency -15, TTFB -10 (capped)
+			name:     "poor",
+			ping:     &models.PingResult{Latency: 250, Jitter: 60, PacketLoss: 8},
+			download: &models.DownloadResult{Throughput: 2, TTFB: 1500},
+			upload:   &models.UploadResult{Throughput: 0.5},
+			score:    27,
+			recs: []string{
+				RecHighPacketLoss, RecVeryHighJitter, RecHighLatency, RecVeryHighTTFB,
+				RecLowDownload, RecGamingPoor, RecLowUpload,
+			},
+		},
+		{
+			// Loss -7.5, jitter -1
+			name:   "some loss",
+			ping:   &models.PingResult{Latency: 20, Jitter: 25, PacketLoss: 1.5},
+			score:  92,
+			stable: true,
+			recs:   []string{RecPacketLoss, RecHighJitter, RecExcellentConnection, RecGamingPoor},
+		},
+		{
+			// High score, but too much jitter to be stable; the speeds sit
+			// between the recommendation thresholds
+			name:     "jittery",
+			ping:     &models.PingResult{Latency: 20, Jitter: 35},
+			download: &models.DownloadResult{Throughput: 4},
+			upload:   &models.UploadResult{Throughput: 1.2},
+			score:    97,
+			recs:     []string{RecHighJitter, RecGamingPoor},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := AssessConnectionQuality(&models.TestResult{PingResult: tt.ping, DownloadResult: tt.download, UploadResult: tt.upload})
+			if got.StabilityScore != tt.score || got.IsStable != tt.stable {
+				t.Errorf("AssessConnectionQuality() = score %v stable %v, want score %v stable %v",
+					got.StabilityScore, got.IsStable, tt.score, tt.stable)
+			}
+			if fmt.Sprint(got.Recommendations) != fmt.Sprint(tt.recs) {
+				t.Errorf("recommendations = %q, want %q", got.Recommendations, tt.recs)
+			}
+		})
+	}
+
+	// Without a ping result there's nothing to judge stability by
+	r := &models.TestResult{DownloadResult: &models.DownloadResult{Throughput: 100}, UploadResult: &models.UploadResult{Throughput: 20}}
+	if AssessConnectionQuality(r) != nil {
+		t.Error("AssessConnectionQuality() without a ping result is not nil")
+	}
+}
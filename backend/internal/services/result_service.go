@@ -0,0 +1,98 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"nova-speed/backend/internal/models"
+	"nova-speed/backend/internal/storage"
+
+	"go.uber.org/zap"
+)
+
+// saveTimeout bounds a single write to the result store
+const saveTimeout = 5 * time.Second
+
+// ResultService persists completed results with the client's geolocation.
+// With no repository it keeps nothing, and with no GeoIP database the
+// geolocation fields stay empty.
+type ResultService struct {
+	logger *zap.Logger
+	repo   storage.Repository
+	geo    *GeolocationService
+}
+
+func NewResultService(logger *zap.Logger, repo storage.Repository, geo *GeolocationService) *ResultService {
+	return &ResultService{
+		logger: logger,
+		repo:   repo,
+		geo:    geo,
+	}
+}
+
+// Enabled reports whether results are being persisted
+func (s *ResultService) Enabled() bool {
+	return s.repo != nil
+}
+
+// Save stores the current state of a test under id. createdAt is the Unix
+// time of the first result, so a session saved again keeps its place in
+// the history.
+func (s *ResultService) Save(id, clientIP string, createdAt int64, result models.TestResult) {
+	if s.repo == nil {
+		return
+	}
+
+	record := &models.StoredResult{
+		ID:        id,
+		Timestamp: createdAt,
+		UpdatedAt: time.Now().Unix(),
+		ClientIP:  clientIP,
+		Result:    result,
+	}
+	if s.geo != nil {
+		if info, err := s.geo.GetIPInfo(clientIP); err == nil {
+			record.Country = info.Country
+			record.CountryCode = info.CountryCode
+			record.City = info.City
+			record.ASN = info.ASN
+			record.ISP = info.ISP
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), saveTimeout)
+	defer cancel()
+	if err := s.repo.Save(ctx, record); err != nil {
+		s.logger.Error("Failed to store test result", zap.Error(err), zap.String("id", id))
+	}
+}
+
+// SaveNew stores a test that doesn't belong to a session under a new ID
+func (s *ResultService) SaveNew(clientIP string, result models.TestResult) {
+	if s.repo == nil {
+		return
+	}
+	id, err := newSessionID()
+	if err != nil {
+		s.logger.Error("Failed to store test result", zap.Error(err))
+		return
+	}
+	s.Save(id, clientIP, time.Now().Unix(), result)
+}
+
+// Get returns a stored result
+func (s *ResultService) Get(ctx context.Context, id string) (*models.StoredResult, error) {
+	if s.repo == nil {
+		return nil, storage.ErrNotFound
+	}
+	return s.repo.Get(ctx, id)
+}
+
+// List returns stored results, newest first
+func (s *ResultService) List(ctx context.Context, query storage.Query) (*storage.Page, error) {
+	if s.repo == nil {
+		query = query.Normalized()
+		return &storage.Page{Results: []*models.StoredResult{}, Limit: query.Limit, Offset: query.Offset}, nil
+	}
+	return s.repo.List(ctx, query)
+}
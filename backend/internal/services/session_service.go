@@ -0,0 +1,163 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+
+	"nova-speed/backend/internal/models"
+
+	"go.uber.org/zap"
+)
+
+// ErrSessionNotFound is returned for unknown or expired session IDs
+var ErrSessionNotFound = errors.New("session not found")
+
+// SessionService keeps test sessions in memory so the results of separate
+// phase connections can be aggregated and looked up by ID. Sessions expire
+// ttl after their last update.
+type SessionService struct {
+	logger      *zap.Logger
+	mu          sync.Mutex
+	sessions    map[string]*models.Session
+	ttl         time.Duration
+	maxSessions int
+}
+
+func NewSessionService(logger *zap.Logger, ttl time.Duration, maxSessions int) *SessionService {
+	return &SessionService{
+		logger:      logger,
+		sessions:    make(map[string]*models.Session),
+		ttl:         ttl,
+		maxSessions: maxSessions,
+	}
+}
+
+// SetLimits applies new limits from a reloaded config
+func (s *SessionService) SetLimits(ttl time.Duration, maxSessions int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ttl = ttl
+	s.maxSessions = maxSessions
+}
+
+// Create starts a new session for a client
+func (s *SessionService) Create(clientIP string) (*models.Session, error) {
+	id, err := newSessionID()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().Unix()
+	session := &models.Session{
+		ID:        id,
+		ClientIP:  clientIP,
+		CreatedAt: now,
+		UpdatedAt: now,
+		Result:    models.TestResult{Type: "summary", Phases: []string{}},
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expireLocked()
+	if len(s.sessions) >= s.maxSessions {
+		s.evictOldestLocked()
+	}
+	s.sessions[id] = session
+
+	copied := *session
+	return &copied, nil
+}
+
+// Get returns a copy of a session
+func (s *SessionService) Get(id string) (*models.Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[id]
+	if !ok || s.expiredLocked(session) {
+		return nil, ErrSessionNotFound
+	}
+	copied := *session
+	copied.Result.Phases = append([]string(nil), session.Result.Phases...)
+	return &copied, nil
+}
+
+// Exists reports whether a session can still be recorded into
+func (s *SessionService) Exists(id string) bool {
+	_, err := s.Get(id)
+	return err == nil
+}
+
+// Update applies fn to a session's aggregated result
+func (s *SessionService) Update(id string, fn func(result *models.TestResult)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[id]
+	if !ok || s.expiredLocked(session) {
+		return ErrSessionNotFound
+	}
+	fn(&session.Result)
+	session.UpdatedAt = time.Now().Unix()
+	session.Result.Timestamp = session.UpdatedAt
+	return nil
+}
+
+// Run removes expired sessions every interval until stop is closed
+func (s *SessionService) Run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.mu.Lock()
+			removed := s.expireLocked()
+			s.mu.Unlock()
+			if removed > 0 {
+				s.logger.Debug("Expired test sessions", zap.Int("removed", removed))
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+func (s *SessionService) expiredLocked(session *models.Session) bool {
+	return time.Since(time.Unix(session.UpdatedAt, 0)) > s.ttl
+}
+
+func (s *SessionService) expireLocked() int {
+	removed := 0
+	for id, session := range s.sessions {
+		if s.expiredLocked(session) {
+			delete(s.sessions, id)
+			removed++
+		}
+	}
+	return removed
+}
+
+func (s *SessionService) evictOldestLocked() {
+	var oldest *models.Session
+	for _, session := range s.sessions {
+		if oldest == nil || session.UpdatedAt < oldest.UpdatedAt {
+			oldest = session
+		}
+	}
+	if oldest != nil {
+		delete(s.sessions, oldest.ID)
+	}
+}
+
+// newSessionID returns 128 random bits, hex encoded
+func newSessionID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,257 @@
+package services
+
+import (
+	"errors"
+	"io"
+	"math"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"nova-speed/backend/internal/models"
+	"nova-speed/backend/internal/utils"
+
+	"go.uber.org/zap"
+)
+
+// tcpBufferSize is the size of the buffer each raw TCP flow sends or
+// receives through; the same random buffer is sent over and over
+const tcpBufferSize = 128 * 1024
+
+// ErrUnknownTCPToken is returned for a data connection whose token matches
+// no test waiting for flows
+var ErrUnknownTCPToken = errors.New("unknown or expired test token")
+
+// TCPService runs raw TCP throughput tests: memory-to-memory transfers over
+// plain TCP connections, without any framing
+type TCPService struct {
+	logger *zap.Logger
+
+	mu      sync.Mutex
+	pending map[string]*TCPTest
+}
+
+func NewTCPService(logger *zap.Logger) *TCPService {
+	return &TCPService{
+		logger:  logger,
+		pending: make(map[string]*TCPTest),
+	}
+}
+
+// TCPTest collects the data connections of one raw TCP test
+type TCPTest struct {
+	Token string
+	flows int
+	conns []net.Conn
+	full  chan struct{} // Closed once every flow has joined
+}
+
+// Prepare registers a test expecting flows data connections
+func (s *TCPService) Prepare(flows int) (*TCPTest, error) {
+	token, err := newSessionID()
+	if err != nil {
+		return nil, err
+	}
+	t := &TCPTest{Token: token, flows: flows, full: make(chan struct{})}
+
+	s.mu.Lock()
+	s.pending[token] = t
+	s.mu.Unlock()
+	return t, nil
+}
+
+// Join hands a data connection to the test with token
+func (s *TCPService) Join(token string, conn net.Conn) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.pending[token]
+	if !ok || len(t.conns) == t.flows {
+		return ErrUnknownTCPToken
+	}
+	t.conns = append(t.conns, conn)
+	if len(t.conns) == t.flows {
+		close(t.full)
+	}
+	return nil
+}
+
+// Wait blocks until every flow has joined or timeout has passed, then
+// closes the test to further joins and returns the flows that arrived
+func (s *TCPService) Wait(t *TCPTest, timeout time.Duration) []net.Conn {
+	select {
+	case <-t.full:
+	case <-time.After(timeout):
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.pending, t.Token)
+	return append([]net.Conn(nil), t.conns...)
+}
+
+// RunDownload sends random data on every flow for opts.Duration, then
+// closes them
+func (s *TCPService) RunDownload(flows []net.Conn, opts DownloadOptions) *models.DownloadResult {
+	opts = opts.Clamped()
+	m := s.transfer(flows, opts.Duration, opts.MaxThroughputMbps, func(conn net.Conn, end time.Time, counter *int64) error {
+		buf, err := utils.GenerateRandomPayload(tcpBufferSize)
+		if err != nil {
+			return err
+		}
+		conn.SetWriteDeadline(end)
+		for time.Now().Before(end) {
+			n, err := conn.Write(buf)
+			atomic.AddInt64(counter, int64(n))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	s.logger.Info("Raw TCP download completed",
+		zap.Float64("throughput", m.throughput),
+		zap.Int64("bytes", m.bytes),
+		zap.Float64("duration", m.duration),
+		zap.Int("flows", len(flows)),
+	)
+
+	return &models.DownloadResult{
+		Type:          "result",
+		Throughput:    m.throughput,
+		Bytes:         m.bytes,
+		Duration:      m.duration,
+		SpeedVariance: utils.CalculateVariance(m.samples),
+		SpeedSamples:  m.samples,
+		Timestamp:     time.Now().Unix(),
+		Streams:       len(flows),
+		StreamResults: m.streams,
+		Transport:     models.TransportTCP,
+	}
+}
+
+// RunUpload reads and discards what the client sends on every flow for
+// opts.Duration, then closes them
+func (s *TCPService) RunUpload(flows []net.Conn, opts UploadOptions) *models.UploadResult {
+	opts = opts.Clamped()
+	m := s.transfer(flows, opts.Duration, opts.MaxThroughputMbps, func(conn net.Conn, end time.Time, counter *int64) error {
+		buf := make([]byte, tcpBufferSize)
+		conn.SetReadDeadline(end)
+		for {
+			n, err := conn.Read(buf)
+			atomic.AddInt64(counter, int64(n))
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+		}
+	})
+
+	s.logger.Info("Raw TCP upload completed",
+		zap.Float64("throughput", m.throughput),
+		zap.Int64("bytes", m.bytes),
+		zap.Float64("duration", m.duration),
+		zap.Int("flows", len(flows)),
+	)
+
+	return &models.UploadResult{
+		Type:          "result",
+		Throughput:    m.throughput,
+		Bytes:         m.bytes,
+		Duration:      m.duration,
+		SpeedVariance: utils.CalculateVariance(m.samples),
+		SpeedSamples:  m.samples,
+		Timestamp:     time.Now().Unix(),
+		Transport:     models.TransportTCP,
+		Streams:       len(flows),
+		StreamResults: m.streams,
+	}
+}
+
+// tcpMeasurement is the outcome of a transfer over all flows
+type tcpMeasurement struct {
+	bytes      int64
+	duration   float64
+	throughput float64
+	samples    []float64
+	streams    []models.StreamResult
+}
+
+// transfer runs move on every flow until the end time, sampling the total
+// throughput every 500ms, and closes the flows. Throughputs are capped at
+// maxMbps like the WebSocket results.
+func (s *TCPService) transfer(flows []net.Conn, duration time.Duration, maxMbps float64, move func(conn net.Conn, end time.Time, counter *int64) error) tcpMeasurement {
+	startTime := time.Now()
+	end := startTime.Add(duration)
+	counters := make([]int64, len(flows))
+
+	var wg sync.WaitGroup
+	for i, conn := range flows {
+		wg.Add(1)
+		go func(i int, conn net.Conn) {
+			defer wg.Done()
+			err := move(conn, end, &counters[i])
+			if netErr, ok := err.(net.Error); err != nil && !(ok && netErr.Timeout()) {
+				// The other flows carry on without this one
+				s.logger.Debug("Raw TCP flow ended early", zap.Error(err), zap.Int("flow", i))
+			}
+		}(i, conn)
+	}
+	flowsDone := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(flowsDone)
+	}()
+
+	total := func() int64 {
+		var sum int64
+		for i := range counters {
+			sum += atomic.LoadInt64(&counters[i])
+		}
+		return sum
+	}
+
+	var samples []float64
+	ticker := time.NewTicker(500 * time.Millisecond)
+sampling:
+	for {
+		select {
+		case now := <-ticker.C:
+			samples = append(samples, math.Min(utils.CalculateThroughput(total(), now.Sub(startTime).Seconds()), maxMbps))
+		case <-flowsDone:
+			break sampling
+		}
+	}
+	ticker.Stop()
+	elapsed := time.Since(startTime).Seconds()
+
+	for _, conn := range flows {
+		conn.Close()
+	}
+
+	// Ensure minimum duration for accurate measurement
+	if elapsed < 0.1 {
+		elapsed = 0.1
+	}
+
+	m := tcpMeasurement{
+		bytes:    total(),
+		duration: elapsed,
+		samples:  samples,
+		streams:  make([]models.StreamResult, len(flows)),
+	}
+	m.throughput = math.Min(utils.CalculateThroughput(m.bytes, elapsed), maxMbps)
+	for i := range flows {
+		n := atomic.LoadInt64(&counters[i])
+		m.streams[i] = models.StreamResult{
+			Stream:     i,
+			Bytes:      n,
+			Throughput: math.Min(utils.CalculateThroughput(n, elapsed), maxMbps),
+		}
+	}
+	return m
+}
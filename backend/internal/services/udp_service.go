@@ -0,0 +1,275 @@
+package services
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"nova-speed/backend/internal/models"
+	"nova-speed/backend/internal/udpproto"
+	"nova-speed/backend/internal/utils"
+
+	"go.uber.org/zap"
+)
+
+// udpHelloTimeout bounds the wait for a UDP test's first Hello datagram
+const udpHelloTimeout = 5 * time.Second
+
+var (
+	// ErrUDPDisabled is returned when no UDP listener is running
+	ErrUDPDisabled = errors.New("UDP test is not enabled on this server")
+
+	// ErrNoUDPHello is returned when the client's datagrams never arrived
+	ErrNoUDPHello = errors.New("no UDP datagrams received from client")
+)
+
+// UDPService runs latency tests over UDP, where loss, duplication and
+// reordering are visible instead of hidden by TCP retransmits. Datagrams are
+// only sent to an address that presented a token handed out over the
+// WebSocket control channel, from the same IP as that channel, so the
+// server can't be aimed at a third party.
+type UDPService struct {
+	logger *zap.Logger
+
+	mu    sync.Mutex
+	conn  net.PacketConn
+	tests map[string]*UDPTest
+}
+
+func NewUDPService(logger *zap.Logger) *UDPService {
+	return &UDPService{
+		logger: logger,
+		tests:  make(map[string]*UDPTest),
+	}
+}
+
+// UDPTest is one registered UDP test
+type UDPTest struct {
+	Token string
+
+	clientIP net.IP        // Only a Hello from this IP starts the test
+	hello    chan net.Addr // The client's address, from its first Hello
+	echoes   chan udpEcho
+	addr     net.Addr // Set once the test is running; guarded by UDPService.mu
+}
+
+type udpEcho struct {
+	sequence uint32
+	at       time.Time
+}
+
+// Serve reads the datagrams of conn until it is closed
+func (s *UDPService) Serve(conn net.PacketConn) {
+	s.mu.Lock()
+	s.conn = conn
+	s.mu.Unlock()
+
+	buf := make([]byte, 2048)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				continue
+			}
+			s.logger.Debug("UDP listener stopped", zap.Error(err))
+			s.mu.Lock()
+			s.conn = nil
+			s.mu.Unlock()
+			return
+		}
+		at := time.Now()
+
+		p, err := udpproto.Parse(buf[:n])
+		if err != nil {
+			continue
+		}
+		s.dispatch(p, addr, at)
+	}
+}
+
+// dispatch hands a datagram to its test; unknown tokens are dropped
+func (s *UDPService) dispatch(p udpproto.Packet, addr net.Addr, at time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.tests[p.Token]
+	if !ok {
+		return
+	}
+	switch p.Type {
+	case udpproto.Hello:
+		if udpAddr, ok := addr.(*net.UDPAddr); !ok || !udpAddr.IP.Equal(t.clientIP) {
+			s.logger.Debug("Ignoring UDP hello from another address",
+				zap.String("from", addr.String()), zap.String("client", t.clientIP.String()))
+			return
+		}
+		if t.addr == nil {
+			t.addr = addr
+			t.hello <- addr
+		}
+	case udpproto.Echo:
+		if t.addr == nil || t.addr.String() != addr.String() {
+			return
+		}
+		select {
+		case t.echoes <- udpEcho{sequence: p.Sequence, at: at}:
+		default:
+			// The test has stopped reading; a flood of duplicates ends here
+		}
+	}
+}
+
+// Port returns the port of the UDP listener, or 0 if none is running
+func (s *UDPService) Port() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		return 0
+	}
+	if addr, ok := s.conn.LocalAddr().(*net.UDPAddr); ok {
+		return addr.Port
+	}
+	return 0
+}
+
+// Prepare registers a test for the client at clientIP. Release it once done.
+func (s *UDPService) Prepare(opts UDPOptions, clientIP net.IP) (*UDPTest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		return nil, ErrUDPDisabled
+	}
+
+	token, err := newSessionID()
+	if err != nil {
+		return nil, err
+	}
+	t := &UDPTest{
+		Token:    token,
+		clientIP: clientIP,
+		hello:    make(chan net.Addr, 1),
+		echoes:   make(chan udpEcho, 2*opts.Clamped().Count),
+	}
+	s.tests[token] = t
+	return t, nil
+}
+
+// Release unregisters a test
+func (s *UDPService) Release(t *UDPTest) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.tests, t.Token)
+}
+
+// RunTest waits for the client's Hello, then sends opts.Count probes at
+// opts.Rate and collects their echoes until opts.Timeout after the last
+func (s *UDPService) RunTest(t *UDPTest, opts UDPOptions) (*models.PingResult, error) {
+	opts = opts.Clamped()
+
+	var addr net.Addr
+	select {
+	case addr = <-t.hello:
+	case <-time.After(udpHelloTimeout):
+		return nil, ErrNoUDPHello
+	}
+
+	s.mu.Lock()
+	conn := s.conn
+	s.mu.Unlock()
+	if conn == nil {
+		return nil, ErrUDPDisabled
+	}
+
+	sendTimes := make([]time.Time, opts.Count)
+	rtts := make([]float64, opts.Count) // -1 until the echo arrives
+	for i := range rtts {
+		rtts[i] = -1
+	}
+
+	startTime := time.Now()
+	sent, received, late, duplicates, reordered := 0, 0, 0, 0, 0
+	highest := -1
+	var arrivals []float64 // RTTs in the order the echoes arrived
+
+	ticker := time.NewTicker(opts.Interval())
+	defer ticker.Stop()
+	send := func() {
+		sendTimes[sent] = time.Now()
+		probe := udpproto.Packet{Type: udpproto.Probe, Token: t.Token, Sequence: uint32(sent), Timestamp: models.GetMonotonicTime()}
+		if _, err := conn.WriteTo(probe.Marshal(), addr); err != nil {
+			s.logger.Warn("Failed to send UDP probe", zap.Error(err), zap.Int("sequence", sent))
+		}
+		sent++
+	}
+	send()
+
+	var deadline <-chan time.Time
+	for received < opts.Count {
+		select {
+		case <-ticker.C:
+			if sent < opts.Count {
+				send()
+			}
+			if sent == opts.Count && deadline == nil {
+				deadline = time.After(opts.Timeout)
+			}
+			continue
+		case <-deadline:
+		case echo := <-t.echoes:
+			seq := int(echo.sequence)
+			if seq >= sent {
+				continue // Never sent; not a real echo
+			}
+			if rtts[seq] >= 0 {
+				duplicates++
+				continue
+			}
+			elapsed := echo.at.Sub(sendTimes[seq])
+			if elapsed > opts.Timeout {
+				late++
+			}
+			rtt := float64(elapsed.Nanoseconds()) / 1_000_000.0
+			rtts[seq] = rtt
+			if seq < highest {
+				reordered++
+			} else {
+				highest = seq
+			}
+			arrivals = append(arrivals, rtt)
+			received++
+			continue
+		}
+		break
+	}
+
+	result := &models.PingResult{
+		Type:          "result",
+		Packets:       received,
+		PacketLoss:    utils.CalculatePacketLoss(sent, received),
+		Timestamp:     time.Now().Unix(),
+		Transport:     models.TransportUDP,
+		Sent:          sent,
+		Late:          late,
+		Duplicates:    duplicates,
+		Reordered:     reordered,
+		JitterRFC3550: utils.CalculateRFC3550Jitter(arrivals),
+	}
+	// Statistics in probe order, as the ping test reports them
+	addLatencyStats(result, rtts[:sent])
+
+	s.logger.Info("UDP latency test completed",
+		zap.Float64("avgLatency", result.Latency),
+		zap.Float64("jitter", result.JitterRFC3550),
+		zap.Float64("packetLoss", result.PacketLoss),
+		zap.Int("sent", sent),
+		zap.Int("received", received),
+		zap.Int("late", late),
+		zap.Int("duplicates", duplicates),
+		zap.Int("reordered", reordered),
+		zap.Float64("duration", time.Since(startTime).Seconds()),
+	)
+
+	return result, nil
+}
@@ -2,6 +2,7 @@ package services
 
 import (
 	"encoding/json"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -23,26 +24,30 @@ func NewUploadService(logger *zap.Logger) *UploadService {
 }
 
 // RunTest executes an upload throughput test
-func (s *UploadService) RunTest(c *websocket.Conn) *models.UploadResult {
-	const (
-		testDuration    = 10 * time.Second // 10 second test
-		minChunkSize    = 64 * 1024       // 64 KB minimum
-		maxChunkSize    = 10 * 1024 * 1024 // 10 MB maximum
-		initialChunkSize = 256 * 1024      // 256 KB initial
-	)
+func (s *UploadService) RunTest(c MessageConn, opts UploadOptions) *models.UploadResult {
+	opts = opts.Clamped()
+	testDuration := opts.Duration
+	minChunkSize := opts.MinChunkSize
+	maxChunkSize := opts.MaxChunkSize
+	initialChunkSize := opts.InitialChunkSize
 
 	startTime := time.Now()
 	endTime := startTime.Add(testDuration)
 	minTestDuration := 3 * time.Second // Minimum test duration
-	maxTestDuration := testDuration     // Maximum test duration
+	if minTestDuration > testDuration {
+		minTestDuration = testDuration
+	}
+	maxTestDuration := testDuration // Maximum test duration
 
 	var totalBytes int64
 	chunkSize := initialChunkSize
+	var currentChunkSize atomic.Int64 // chunkSize, for progress messages
+	currentChunkSize.Store(int64(chunkSize))
+	var writeMu sync.Mutex // Serializes writes to c
 	var currentThroughput float64
 	var previousThroughput float64
 	var speedSamples []float64
 	var recentSamples []float64 // Last 5 samples for stability check
-	var earlyStop bool
 
 	// Send start message
 	startMsg := models.UploadMessage{
@@ -62,39 +67,32 @@ func (s *UploadService) RunTest(c *websocket.Conn) *models.UploadResult {
 		}
 	}
 
+	// Latency probes go out between the control messages; their pongs
+	// arrive among the upload data
+	probe := startLatencyProbe(c, &writeMu, s.logger, opts.LatencyInterval)
+
 	// Receive and process upload chunks
 	sequence := 0
 	lastAdaptationTime := startTime
-	done := false
+	finished := make(chan struct{})
 
 	// Use a goroutine to handle reading while monitoring time
 	go func() {
+		defer close(finished)
+		// A failed read can't be retried, so one deadline covers the test
+		c.SetReadDeadline(endTime)
+		done := false
 		for !done {
-			// Set read deadline
 			remainingTime := endTime.Sub(time.Now())
 			if remainingTime <= 0 {
 				done = true
 				break
 			}
-			
-			// Set deadline to remaining time or 2 seconds, whichever is smaller
-			deadline := remainingTime
-			if deadline > 2*time.Second {
-				deadline = 2 * time.Second
-			}
-			c.SetReadDeadline(time.Now().Add(deadline))
 
 			// Read message (could be binary or text/JSON)
 			messageType, data, err := c.ReadMessage()
 			if err != nil {
-				// Check if it's a timeout
-				if netErr, ok := err.(interface{ Timeout() bool }); ok && netErr.Timeout() {
-					// Continue if we haven't reached end time
-					if time.Now().Before(endTime) {
-						continue
-					}
-				}
-				// Other errors - log and break
+				// End time reached, or the connection failed
 				if !done {
 					s.logger.Debug("Failed to read message", zap.Error(err))
 				}
@@ -104,6 +102,9 @@ func (s *UploadService) RunTest(c *websocket.Conn) *models.UploadResult {
 
 			// Handle text/JSON messages (like "complete")
 			if messageType == websocket.TextMessage {
+				if probe.handle(data) {
+					continue
+				}
 				var msg models.UploadMessage
 				if err := json.Unmarshal(data, &msg); err == nil {
 					if msg.Type == "complete" {
@@ -143,7 +144,6 @@ func (s *UploadService) RunTest(c *websocket.Conn) *models.UploadResult {
 								zap.Float64("throughput", currentThroughput),
 								zap.Duration("duration", elapsedDuration),
 							)
-							earlyStop = true
 							done = true
 							break
 						}
@@ -168,6 +168,7 @@ func (s *UploadService) RunTest(c *websocket.Conn) *models.UploadResult {
 							zap.Float64("speedChange", speedChange),
 						)
 						chunkSize = newChunkSize
+						currentChunkSize.Store(int64(chunkSize))
 
 						// Send updated chunk size to client
 						updateMsg := models.UploadMessage{
@@ -175,7 +176,10 @@ func (s *UploadService) RunTest(c *websocket.Conn) *models.UploadResult {
 							ChunkSize: chunkSize,
 							Sequence:  sequence,
 						}
-						if err := c.WriteJSON(updateMsg); err != nil {
+						writeMu.Lock()
+						err := c.WriteJSON(updateMsg)
+						writeMu.Unlock()
+						if err != nil {
 							s.logger.Warn("Failed to send chunk size update", zap.Error(err))
 						}
 					}
@@ -186,20 +190,20 @@ func (s *UploadService) RunTest(c *websocket.Conn) *models.UploadResult {
 		}
 	}()
 
-	// Wait for test duration or early stop
+	stopProgress := startProgress(c, &writeMu, s.logger, opts.ProgressInterval, opts.MaxThroughputMbps, func() (int64, int, int) {
+		return atomic.LoadInt64(&totalBytes), int(currentChunkSize.Load()), 1
+	})
+
+	// Wait for the reader to finish (end time, early stop, "complete" or a
+	// closed connection); past the maximum duration, unblock its read
 	select {
-	case <-time.After(maxTestDuration):
-		// Maximum duration reached
-		done = true
-	case <-time.After(100 * time.Millisecond):
-		// Check if early stop was triggered
-		if earlyStop {
-			done = true
-		}
+	case <-finished:
+	case <-time.After(maxTestDuration + 2*time.Second):
+		c.SetReadDeadline(time.Now())
+		<-finished
 	}
-	
-	// Give a moment for any remaining messages
-	time.Sleep(100 * time.Millisecond)
+	stopProgress()
+	probe.stop(false)
 
 	duration := time.Since(startTime).Seconds()
 	
@@ -212,13 +216,13 @@ func (s *UploadService) RunTest(c *websocket.Conn) *models.UploadResult {
 	
 	// Validate throughput - cap unrealistic values (likely localhost loopback)
 	// If throughput is > 10 Gbps, it's likely a localhost measurement issue
-	if finalThroughput > 10000 {
+	if finalThroughput > opts.MaxThroughputMbps {
 		s.logger.Warn("Unrealistic throughput detected, likely localhost loopback",
 			zap.Float64("throughput", finalThroughput),
 			zap.Int64("bytes", atomic.LoadInt64(&totalBytes)),
 			zap.Float64("duration", duration))
-		// Cap at reasonable maximum (10 Gbps)
-		finalThroughput = 10000
+		// Cap at the configured maximum
+		finalThroughput = opts.MaxThroughputMbps
 	}
 
 	// Calculate speed variance
@@ -233,12 +237,13 @@ func (s *UploadService) RunTest(c *websocket.Conn) *models.UploadResult {
 	)
 
 	return &models.UploadResult{
-		Type:          "result",
-		Throughput:    finalThroughput,
-		Bytes:         atomic.LoadInt64(&totalBytes),
-		Duration:      duration,
-		SpeedVariance: speedVariance,
-		SpeedSamples:  speedSamples,
-		Timestamp:     time.Now().Unix(),
+		Type:             "result",
+		Throughput:       finalThroughput,
+		Bytes:            atomic.LoadInt64(&totalBytes),
+		Duration:         duration,
+		SpeedVariance:    speedVariance,
+		SpeedSamples:     speedSamples,
+		Timestamp:        time.Now().Unix(),
+		LatencyUnderLoad: probe.result(),
 	}
 }
@@ -0,0 +1,156 @@
+package storage
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"nova-speed/backend/internal/models"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	resultsBucket = []byte("results")      // id -> JSON record
+	timeBucket    = []byte("results_time") // timestamp (big-endian) + id -> nothing, for ordered listing
+)
+
+// BoltRepository is a Repository in a single bbolt file
+type BoltRepository struct {
+	db *bolt.DB
+}
+
+// OpenBolt opens (or creates) the database file, creating its directory
+// if needed
+func OpenBolt(path string) (*BoltRepository, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create storage directory: %w", err)
+	}
+
+	// The timeout keeps a second instance from hanging on the file lock
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open result store %s: %w", path, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{resultsBucket, timeBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize result store: %w", err)
+	}
+	return &BoltRepository{db: db}, nil
+}
+
+func timeKey(timestamp int64, id string) []byte {
+	key := make([]byte, 8+len(id))
+	binary.BigEndian.PutUint64(key, uint64(timestamp))
+	copy(key[8:], id)
+	return key
+}
+
+// Save inserts or replaces a result
+func (r *BoltRepository) Save(ctx context.Context, result *models.StoredResult) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+
+	return r.db.Update(func(tx *bolt.Tx) error {
+		results, index := tx.Bucket(resultsBucket), tx.Bucket(timeBucket)
+
+		// Keep the index in step if the timestamp of an existing record changed
+		if old := results.Get([]byte(result.ID)); old != nil {
+			var previous models.StoredResult
+			if err := json.Unmarshal(old, &previous); err == nil && previous.Timestamp != result.Timestamp {
+				if err := index.Delete(timeKey(previous.Timestamp, previous.ID)); err != nil {
+					return err
+				}
+			}
+		}
+
+		if err := results.Put([]byte(result.ID), data); err != nil {
+			return err
+		}
+		return index.Put(timeKey(result.Timestamp, result.ID), nil)
+	})
+}
+
+// Get returns one result
+func (r *BoltRepository) Get(ctx context.Context, id string) (*models.StoredResult, error) {
+	var result models.StoredResult
+	err := r.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(resultsBucket).Get([]byte(id))
+		if data == nil {
+			return ErrNotFound
+		}
+		return json.Unmarshal(data, &result)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// List walks the time index newest first. Filters are applied to every
+// record, so the cost grows with the number of stored results.
+func (r *BoltRepository) List(ctx context.Context, query Query) (*Page, error) {
+	query = query.Normalized()
+	page := &Page{Results: []*models.StoredResult{}, Limit: query.Limit, Offset: query.Offset}
+
+	err := r.db.View(func(tx *bolt.Tx) error {
+		results := tx.Bucket(resultsBucket)
+		c := tx.Bucket(timeBucket).Cursor()
+
+		for key, _ := c.Last(); key != nil; key, _ = c.Prev() {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			timestamp := int64(binary.BigEndian.Uint64(key[:8]))
+			if query.To != 0 && timestamp > query.To {
+				continue
+			}
+			if query.From != 0 && timestamp < query.From {
+				break // Everything further back is older still
+			}
+
+			data := results.Get(key[8:])
+			if data == nil {
+				continue
+			}
+			var result models.StoredResult
+			if err := json.Unmarshal(data, &result); err != nil {
+				return fmt.Errorf("corrupt result %q: %w", key[8:], err)
+			}
+			if !query.Matches(&result) {
+				continue
+			}
+
+			if page.Total >= query.Offset && len(page.Results) < query.Limit {
+				page.Results = append(page.Results, &result)
+			}
+			page.Total++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// Close closes the database file
+func (r *BoltRepository) Close() error {
+	return r.db.Close()
+}
@@ -0,0 +1,116 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"nova-speed/backend/internal/models"
+)
+
+func openTestBolt(t *testing.T) *BoltRepository {
+	t.Helper()
+	repo, err := OpenBolt(filepath.Join(t.TempDir(), "results", "nova.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { repo.Close() })
+	return repo
+}
+
+// ids returns the IDs of a page's results in order
+func ids(page *Page) []string {
+	out := make([]string, len(page.Results))
+	for i, r := range page.Results {
+		out[i] = r.ID
+	}
+	return out
+}
+
+func TestBoltList(t *testing.T) {
+	ctx := context.Background()
+	repo := openTestBolt(t)
+
+	// r0 is the oldest; odd results come from another country
+	for i := 0; i < 7; i++ {
+		country := "BG"
+		if i%2 == 1 {
+			country = "DE"
+		}
+		r := &models.StoredResult{ID: fmt.Sprintf("r%d", i), Timestamp: int64(1000 + i*10), CountryCode: country}
+		if err := repo.Save(ctx, r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		query Query
+		want  []string
+		total int
+	}{
+		{name: "newest first", query: Query{}, want: []string{"r6", "r5", "r4", "r3", "r2", "r1", "r0"}, total: 7},
+		{name: "first page", query: Query{Limit: 3}, want: []string{"r6", "r5", "r4"}, total: 7},
+		{name: "second page", query: Query{Limit: 3, Offset: 3}, want: []string{"r3", "r2", "r1"}, total: 7},
+		{name: "last page", query: Query{Limit: 3, Offset: 6}, want: []string{"r0"}, total: 7},
+		{name: "past the end", query: Query{Limit: 3, Offset: 7}, want: []string{}, total: 7},
+		{name: "time range", query: Query{From: 1010, To: 1030}, want: []string{"r3", "r2", "r1"}, total: 3},
+		{name: "filtered pages", query: Query{CountryCode: "BG", Limit: 2, Offset: 1}, want: []string{"r4", "r2"}, total: 4},
+		{name: "no match", query: Query{CountryCode: "FR"}, want: []string{}, total: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := repo.List(ctx, tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := ids(page)
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) || page.Total != tt.total {
+				t.Errorf("List() = %v (total %d), want %v (total %d)", got, page.Total, tt.want, tt.total)
+			}
+			if want := tt.query.Normalized(); page.Limit != want.Limit || page.Offset != want.Offset {
+				t.Errorf("page limit/offset = %d/%d, want %d/%d", page.Limit, page.Offset, want.Limit, want.Offset)
+			}
+		})
+	}
+}
+
+func TestBoltSaveReplaces(t *testing.T) {
+	ctx := context.Background()
+	repo := openTestBolt(t)
+
+	for _, r := range []*models.StoredResult{
+		{ID: "old", Timestamp: 1000},
+		{ID: "session", Timestamp: 2000, Result: models.TestResult{Phases: []string{"ping"}}},
+		{ID: "new", Timestamp: 3000},
+		// Saving again as phases complete, with an earlier timestamp
+		{ID: "session", Timestamp: 500, Result: models.TestResult{Phases: []string{"ping", "download"}}},
+	} {
+		if err := repo.Save(ctx, r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	page, err := repo.List(ctx, Query{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(ids(page)); got != "[new old session]" || page.Total != 3 {
+		t.Errorf("List() = %s (total %d), want [new old session] (total 3)", got, page.Total)
+	}
+
+	r, err := repo.Get(ctx, "session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Result.Phases) != 2 {
+		t.Errorf("phases = %q, want the replacement's", r.Result.Phases)
+	}
+
+	if _, err := repo.Get(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get(missing) error = %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,95 @@
+// Package storage persists completed test results.
+package storage
+
+import (
+	"context"
+	"errors"
+
+	"nova-speed/backend/internal/models"
+)
+
+// ErrNotFound is returned for unknown result IDs
+var ErrNotFound = errors.New("result not found")
+
+// Page size bounds for List
+const (
+	DefaultLimit = 50
+	MaxLimit     = 500
+)
+
+// Repository stores test results keyed by ID. Saving an existing ID
+// replaces the record, so a session can be saved again as phases complete.
+type Repository interface {
+	Save(ctx context.Context, result *models.StoredResult) error
+	Get(ctx context.Context, id string) (*models.StoredResult, error)
+	// List returns matching results, newest first
+	List(ctx context.Context, query Query) (*Page, error)
+	Close() error
+}
+
+// Query filters and pages a List call. Zero values don't filter.
+type Query struct {
+	Limit  int
+	Offset int
+
+	From int64 // Unix timestamps, inclusive
+	To   int64
+
+	ClientIP    string
+	CountryCode string
+	ASN         uint
+	Phase       string // Only results that include this phase
+}
+
+// Normalized applies the default and maximum page size
+func (q Query) Normalized() Query {
+	if q.Limit <= 0 {
+		q.Limit = DefaultLimit
+	}
+	if q.Limit > MaxLimit {
+		q.Limit = MaxLimit
+	}
+	if q.Offset < 0 {
+		q.Offset = 0
+	}
+	return q
+}
+
+// Matches reports whether a result passes the filters
+func (q Query) Matches(r *models.StoredResult) bool {
+	if q.From != 0 && r.Timestamp < q.From {
+		return false
+	}
+	if q.To != 0 && r.Timestamp > q.To {
+		return false
+	}
+	if q.ClientIP != "" && r.ClientIP != q.ClientIP {
+		return false
+	}
+	if q.CountryCode != "" && r.CountryCode != q.CountryCode {
+		return false
+	}
+	if q.ASN != 0 && r.ASN != q.ASN {
+		return false
+	}
+	if q.Phase != "" {
+		found := false
+		for _, p := range r.Result.Phases {
+			if p == q.Phase {
+				found = true
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// Page is one page of List results
+type Page struct {
+	Results []*models.StoredResult `json:"results"`
+	Total   int                    `json:"total"` // Matching results across all pages
+	Limit   int                    `json:"limit"`
+	Offset  int                    `json:"offset"`
+}
@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"testing"
+
+	"nova-speed/backend/internal/models"
+)
+
+func TestQueryNormalized(t *testing.T) {
+	tests := []struct {
+		in   Query
+		want Query
+	}{
+		{in: Query{}, want: Query{Limit: DefaultLimit}},
+		{in: Query{Limit: 10, Offset: 20}, want: Query{Limit: 10, Offset: 20}},
+		{in: Query{Limit: MaxLimit + 1}, want: Query{Limit: MaxLimit}},
+		{in: Query{Limit: -1, Offset: -5}, want: Query{Limit: DefaultLimit}},
+	}
+
+	for _, tt := range tests {
+		if got := tt.in.Normalized(); got != tt.want {
+			t.Errorf("%+v.Normalized() = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestQueryMatches(t *testing.T) {
+	r := &models.StoredResult{
+		ID:          "a",
+		Timestamp:   1000,
+		ClientIP:    "198.51.100.1",
+		CountryCode: "BG",
+		ASN:         8866,
+		Result:      models.TestResult{Phases: []string{"ping", "download"}},
+	}
+
+	tests := []struct {
+		name  string
+		query Query
+		want  bool
+	}{
+		{name: "no filters", query: Query{}, want: true},
+		{name: "from inclusive", query: Query{From: 1000}, want: true},
+		{name: "from after", query: Query{From: 1001}, want: false},
+		{name: "to inclusive", query: Query{To: 1000}, want: true},
+		{name: "to before", query: Query{To: 999}, want: false},
+		{name: "range", query: Query{From: 900, To: 1100}, want: true},
+		{name: "client IP", query: Query{ClientIP: "198.51.100.1"}, want: true},
+		{name: "other client IP", query: Query{ClientIP: "198.51.100.2"}, want: false},
+		{name: "country", query: Query{CountryCode: "BG"}, want: true},
+		{name: "other country", query: Query{CountryCode: "DE"}, want: false},
+		{name: "ASN", query: Query{ASN: 8866}, want: true},
+		{name: "other ASN", query: Query{ASN: 3320}, want: false},
+		{name: "phase", query: Query{Phase: "download"}, want: true},
+		{name: "missing phase", query: Query{Phase: "upload"}, want: false},
+		{name: "all filters", query: Query{From: 1000, To: 1000, ClientIP: "198.51.100.1", CountryCode: "BG", ASN: 8866, Phase: "ping"}, want: true},
+		{name: "one filter fails", query: Query{CountryCode: "BG", Phase: "upload"}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.query.Matches(r); got != tt.want {
+				t.Errorf("Matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,183 @@
+// Package tlsutil builds the server's TLS configuration and keeps its
+// certificate in sync with the files on disk.
+package tlsutil
+
+import (
+	"crypto/tls"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var versions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// ParseVersion converts "1.2" or "1.3" to a crypto/tls version constant.
+// Older versions are deliberately not supported.
+func ParseVersion(name string) (uint16, error) {
+	v, ok := versions[name]
+	if !ok {
+		return 0, fmt.Errorf("unsupported TLS version %q (use 1.2 or 1.3)", name)
+	}
+	return v, nil
+}
+
+// ParseCipherSuites converts IANA suite names (as listed by
+// tls.CipherSuites) to IDs. Suites Go considers insecure are rejected.
+// An empty list means Go's defaults.
+func ParseCipherSuites(names []string) ([]uint16, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	known := make(map[string]uint16)
+	for _, suite := range tls.CipherSuites() {
+		known[suite.Name] = suite.ID
+	}
+
+	ids := make([]uint16, 0, len(names))
+	for _, name := range names {
+		id, ok := known[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown or insecure cipher suite %q", name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// ServerConfig builds a TLS config with the given policy that takes its
+// certificate from getCertificate on every handshake. Cipher suites only
+// apply to TLS 1.2; TLS 1.3 suites are not configurable in Go.
+func ServerConfig(minVersion string, cipherSuites []string, getCertificate func(*tls.ClientHelloInfo) (*tls.Certificate, error)) (*tls.Config, error) {
+	version, err := ParseVersion(minVersion)
+	if err != nil {
+		return nil, err
+	}
+	suites, err := ParseCipherSuites(cipherSuites)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tls.Config{
+		MinVersion:     version,
+		CipherSuites:   suites,
+		GetCertificate: getCertificate,
+		NextProtos:     []string{"http/1.1"}, // fasthttp does not speak HTTP/2
+	}, nil
+}
+
+// CertReloader serves a certificate loaded from disk and reloads it when
+// the files change, so renewed certificates are picked up without a restart.
+type CertReloader struct {
+	logger   *zap.Logger
+	mu       sync.RWMutex
+	certFile string
+	keyFile  string
+	cert     *tls.Certificate
+	modTime  time.Time // Latest modification time of the two files when loaded
+}
+
+func NewCertReloader(logger *zap.Logger, certFile, keyFile string) (*CertReloader, error) {
+	r := &CertReloader{logger: logger}
+	if err := r.SetFiles(certFile, keyFile); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// SetFiles loads a certificate from new paths and switches to it. On error
+// the current certificate stays in use.
+func (r *CertReloader) SetFiles(certFile, keyFile string) error {
+	cert, modTime, err := load(certFile, keyFile)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	r.certFile, r.keyFile = certFile, keyFile
+	r.cert, r.modTime = cert, modTime
+	r.mu.Unlock()
+	return nil
+}
+
+// Reload re-reads the current files
+func (r *CertReloader) Reload() error {
+	r.mu.RLock()
+	certFile, keyFile := r.certFile, r.keyFile
+	r.mu.RUnlock()
+	return r.SetFiles(certFile, keyFile)
+}
+
+// GetCertificate implements tls.Config.GetCertificate
+func (r *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// Watch checks the files every interval and reloads them when either has
+// been modified, until stop is closed. A half-written pair that fails to
+// load is retried on the next tick.
+func (r *CertReloader) Watch(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			r.mu.RLock()
+			certFile, keyFile, loaded := r.certFile, r.keyFile, r.modTime
+			r.mu.RUnlock()
+
+			modTime, err := latestModTime(certFile, keyFile)
+			if err != nil {
+				r.logger.Warn("Failed to stat TLS certificate files", zap.Error(err))
+				continue
+			}
+			if !modTime.After(loaded) {
+				continue
+			}
+
+			if err := r.Reload(); err != nil {
+				r.logger.Warn("Failed to reload TLS certificate, keeping current one", zap.Error(err))
+				continue
+			}
+			r.logger.Info("TLS certificate reloaded", zap.String("cert", certFile))
+		case <-stop:
+			return
+		}
+	}
+}
+
+func load(certFile, keyFile string) (*tls.Certificate, time.Time, error) {
+	// Stat first so a change made while loading is seen on the next check
+	modTime, err := latestModTime(certFile, keyFile)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to load TLS key pair: %w", err)
+	}
+	return &cert, modTime, nil
+}
+
+func latestModTime(files ...string) (time.Time, error) {
+	var latest time.Time
+	for _, f := range files {
+		info, err := os.Stat(f)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
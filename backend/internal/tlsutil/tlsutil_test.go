@@ -0,0 +1,233 @@
+package tlsutil
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    uint16
+		wantErr bool
+	}{
+		{name: "1.2", want: tls.VersionTLS12},
+		{name: "1.3", want: tls.VersionTLS13},
+		{name: "1.1", wantErr: true},
+		{name: "1.0", wantErr: true},
+		{name: "", wantErr: true},
+		{name: "TLS1.3", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseVersion(tt.name)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("ParseVersion(%q) = %v, %v, want %v, error %v", tt.name, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestParseCipherSuites(t *testing.T) {
+	tests := []struct {
+		name    string
+		names   []string
+		want    []uint16
+		wantErr bool
+	}{
+		{name: "empty means defaults", names: nil, want: nil},
+		{
+			name:  "secure suites",
+			names: []string{"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256", "TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256"},
+			want:  []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256, tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256},
+		},
+		{name: "insecure suite", names: []string{"TLS_RSA_WITH_RC4_128_SHA"}, wantErr: true},
+		{name: "unknown suite", names: []string{"TLS_NOT_A_SUITE"}, wantErr: true},
+		{
+			name:    "one bad suite",
+			names:   []string{"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256", "TLS_ECDHE_RSA_WITH_RC4_128_SHA"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseCipherSuites(tt.names)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseCipherSuites() error = %v, want error %v", err, tt.wantErr)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("ParseCipherSuites() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("ParseCipherSuites() = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+// newCert generates a self-signed certificate for tests
+func newCert(t *testing.T) *tls.Certificate {
+	t.Helper()
+	cert, err := SelfSigned("localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+// writeCert saves cert's certificate as PEM, with the given mtime
+func writeCert(t *testing.T, path string, cert *tls.Certificate, modTime time.Time) {
+	t.Helper()
+	writePEM(t, path, "CERTIFICATE", cert.Certificate[0], modTime)
+}
+
+// writeKey saves cert's private key as PEM, with the given mtime
+func writeKey(t *testing.T, path string, cert *tls.Certificate, modTime time.Time) {
+	t.Helper()
+	key, err := x509.MarshalPKCS8PrivateKey(cert.PrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePEM(t, path, "PRIVATE KEY", key, modTime)
+}
+
+func writePEM(t *testing.T, path, blockType string, der []byte, modTime time.Time) {
+	t.Helper()
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// serving reports whether r hands out want
+func serving(r *CertReloader, want *tls.Certificate) bool {
+	got, err := r.GetCertificate(nil)
+	return err == nil && got != nil && bytes.Equal(got.Certificate[0], want.Certificate[0])
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	now := time.Now()
+
+	first := newCert(t)
+	writeCert(t, certFile, first, now)
+	writeKey(t, keyFile, first, now)
+	r, err := NewCertReloader(zap.NewNop(), certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !serving(r, first) {
+		t.Fatal("NewCertReloader() doesn't serve the certificate on disk")
+	}
+
+	// Missing files
+	if err := r.SetFiles(filepath.Join(dir, "missing.pem"), keyFile); err == nil {
+		t.Error("SetFiles() with a missing file succeeded")
+	}
+	if !serving(r, first) {
+		t.Error("failed SetFiles() replaced the certificate")
+	}
+
+	// Renewed in place
+	second := newCert(t)
+	writeCert(t, certFile, second, now)
+	writeKey(t, keyFile, second, now)
+	if err := r.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if !serving(r, second) {
+		t.Error("Reload() didn't pick up the renewed certificate")
+	}
+
+	// Half-written: the new certificate doesn't match the old key
+	third := newCert(t)
+	writeCert(t, certFile, third, now)
+	if err := r.Reload(); err == nil {
+		t.Error("Reload() of a mismatched pair succeeded")
+	}
+	if !serving(r, second) {
+		t.Error("failed Reload() replaced the certificate")
+	}
+
+	// Switched to other files
+	otherCert, otherKey := filepath.Join(dir, "other-cert.pem"), filepath.Join(dir, "other-key.pem")
+	writeCert(t, otherCert, first, now)
+	writeKey(t, otherKey, first, now)
+	if err := r.SetFiles(otherCert, otherKey); err != nil {
+		t.Fatal(err)
+	}
+	if !serving(r, first) {
+		t.Error("SetFiles() didn't switch certificates")
+	}
+}
+
+func TestCertReloaderWatch(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	now := time.Now()
+
+	first := newCert(t)
+	writeCert(t, certFile, first, now)
+	writeKey(t, keyFile, first, now)
+	r, err := NewCertReloader(zap.NewNop(), certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		r.Watch(10*time.Millisecond, stop)
+		close(done)
+	}()
+	defer func() {
+		close(stop)
+		<-done
+	}()
+
+	// waitFor polls until r serves want or a second has passed
+	waitFor := func(want *tls.Certificate) bool {
+		for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+			if serving(r, want) {
+				return true
+			}
+		}
+		return false
+	}
+
+	// Each write gets a later mtime, as a renewal would
+	second := newCert(t)
+	writeCert(t, certFile, second, now.Add(time.Second))
+	writeKey(t, keyFile, second, now.Add(time.Second))
+	if !waitFor(second) {
+		t.Fatal("Watch() didn't reload the renewed certificate")
+	}
+
+	// Half-written pair: retried every tick, the current certificate stays
+	third := newCert(t)
+	writeCert(t, certFile, third, now.Add(2*time.Second))
+	time.Sleep(50 * time.Millisecond)
+	if !serving(r, second) {
+		t.Fatal("Watch() replaced the certificate with a half-written pair")
+	}
+
+	// Once the key is written too, the pair loads
+	writeKey(t, keyFile, third, now.Add(3*time.Second))
+	if !waitFor(third) {
+		t.Fatal("Watch() didn't reload the completed pair")
+	}
+}
@@ -0,0 +1,62 @@
+// Package udpproto defines the datagrams of the UDP latency test.
+//
+// Every datagram has the same fixed layout: a one-byte type, the 32-character
+// test token handed out on the WebSocket control channel, a big-endian
+// uint32 sequence number and a big-endian int64 timestamp in nanoseconds.
+// The client opens the test with Hello datagrams so the server learns its
+// address (and any NAT on the way lets replies through). The server then
+// sends Probes, and the client returns each one unchanged except for its
+// type, as an Echo.
+package udpproto
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// Datagram types
+const (
+	Hello = 1
+	Probe = 2
+	Echo  = 3
+)
+
+// TokenSize is the length of a test token
+const TokenSize = 32
+
+// PacketSize is the size of every datagram
+const PacketSize = 1 + TokenSize + 4 + 8
+
+// ErrInvalidPacket is returned for datagrams that are not test packets
+var ErrInvalidPacket = errors.New("not a UDP test packet")
+
+// Packet is one datagram
+type Packet struct {
+	Type      byte
+	Token     string
+	Sequence  uint32
+	Timestamp int64
+}
+
+// Marshal encodes p
+func (p Packet) Marshal() []byte {
+	b := make([]byte, PacketSize)
+	b[0] = p.Type
+	copy(b[1:1+TokenSize], p.Token)
+	binary.BigEndian.PutUint32(b[1+TokenSize:], p.Sequence)
+	binary.BigEndian.PutUint64(b[5+TokenSize:], uint64(p.Timestamp))
+	return b
+}
+
+// Parse decodes a datagram
+func Parse(b []byte) (Packet, error) {
+	if len(b) != PacketSize || b[0] < Hello || b[0] > Echo {
+		return Packet{}, ErrInvalidPacket
+	}
+	return Packet{
+		Type:      b[0],
+		Token:     string(b[1 : 1+TokenSize]),
+		Sequence:  binary.BigEndian.Uint32(b[1+TokenSize:]),
+		Timestamp: int64(binary.BigEndian.Uint64(b[5+TokenSize:])),
+	}, nil
+}
@@ -0,0 +1,52 @@
+package utils
+
+// ClockSample is one NTP-style exchange, in Unix nanoseconds: the server
+// sent the probe at ServerSend and got the reply at ServerReceive (server
+// clock); the client got the probe at ClientReceive and replied at
+// ClientSend (client clock).
+type ClockSample struct {
+	ServerSend    int64
+	ClientReceive int64
+	ClientSend    int64
+	ServerReceive int64
+}
+
+// Delay returns the round trip minus the client's processing time
+func (s ClockSample) Delay() int64 {
+	return (s.ServerReceive - s.ServerSend) - (s.ClientSend - s.ClientReceive)
+}
+
+// Offset returns how far the client clock is ahead of the server clock,
+// assuming this exchange took as long in each direction
+func (s ClockSample) Offset() int64 {
+	return ((s.ClientReceive - s.ServerSend) + (s.ClientSend - s.ServerReceive)) / 2
+}
+
+// OneWayDelays estimates the clock offset from the sample with the lowest
+// delay, where queueing distorts the symmetry assumption least, like NTP's
+// clock filter. With that offset fixed, each sample splits into a
+// downstream (server to client) and upstream delay; the means are returned
+// in milliseconds. The split only reveals asymmetry that varies between
+// probes, such as queueing in one direction: a constant difference between
+// the paths is indistinguishable from clock offset.
+func OneWayDelays(samples []ClockSample) (offsetMs, upstreamMs, downstreamMs float64, ok bool) {
+	if len(samples) == 0 {
+		return 0, 0, 0, false
+	}
+
+	best := samples[0]
+	for _, s := range samples[1:] {
+		if s.Delay() < best.Delay() {
+			best = s
+		}
+	}
+	offset := best.Offset()
+
+	var up, down int64
+	for _, s := range samples {
+		down += s.ClientReceive - offset - s.ServerSend
+		up += s.ServerReceive - (s.ClientSend - offset)
+	}
+	n := float64(len(samples))
+	return float64(offset) / 1e6, float64(up) / n / 1e6, float64(down) / n / 1e6, true
+}
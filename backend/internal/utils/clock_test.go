@@ -0,0 +1,105 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+// exchange builds the sample of one probe sent at serverSend, with the
+// client clock offset ahead of the server's and the given path delays
+func exchange(serverSend int64, offset, down, up, processing time.Duration) ClockSample {
+	clientReceive := serverSend + int64(down+offset)
+	clientSend := clientReceive + int64(processing)
+	return ClockSample{
+		ServerSend:    serverSend,
+		ClientReceive: clientReceive,
+		ClientSend:    clientSend,
+		ServerReceive: clientSend - int64(offset) + int64(up),
+	}
+}
+
+func TestClockSample(t *testing.T) {
+	tests := []struct {
+		name   string
+		sample ClockSample
+		delay  time.Duration
+		offset time.Duration
+	}{
+		{
+			name:   "symmetric",
+			sample: exchange(1e9, 5*time.Millisecond, 2*time.Millisecond, 2*time.Millisecond, time.Millisecond),
+			delay:  4 * time.Millisecond,
+			offset: 5 * time.Millisecond,
+		},
+		{
+			name:   "client behind",
+			sample: exchange(1e9, -3*time.Millisecond, 2*time.Millisecond, 2*time.Millisecond, 0),
+			delay:  4 * time.Millisecond,
+			offset: -3 * time.Millisecond,
+		},
+		{
+			// Asymmetry shifts the offset by half the difference
+			name:   "slow upstream",
+			sample: exchange(1e9, 0, time.Millisecond, 5*time.Millisecond, 0),
+			delay:  6 * time.Millisecond,
+			offset: -2 * time.Millisecond,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := time.Duration(tt.sample.Delay()); got != tt.delay {
+				t.Errorf("Delay() = %v, want %v", got, tt.delay)
+			}
+			if got := time.Duration(tt.sample.Offset()); got != tt.offset {
+				t.Errorf("Offset() = %v, want %v", got, tt.offset)
+			}
+		})
+	}
+}
+
+func TestOneWayDelays(t *testing.T) {
+	const ms = time.Millisecond
+	tests := []struct {
+		name                   string
+		samples                []ClockSample
+		offset, upstream, down float64
+		ok                     bool
+	}{
+		{name: "no samples", ok: false},
+		{
+			name:    "single",
+			samples: []ClockSample{exchange(1e9, 5*ms, 2*ms, 2*ms, ms)},
+			offset:  5, upstream: 2, down: 2, ok: true,
+		},
+		{
+			// The quiet first probe fixes the offset; the second was queued
+			// on the way up
+			name: "upstream queueing",
+			samples: []ClockSample{
+				exchange(1e9, 5*ms, ms, ms, 0),
+				exchange(2e9, 5*ms, ms, 5*ms, 0),
+			},
+			offset: 5, upstream: 3, down: 1, ok: true,
+		},
+		{
+			name: "downstream queueing, least delay last",
+			samples: []ClockSample{
+				exchange(1e9, -2*ms, 9*ms, ms, 0),
+				exchange(2e9, -2*ms, 3*ms, ms, 0),
+				exchange(3e9, -2*ms, ms, ms, 0),
+			},
+			offset: -2, upstream: 1, down: 13.0 / 3, ok: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			offset, up, down, ok := OneWayDelays(tt.samples)
+			if ok != tt.ok || !approxEqual(offset, tt.offset) || !approxEqual(up, tt.upstream) || !approxEqual(down, tt.down) {
+				t.Errorf("OneWayDelays() = %v, %v, %v, %v, want %v, %v, %v, %v",
+					offset, up, down, ok, tt.offset, tt.upstream, tt.down, tt.ok)
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package utils
 
 import (
 	"math"
+	"sort"
 )
 
 // BitsPerSecondToMbps converts bits per second to Megabits per second
@@ -160,38 +161,86 @@ func CalculateVariance(samples []float64) float64 {
 	return varianceSum / float64(len(samples))
 }
 
-// CalculateStabilityScore calculates connection stability score (0-100)
-func CalculateStabilityScore(packetLoss, jitter, speedVariance float64, avgLatency float64) float64 {
+// CalculateStdDev calculates the population standard deviation of samples
+func CalculateStdDev(samples []float64) float64 {
+	return math.Sqrt(CalculateVariance(samples))
+}
+
+// CalculatePercentile returns the p-th percentile (0-100) of samples,
+// interpolating linearly between the closest ranks
+func CalculatePercentile(samples []float64, p float64) float64 {
+	if len(samples) == 0 {
+		return 0
+	}
+	sorted := make([]float64, len(samples))
+	copy(sorted, samples)
+	sort.Float64s(sorted)
+
+	rank := p / 100 * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(rank-float64(lower))
+}
+
+// CalculateRFC3550Jitter calculates the smoothed interarrival jitter of
+// RFC 3550 section 6.4.1, with latencies in arrival order standing in for
+// transit times
+func CalculateRFC3550Jitter(latencies []float64) float64 {
+	var jitter float64
+	for i := 1; i < len(latencies); i++ {
+		jitter += (math.Abs(latencies[i]-latencies[i-1]) - jitter) / 16
+	}
+	return jitter
+}
+
+// CalculateStabilityScore calculates connection stability score (0-100),
+// using the same penalties as the web UI. speedCVs are the coefficients of
+// variation (in percent) of the throughput tests that ran, ttfb is the
+// download time to first byte in ms (0 if unknown).
+func CalculateStabilityScore(packetLoss, jitter, avgLatency float64, speedCVs []float64, ttfb float64) float64 {
 	score := 100.0
-	
-	// Penalize packet loss (each 1% = -5 points)
-	score -= packetLoss * 5
-	if score < 0 {
-		score = 0
+
+	// Penalize packet loss (each 1% = -5 points, max -50)
+	score -= math.Min(packetLoss*5, 50)
+
+	// Penalize jitter above 20ms (each 10ms = -2 points, max -20)
+	if jitter > 20 {
+		score -= math.Min((jitter-20)/10*2, 20)
 	}
-	
-	// Penalize high jitter (each 10ms = -2 points, max -20)
-	jitterPenalty := math.Min(jitter/10.0*2.0, 20.0)
-	score -= jitterPenalty
-	
-	// Penalize high speed variance (each 10% variance = -1 point, max -15)
-	variancePenalty := math.Min(speedVariance/10.0, 15.0)
-	score -= variancePenalty
-	
-	// Bonus for low latency (< 20ms = +5 points)
-	if avgLatency > 0 && avgLatency < 20 {
+
+	// Penalize latency above 100ms (each 10ms = -1 point, max -20)
+	if avgLatency > 100 {
+		score -= math.Min((avgLatency-100)/10, 20)
+	}
+
+	// Penalize unstable throughput above 20% CV (each 10% = -2 points, max -15 per test)
+	for _, cv := range speedCVs {
+		if cv > 20 {
+			score -= math.Min((cv-20)/10*2, 15)
+		}
+	}
+
+	// Penalize TTFB above 500ms (each 100ms = -2 points, max -10)
+	if ttfb > 500 {
+		score -= math.Min((ttfb-500)/100*2, 10)
+	}
+
+	// Bonus for an excellent connection
+	if avgLatency < 20 && jitter < 10 && packetLoss < 0.5 {
 		score += 5
 	}
-	
+
 	// Ensure score is between 0 and 100
-	if score > 100 {
-		score = 100
-	}
-	if score < 0 {
-		score = 0
+	return math.Max(0, math.Min(100, score))
+}
+
+// CoefficientOfVariation returns the standard deviation of a set of samples
+// as a percentage of their mean, given their variance
+func CoefficientOfVariation(variance, mean float64) float64 {
+	if variance <= 0 || mean <= 0 {
+		return 0
 	}
-	
-	return score
+	return math.Sqrt(variance) / mean * 100
 }
 
 // IsSpeedStable checks if speed has stabilized based on recent samples
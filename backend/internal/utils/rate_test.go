@@ -0,0 +1,96 @@
+package utils
+
+import (
+	"math"
+	"testing"
+)
+
+func approxEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestCalculatePercentile(t *testing.T) {
+	tests := []struct {
+		name    string
+		samples []float64
+		p       float64
+		want    float64
+	}{
+		{name: "empty", samples: nil, p: 50, want: 0},
+		{name: "single", samples: []float64{7}, p: 99, want: 7},
+		{name: "median odd", samples: []float64{3, 1, 2}, p: 50, want: 2},
+		{name: "median even", samples: []float64{4, 1, 3, 2}, p: 50, want: 2.5},
+		{name: "minimum", samples: []float64{5, 1, 9}, p: 0, want: 1},
+		{name: "maximum", samples: []float64{5, 1, 9}, p: 100, want: 9},
+		{name: "interpolated", samples: []float64{10, 20, 30, 40, 50}, p: 90, want: 46},
+		{name: "p99 of 1..100", samples: series(1, 100), p: 99, want: 99.01},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CalculatePercentile(tt.samples, tt.p); !approxEqual(got, tt.want) {
+				t.Errorf("CalculatePercentile(%v) = %v, want %v", tt.p, got, tt.want)
+			}
+		})
+	}
+
+	// The samples keep their order
+	samples := []float64{3, 1, 2}
+	CalculatePercentile(samples, 50)
+	if samples[0] != 3 || samples[1] != 1 || samples[2] != 2 {
+		t.Errorf("CalculatePercentile sorted its input: %v", samples)
+	}
+}
+
+func TestCalculateStdDev(t *testing.T) {
+	tests := []struct {
+		samples []float64
+		want    float64
+	}{
+		{samples: nil, want: 0},
+		{samples: []float64{5}, want: 0},
+		{samples: []float64{4, 4, 4}, want: 0},
+		{samples: []float64{2, 4, 4, 4, 5, 5, 7, 9}, want: 2},
+		{samples: []float64{1, 3}, want: 1},
+	}
+
+	for _, tt := range tests {
+		if got := CalculateStdDev(tt.samples); !approxEqual(got, tt.want) {
+			t.Errorf("CalculateStdDev(%v) = %v, want %v", tt.samples, got, tt.want)
+		}
+	}
+}
+
+func TestCalculateRFC3550Jitter(t *testing.T) {
+	tests := []struct {
+		name      string
+		latencies []float64
+		want      float64
+	}{
+		{name: "empty", latencies: nil, want: 0},
+		{name: "single", latencies: []float64{10}, want: 0},
+		{name: "constant", latencies: []float64{10, 10, 10, 10}, want: 0},
+		{name: "one step", latencies: []float64{10, 26}, want: 1},
+		// J = 1, then 1 + (16-1)/16
+		{name: "alternating", latencies: []float64{10, 26, 10}, want: 1.9375},
+		// Arrival order matters: the same values sorted vary once
+		{name: "sorted", latencies: []float64{10, 10, 26}, want: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CalculateRFC3550Jitter(tt.latencies); !approxEqual(got, tt.want) {
+				t.Errorf("CalculateRFC3550Jitter(%v) = %v, want %v", tt.latencies, got, tt.want)
+			}
+		})
+	}
+}
+
+// series returns from, from+1, ..., to
+func series(from, to int) []float64 {
+	var out []float64
+	for i := from; i <= to; i++ {
+		out = append(out, float64(i))
+	}
+	return out
+}
@@ -1,50 +1,95 @@
 package main
 
 import (
+	"crypto/tls"
+	"flag"
 	"log"
+	"net"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
+	"nova-speed/backend/internal/clientip"
 	"nova-speed/backend/internal/config"
 	"nova-speed/backend/internal/handlers"
+	"nova-speed/backend/internal/listen"
 	"nova-speed/backend/internal/logger"
 	"nova-speed/backend/internal/middleware"
+	"nova-speed/backend/internal/quicproto"
 	"nova-speed/backend/internal/services"
-	"strings"
+	"nova-speed/backend/internal/storage"
+	"nova-speed/backend/internal/tlsutil"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/fiber/v2/middleware/recover"
+	"github.com/quic-go/quic-go"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
 func main() {
+	// Subcommands; anything else starts the server
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "config":
+			os.Exit(runConfigCommand(os.Args[2:]))
+		case "client":
+			os.Exit(runClientCommand(os.Args[2:]))
+		}
+	}
+
+	configPath := flag.String("config", "", "path to a YAML or TOML config file (defaults to $"+config.ConfigPathEnv+")")
+	flag.Parse()
+
 	// Initialize logger
-	appLogger := logger.NewLogger()
+	logLevel := zap.NewAtomicLevel()
+	appLogger := logger.NewLogger(logLevel)
 	defer appLogger.Sync()
 
 	// Load configuration
-	cfg := config.Load()
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		appLogger.Fatal("Failed to load configuration", zap.Error(err))
+	}
+	configStore := config.NewStore(*configPath, cfg)
+	setLogLevel(logLevel, cfg.Server.LogLevel)
 
 	// Create Fiber app
 	app := fiber.New(fiber.Config{
 		AppName:      "Nova Speed Test Backend",
 		ServerHeader: "Nova-Speed",
 		ErrorHandler: middleware.ErrorHandler,
+		// The banner only describes one address; each listener is logged instead
+		DisableStartupMessage: true,
+		// POST /api/upload reads its body as it arrives instead of buffering
+		// it; no other route reads a request body
+		StreamRequestBody: true,
 	})
 
 	// Middleware
 	app.Use(recover.New())
+
+	// Resolve the real client IP first so every later middleware and
+	// handler (logging, limits, /info) sees the same address
+	app.Use(middleware.ClientIP(func() *clientip.Resolver {
+		return configStore.Get().ClientIPResolver()
+	}))
+
+	// Optional plain HTTP -> HTTPS redirect when native TLS is enabled. The
+	// HTTPS listeners only change on restart, so the redirect follows the
+	// startup config: a reload must not send clients to a port not yet open.
+	httpsPort, redirectHTTP := cfg.TLS.HTTPSPort(), cfg.TLS.Enabled() && cfg.TLS.RedirectHTTP
+	app.Use(middleware.HTTPSRedirect(func() (string, bool) {
+		return httpsPort, redirectHTTP
+	}))
 	
-	// Configure CORS
-	corsOrigins := cfg.AllowedOrigins
-	if corsOrigins == "*" {
-		corsOrigins = "*"
-	}
-	
+	// Configure CORS (origins are read from the live config so reloads apply)
 	app.Use(cors.New(cors.Config{
-		AllowOrigins:     corsOrigins,
+		AllowOriginsFunc: func(origin string) bool {
+			return configStore.Get().IsOriginAllowed(origin)
+		},
 		AllowMethods:     "GET,POST,OPTIONS",
 		AllowHeaders:     "Origin,Content-Type,Accept,Authorization",
 		AllowCredentials: true,
@@ -55,7 +100,7 @@ func main() {
 	app.Use(middleware.SecurityHeaders())
 
 	// Connection limit middleware
-	connLimiter := middleware.NewConnectionLimiter(cfg.MaxConnections)
+	connLimiter := middleware.NewConnectionLimiter(cfg.Limits.MaxConnections)
 	app.Use(connLimiter.Middleware())
 
 	// Request logging middleware
@@ -71,26 +116,53 @@ func main() {
 
 	// Initialize geolocation service (optional, graceful degradation if DB not available)
 	var geoService *services.GeolocationService
-	if cfg.GeoIPCityPath != "" {
-		geo, err := services.NewGeolocationService(appLogger, cfg.GeoIPCityPath)
+	if cfg.GeoIP.CityPath != "" {
+		geo, err := services.NewGeolocationService(appLogger, cfg.GeoIP.CityPath)
 		if err != nil {
-			appLogger.Warn("Geolocation service not available", zap.Error(err), zap.String("path", cfg.GeoIPCityPath))
+			appLogger.Warn("Geolocation service not available", zap.Error(err), zap.String("path", cfg.GeoIP.CityPath))
 			appLogger.Info("IP info endpoint will return IP only (no geolocation)")
 		} else {
 			geoService = geo
 			defer geoService.Close()
-			appLogger.Info("Geolocation service initialized", zap.String("path", cfg.GeoIPCityPath))
+			appLogger.Info("Geolocation service initialized", zap.String("path", cfg.GeoIP.CityPath))
 		}
 	} else {
 		appLogger.Info("GeoIP path not configured, IP info endpoint will return IP only")
 	}
 
+	// Test sessions aggregate phase results under one ID
+	sessionService := services.NewSessionService(appLogger, cfg.Sessions.TTL, cfg.Sessions.MaxSessions)
+	stopSessions := make(chan struct{})
+	defer close(stopSessions)
+	go sessionService.Run(time.Minute, stopSessions)
+
+	// Completed results are persisted when a storage path is configured
+	var repo storage.Repository
+	if cfg.Storage.Path != "" {
+		boltRepo, err := storage.OpenBolt(cfg.Storage.Path)
+		if err != nil {
+			appLogger.Fatal("Failed to open result store", zap.Error(err))
+		}
+		defer boltRepo.Close()
+		repo = boltRepo
+		appLogger.Info("Result storage enabled", zap.String("path", cfg.Storage.Path))
+	} else {
+		appLogger.Info("Result storage disabled")
+	}
+	resultService := services.NewResultService(appLogger, repo, geoService)
+
 	// Initialize handlers
-	testHandler := handlers.NewTestHandler(appLogger, cfg)
+	testHandler := handlers.NewTestHandler(appLogger, configStore, sessionService, resultService)
+	sessionHandler := handlers.NewSessionHandler(appLogger, sessionService)
+	sessionHandler.RegisterRoutes(app)
+	adminHandler := handlers.NewAdminHandler(appLogger, configStore)
+	adminHandler.RegisterRoutes(app)
+	resultHandler := handlers.NewResultHandler(appLogger, resultService)
+	resultHandler.RegisterRoutes(app, adminHandler.RequireToken)
 	
 	// Initialize info handler (always register, with or without geolocation)
 	if geoService != nil {
-		infoHandler := handlers.NewInfoHandler(appLogger, cfg, geoService)
+		infoHandler := handlers.NewInfoHandler(appLogger, configStore, geoService)
 		app.Get("/info", infoHandler.HandleInfo)
 		
 		// Test endpoint to test geolocation with any IP
@@ -112,26 +184,8 @@ func main() {
 	} else {
 		// Fallback endpoint that returns just IP
 		app.Get("/info", func(c *fiber.Ctx) error {
-			// Try to get real IP from headers
-			ip := c.Get("X-Real-IP")
-			if ip == "" {
-				ip = c.Get("X-Forwarded-For")
-				if ip != "" {
-					// Take first IP if multiple
-					if idx := strings.Index(ip, ","); idx != -1 {
-						ip = strings.TrimSpace(ip[:idx])
-					}
-				}
-			}
-			if ip == "" {
-				ip = c.Get("CF-Connecting-IP")
-			}
-			if ip == "" {
-				ip = c.IP()
-			}
-			
 			return c.JSON(fiber.Map{
-				"ip":      ip,
+				"ip":      middleware.GetClientIP(c),
 				"error":   "Geolocation database not available",
 				"message": "Install MaxMind GeoLite2 database to enable geolocation",
 			})
@@ -139,22 +193,115 @@ func main() {
 		appLogger.Info("IP info endpoint enabled at /info (IP only, no geolocation)")
 	}
 
-	// Register WebSocket routes
+	// Register WebSocket routes, and the plain HTTP tests next to them
 	testHandler.RegisterWebSocketRoutes(app)
+	testHandler.RegisterHTTPRoutes(app)
 
-	// Start server
-	go func() {
-		addr := ":" + cfg.Port
-		appLogger.Info("Starting server", zap.String("address", addr))
-		if err := app.Listen(addr); err != nil {
-			appLogger.Fatal("Server failed to start", zap.Error(err))
+	// Native HTTPS listeners serve the same app, with certificates reloaded
+	// from disk when they change
+	var certReloader *tlsutil.CertReloader
+	var tlsConfig *tls.Config
+	stopTLSWatch := make(chan struct{})
+	defer close(stopTLSWatch)
+	if cfg.TLS.Enabled() {
+		certReloader, err = tlsutil.NewCertReloader(appLogger, cfg.TLS.CertFile, cfg.TLS.KeyFile)
+		if err != nil {
+			appLogger.Fatal("Failed to load TLS certificate", zap.Error(err))
+		}
+		tlsConfig, err = tlsutil.ServerConfig(cfg.TLS.MinVersion, cfg.TLS.CipherSuites, certReloader.GetCertificate)
+		if err != nil {
+			appLogger.Fatal("Invalid TLS configuration", zap.Error(err))
+		}
+		go certReloader.Watch(cfg.TLS.ReloadInterval, stopTLSWatch)
+	}
+
+	// Open every listener before serving any, so a bad address fails
+	// startup instead of leaving a partially reachable server
+	listeners := openListeners(appLogger, cfg.ListenAddrs(), nil)
+	if tlsConfig != nil {
+		listeners = append(listeners, openListeners(appLogger, cfg.TLS.ListenAddrs(), tlsConfig)...)
+	}
+
+	// Optional QUIC listener for the same tests over UDP
+	if cfg.QUIC.Listen != "" {
+		quicListener := openQUICListener(appLogger, cfg.QUIC.Listen, certReloader)
+		defer quicListener.Close()
+		go testHandler.ServeQUIC(quicListener, connLimiter)
+	}
+
+	// Optional raw TCP port for throughput tests without WebSocket framing
+	if cfg.TCP.Listen != "" {
+		tcpListener := openListeners(appLogger, []string{cfg.TCP.Listen}, nil)[0]
+		defer tcpListener.Close()
+		go testHandler.ServeTCP(tcpListener, connLimiter)
+	}
+
+	// Optional UDP listener for latency tests that see real packet loss
+	if cfg.UDP.Listen != "" {
+		udpConn, err := net.ListenPacket("udp", cfg.UDP.Listen)
+		if err != nil {
+			appLogger.Fatal("UDP listener failed to start", zap.Error(err), zap.String("address", cfg.UDP.Listen))
 		}
-	}()
+		defer udpConn.Close()
+		appLogger.Info("UDP test listener started", zap.String("address", udpConn.LocalAddr().String()))
+		go testHandler.ServeUDP(udpConn)
+	}
 
-	// Graceful shutdown
+	// Start server; fasthttp tracks every listener, so app.Shutdown closes them all
+	for _, ln := range listeners {
+		go func(ln net.Listener) {
+			if err := app.Listener(ln); err != nil {
+				appLogger.Fatal("Server failed", zap.String("address", ln.Addr().String()), zap.Error(err))
+			}
+		}(ln)
+	}
+
+	// Apply the reloadable parts of a new config to the running services.
+	// Handlers read the store themselves at the start of each test.
+	configStore.OnReload(func(old, updated *config.Config) {
+		setLogLevel(logLevel, updated.Server.LogLevel)
+		connLimiter.SetMaxConnections(updated.Limits.MaxConnections)
+		sessionService.SetLimits(updated.Sessions.TTL, updated.Sessions.MaxSessions)
+
+		if certReloader != nil && updated.TLS.Enabled() {
+			if err := certReloader.SetFiles(updated.TLS.CertFile, updated.TLS.KeyFile); err != nil {
+				appLogger.Error("Failed to reload TLS certificate", zap.Error(err))
+			}
+		}
+
+		if updated.GeoIP.CityPath != old.GeoIP.CityPath {
+			switch {
+			case geoService == nil:
+				appLogger.Warn("GeoIP was not available at startup, restart required to enable it",
+					zap.String("path", updated.GeoIP.CityPath))
+			case updated.GeoIP.CityPath == "":
+				appLogger.Warn("GeoIP path cleared, keeping current database until restart",
+					zap.String("path", geoService.DBPath()))
+			default:
+				if err := geoService.Reload(updated.GeoIP.CityPath); err != nil {
+					appLogger.Error("Failed to reload GeoIP database", zap.Error(err),
+						zap.String("path", updated.GeoIP.CityPath))
+				} else {
+					appLogger.Info("GeoIP database reloaded", zap.String("path", updated.GeoIP.CityPath))
+				}
+			}
+		}
+	})
+
+	// Graceful shutdown; SIGHUP reloads the configuration instead
 	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
-	<-quit
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	for sig := range quit {
+		if sig != syscall.SIGHUP {
+			break
+		}
+		restartRequired, err := configStore.Reload()
+		if err != nil {
+			appLogger.Error("Configuration reload failed, keeping current configuration", zap.Error(err))
+			continue
+		}
+		appLogger.Info("Configuration reloaded", zap.Strings("restartRequired", restartRequired))
+	}
 
 	appLogger.Info("Shutting down server...")
 	if err := app.Shutdown(); err != nil {
@@ -164,3 +311,63 @@ func main() {
 	log.Println("Server exited")
 }
 
+// openListeners opens each configured address, wrapping it in TLS when
+// tlsConfig is set. Any failure is fatal.
+func openListeners(appLogger *zap.Logger, addrs []string, tlsConfig *tls.Config) []net.Listener {
+	var listeners []net.Listener
+	for _, raw := range addrs {
+		addr, err := listen.Parse(raw)
+		if err != nil {
+			appLogger.Fatal("Invalid listen address", zap.Error(err))
+		}
+		ln, err := addr.Listen()
+		if err != nil {
+			appLogger.Fatal("Server failed to start", zap.Error(err))
+		}
+		if tlsConfig != nil {
+			ln = tls.NewListener(ln, tlsConfig)
+		}
+
+		appLogger.Info("Starting server",
+			zap.String("address", addr.String()),
+			zap.String("network", addr.Network),
+			zap.Bool("tls", tlsConfig != nil),
+		)
+		listeners = append(listeners, ln)
+	}
+	return listeners
+}
+
+// setLogLevel applies a level name that has already passed config validation
+func setLogLevel(level zap.AtomicLevel, name string) {
+	if l, err := zapcore.ParseLevel(name); err == nil {
+		level.SetLevel(l)
+	}
+}
+
+// openQUICListener opens the QUIC test listener with the HTTPS certificate,
+// or a self-signed one for localhost when TLS is not configured. Any failure
+// is fatal.
+func openQUICListener(appLogger *zap.Logger, addr string, certReloader *tlsutil.CertReloader) *quic.Listener {
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS13, // Required by QUIC
+		NextProtos: []string{quicproto.ALPN},
+	}
+	if certReloader != nil {
+		tlsConfig.GetCertificate = certReloader.GetCertificate
+	} else {
+		cert, err := tlsutil.SelfSigned("localhost", "127.0.0.1", "::1")
+		if err != nil {
+			appLogger.Fatal("Failed to generate QUIC certificate", zap.Error(err))
+		}
+		tlsConfig.Certificates = []tls.Certificate{*cert}
+		appLogger.Warn("No TLS certificate configured, QUIC uses a self-signed one; clients must skip verification")
+	}
+
+	ln, err := quic.ListenAddr(addr, tlsConfig, quicproto.NewConfig())
+	if err != nil {
+		appLogger.Fatal("QUIC listener failed to start", zap.Error(err), zap.String("address", addr))
+	}
+	appLogger.Info("QUIC test listener started", zap.String("address", ln.Addr().String()))
+	return ln
+}
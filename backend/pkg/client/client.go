@@ -0,0 +1,679 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"nova-speed/backend/internal/models"
+	"nova-speed/backend/internal/utils"
+
+	"github.com/fasthttp/websocket"
+)
+
+// DefaultUploadChunkSize is used until the server sends its start message
+const DefaultUploadChunkSize = 256 * 1024
+
+const defaultUploadMaxDuration = 15 * time.Second
+
+// Client runs tests against one server. Set its fields before the first
+// test; a Client may then be used by several goroutines.
+type Client struct {
+	// TLSConfig is used for wss:// servers; nil means the system defaults
+	TLSConfig *tls.Config
+
+	// Timeout is the longest silence tolerated from the server (default 30s)
+	Timeout time.Duration
+
+	// HandshakeTimeout bounds each WebSocket handshake (default 10s)
+	HandshakeTimeout time.Duration
+
+	// SessionID, if set, records every test into that server session (see
+	// CreateSession)
+	SessionID string
+
+	// QUICAddr, if set, is the host:port of the server's QUIC listener.
+	// Ping, Download and Upload then run over QUIC instead of WebSockets;
+	// RunTest and multi-stream downloads still use WebSockets. TLSConfig
+	// applies to the QUIC handshake too.
+	QUICAddr string
+
+	// TCPAddr is the host:port of the server's raw TCP test port, used by
+	// TCPDownload and TCPUpload
+	TCPAddr string
+
+	baseURL *url.URL
+	err     error // Invalid server URL, reported by every test
+}
+
+// New creates a client for a server base URL. http(s):// and ws(s):// are
+// both accepted; the path, if any, is replaced by the test endpoints. An
+// invalid URL is reported by the first test run.
+func New(serverURL string) *Client {
+	c := &Client{
+		Timeout:          30 * time.Second,
+		HandshakeTimeout: 10 * time.Second,
+	}
+	c.baseURL, c.err = parseServerURL(serverURL)
+	return c
+}
+
+func parseServerURL(serverURL string) (*url.URL, error) {
+	u, err := url.Parse(serverURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid server URL: %w", err)
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http", "ws":
+		u.Scheme = "ws"
+	case "https", "wss":
+		u.Scheme = "wss"
+	default:
+		return nil, fmt.Errorf("unsupported scheme %q (use http, https, ws or wss)", u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("server URL %q has no host", serverURL)
+	}
+	return u, nil
+}
+
+// Err reports whether the server URL given to New was invalid
+func (c *Client) Err() error {
+	return c.err
+}
+
+// URL returns the WebSocket URL of a test endpoint
+func (c *Client) URL(endpoint string) string {
+	return c.endpointURL(endpoint, url.Values{})
+}
+
+// endpointURL returns the WebSocket URL of a test endpoint with query,
+// plus the session
+func (c *Client) endpointURL(endpoint string, query url.Values) string {
+	if c.baseURL == nil {
+		return ""
+	}
+	u := *c.baseURL
+	u.Path = "/ws/" + endpoint
+	if c.SessionID != "" {
+		query.Set("session", c.SessionID)
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// apiURL returns the HTTP URL of a REST endpoint
+func (c *Client) apiURL(path string) string {
+	u := *c.baseURL
+	u.Scheme = strings.Replace(u.Scheme, "ws", "http", 1)
+	u.Path = path
+	u.RawQuery = ""
+	return u.String()
+}
+
+// CreateSession asks the server for a new session. Set c.SessionID to its
+// ID to aggregate the following tests under it.
+func (c *Client) CreateSession(ctx context.Context) (*Session, error) {
+	var session Session
+	if err := c.doJSON(ctx, http.MethodPost, "/api/sessions", &session); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// GetSession fetches a session with the results recorded so far
+func (c *Client) GetSession(ctx context.Context, id string) (*Session, error) {
+	var session Session
+	if err := c.doJSON(ctx, http.MethodGet, "/api/sessions/"+url.PathEscape(id), &session); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (c *Client) doJSON(ctx context.Context, method, path string, out interface{}) error {
+	if c.err != nil {
+		return c.err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.apiURL(path), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	httpClient := &http.Client{
+		Timeout:   c.Timeout,
+		Transport: &http.Transport{TLSClientConfig: c.TLSConfig, Proxy: http.ProxyFromEnvironment},
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&apiErr)
+		if apiErr.Error == "" {
+			apiErr.Error = resp.Status
+		}
+		return fmt.Errorf("%s %s: %s", method, path, apiErr.Error)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// messageConn is a test connection: a WebSocket or a QUIC stream
+type messageConn interface {
+	ReadMessage() (messageType int, data []byte, err error)
+	WriteMessage(messageType int, data []byte) error
+	WriteJSON(v interface{}) error
+	SetReadDeadline(t time.Time) error
+	SetWriteDeadline(t time.Time) error
+	Close() error
+}
+
+// open connects to a single test: a QUIC stream when QUICAddr is set,
+// otherwise the test's WebSocket endpoint. Either way the test's start
+// message has been sent; chunkSize is the initial download chunk size and
+// loadedLatency asks for latency probes during a download or upload.
+func (c *Client) open(ctx context.Context, start models.StreamStartMessage) (messageConn, error) {
+	start.Type = "start"
+	start.SessionID = c.SessionID
+	if c.QUICAddr != "" {
+		return c.dialQUIC(ctx, start)
+	}
+
+	// The WebSocket endpoints take loaded latency in the query string and
+	// the rest in their own start message
+	query := url.Values{}
+	if start.LoadedLatency {
+		query.Set("latency", "1")
+	}
+	conn, err := c.dial(ctx, start.Test, query)
+	if err != nil {
+		return nil, err
+	}
+	var msg interface{}
+	switch start.Test {
+	case "ping":
+		// Sent even without settings, so the server needn't wait for it
+		msg = models.TestStartMessage{Type: "start", PingCount: start.PingCount, PingInterval: start.PingInterval}
+	case "download":
+		msg = models.DownloadMessage{Type: "start", ChunkSize: start.ChunkSize}
+	}
+	if msg != nil {
+		if err := conn.WriteJSON(msg); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("%s: failed to send start message: %w", start.Test, err)
+		}
+	}
+	return conn, nil
+}
+
+func (c *Client) dial(ctx context.Context, endpoint string, query url.Values) (*websocket.Conn, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	dialer := &websocket.Dialer{
+		HandshakeTimeout: c.HandshakeTimeout,
+		TLSClientConfig:  c.TLSConfig,
+	}
+	target := c.endpointURL(endpoint, query)
+	conn, _, err := dialer.DialContext(ctx, target, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", target, err)
+	}
+
+	// Unblock reads and writes when the context is cancelled
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+	return conn, nil
+}
+
+func (c *Client) readDeadline() time.Time {
+	if c.Timeout <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(c.Timeout)
+}
+
+// Ping runs the latency test, echoing every ping as a pong
+func (c *Client) Ping(ctx context.Context, opts PingOptions) (*PingResult, error) {
+	conn, err := c.open(ctx, models.StreamStartMessage{
+		Test:         "ping",
+		PingCount:    opts.Count,
+		PingInterval: milliseconds(opts.Interval),
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	return c.ping(ctx, conn, opts)
+}
+
+func (c *Client) ping(ctx context.Context, conn messageConn, opts PingOptions) (*PingResult, error) {
+	for {
+		conn.SetReadDeadline(c.readDeadline())
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return nil, readError(ctx, "ping", err)
+		}
+		received := time.Now().UnixNano()
+
+		var msg struct {
+			Type string `json:"type"`
+		}
+		if err := json.Unmarshal(data, &msg); err != nil {
+			return nil, fmt.Errorf("ping: invalid message: %w", err)
+		}
+
+		switch msg.Type {
+		case "ping":
+			var ping models.PingMessage
+			if err := json.Unmarshal(data, &ping); err != nil {
+				return nil, fmt.Errorf("ping: invalid ping message: %w", err)
+			}
+			// Timestamp the pong so the server can estimate clock offset
+			ping.Type = "pong"
+			ping.ClientReceive = received
+			ping.ClientSend = time.Now().UnixNano()
+			if err := conn.WriteJSON(ping); err != nil {
+				return nil, fmt.Errorf("ping: failed to send pong: %w", err)
+			}
+			if opts.OnProbe != nil {
+				opts.OnProbe(ping.Sequence)
+			}
+		case "result":
+			var result PingResult
+			if err := json.Unmarshal(data, &result); err != nil {
+				return nil, fmt.Errorf("ping: invalid result: %w", err)
+			}
+			return &result, nil
+		case "error":
+			return nil, serverError("ping", data)
+		}
+	}
+}
+
+// Download runs the download test, counting the binary chunks the server
+// streams until it sends its result. With opts.Streams > 1 the test runs
+// over that many connections, which requires SessionID to be set.
+func (c *Client) Download(ctx context.Context, opts DownloadOptions) (*DownloadResult, *DownloadStats, error) {
+	if opts.Streams > 1 {
+		return c.downloadStreams(ctx, opts)
+	}
+
+	conn, err := c.open(ctx, models.StreamStartMessage{Test: "download", ChunkSize: opts.ChunkSize, LoadedLatency: opts.LoadedLatency})
+	if err != nil {
+		return nil, nil, err
+	}
+	defer conn.Close()
+
+	return c.download(ctx, conn, opts)
+}
+
+// downloadStreams runs a multi-stream download. The connections are bound
+// together by the session; every one of them receives the result.
+func (c *Client) downloadStreams(ctx context.Context, opts DownloadOptions) (*DownloadResult, *DownloadStats, error) {
+	if c.SessionID == "" {
+		return nil, nil, errors.New("download: multiple streams require a session")
+	}
+	if c.QUICAddr != "" {
+		return nil, nil, errors.New("download: multiple streams are not supported over QUIC")
+	}
+
+	// Closing the connections once a result arrives ends the other readers
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	conns := make([]*websocket.Conn, opts.Streams)
+	for i := range conns {
+		// Only the first connection, which runs the test, carries probes
+		query := url.Values{}
+		if i == 0 && opts.LoadedLatency {
+			query.Set("latency", "1")
+		}
+		conn, err := c.dial(ctx, "download", query)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer conn.Close()
+
+		start := models.DownloadMessage{Type: "start", ChunkSize: opts.ChunkSize, Streams: opts.Streams}
+		if err := conn.WriteJSON(start); err != nil {
+			return nil, nil, fmt.Errorf("download: failed to send start message: %w", err)
+		}
+		conns[i] = conn
+	}
+
+	counter := newDownloadCounter(opts)
+	counter.stats.Streams = len(conns)
+
+	type outcome struct {
+		result *DownloadResult
+		err    error
+	}
+	outcomes := make(chan outcome, len(conns))
+	for _, conn := range conns {
+		go func(conn *websocket.Conn) {
+			result, err := c.readDownload(ctx, conn, opts, counter)
+			outcomes <- outcome{result, err}
+		}(conn)
+	}
+
+	var firstErr error
+	for range conns {
+		o := <-outcomes
+		if o.result != nil {
+			cancel()
+			return o.result, counter.snapshot(), nil
+		}
+		if firstErr == nil {
+			firstErr = o.err
+		}
+	}
+	return nil, counter.snapshot(), firstErr
+}
+
+// download receives chunks until the result; TTFB is measured from the call
+func (c *Client) download(ctx context.Context, conn messageConn, opts DownloadOptions) (*DownloadResult, *DownloadStats, error) {
+	counter := newDownloadCounter(opts)
+	result, err := c.readDownload(ctx, conn, opts, counter)
+	return result, counter.snapshot(), err
+}
+
+// readDownload reads one download connection until its result, adding the
+// chunks to counter
+func (c *Client) readDownload(ctx context.Context, conn messageConn, opts DownloadOptions, counter *downloadCounter) (*DownloadResult, error) {
+	for {
+		conn.SetReadDeadline(c.readDeadline())
+		messageType, data, err := conn.ReadMessage()
+		if err != nil {
+			return nil, readError(ctx, "download", err)
+		}
+
+		if messageType == websocket.BinaryMessage {
+			counter.add(len(data))
+			continue
+		}
+
+		var result DownloadResult
+		if err := json.Unmarshal(data, &result); err != nil {
+			return nil, fmt.Errorf("download: invalid message: %w", err)
+		}
+		switch result.Type {
+		case "ping":
+			// Idle and loaded latency probes; this reader is the only
+			// writer. A failed pong surfaces as a failed read.
+			pong(conn, nil, data)
+		case "progress":
+			serverProgress(opts.OnServerProgress, data)
+		case "result":
+			return &result, nil
+		case "error":
+			return nil, serverError("download", data)
+		}
+	}
+}
+
+// Upload runs the upload test. Random chunks are sent at the size the
+// server asks for until it sends its result; if it hasn't after
+// opts.MaxDuration the client sends "complete" and waits for the result.
+func (c *Client) Upload(ctx context.Context, opts UploadOptions) (*UploadResult, *UploadStats, error) {
+	conn, err := c.open(ctx, models.StreamStartMessage{Test: "upload", LoadedLatency: opts.LoadedLatency})
+	if err != nil {
+		return nil, nil, err
+	}
+	defer conn.Close()
+
+	return c.upload(ctx, conn, opts)
+}
+
+func (c *Client) upload(ctx context.Context, conn messageConn, opts UploadOptions) (*UploadResult, *UploadStats, error) {
+	maxDuration := opts.MaxDuration
+	if maxDuration <= 0 {
+		maxDuration = defaultUploadMaxDuration
+	}
+
+	var chunkSize atomic.Int64
+	chunkSize.Store(DefaultUploadChunkSize)
+
+	// The reader answers latency probes while the writer sends data. With
+	// probes the server measures idle latency first, so no data may be sent
+	// before its start message.
+	var writeMu sync.Mutex
+	started := make(chan struct{})
+	var startOnce sync.Once
+	if !opts.LoadedLatency {
+		startOnce.Do(func() { close(started) })
+	}
+
+	type outcome struct {
+		result *UploadResult
+		err    error
+	}
+	done := make(chan outcome, 1)
+
+	// Reader: follows chunk size changes and waits for the result
+	go func() {
+		for {
+			conn.SetReadDeadline(c.readDeadline())
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				done <- outcome{err: readError(ctx, "upload", err)}
+				return
+			}
+
+			var msg UploadResult
+			if err := json.Unmarshal(data, &msg); err != nil {
+				done <- outcome{err: fmt.Errorf("upload: invalid message: %w", err)}
+				return
+			}
+			switch msg.Type {
+			case "start", "chunkSize":
+				var update models.UploadMessage
+				if err := json.Unmarshal(data, &update); err == nil && update.ChunkSize > 0 {
+					chunkSize.Store(int64(update.ChunkSize))
+				}
+				startOnce.Do(func() { close(started) })
+			case "ping":
+				// The server may already have stopped reading; its result
+				// still follows
+				pong(conn, &writeMu, data)
+			case "progress":
+				serverProgress(opts.OnServerProgress, data)
+			case "result":
+				done <- outcome{result: &msg}
+				return
+			case "error":
+				done <- outcome{err: serverError("upload", data)}
+				return
+			}
+		}
+	}()
+
+	// Writer: sends the data, sharing the connection with the reader's pongs
+	select {
+	case <-started:
+	case o := <-done:
+		return o.result, &UploadStats{}, o.err
+	}
+	stats := &UploadStats{}
+	progress := newProgressReporter(opts.OnProgress, opts.ProgressInterval)
+	deadline := time.Now().Add(maxDuration)
+	var payload []byte
+	for time.Now().Before(deadline) {
+		select {
+		case o := <-done:
+			return o.result, stats, o.err
+		default:
+		}
+
+		size := int(chunkSize.Load())
+		if len(payload) != size {
+			var err error
+			if payload, err = utils.GenerateRandomPayload(size); err != nil {
+				return nil, stats, fmt.Errorf("upload: failed to generate payload: %w", err)
+			}
+		}
+
+		writeMu.Lock()
+		if c.Timeout > 0 {
+			conn.SetWriteDeadline(time.Now().Add(c.Timeout))
+		}
+		err := conn.WriteMessage(websocket.BinaryMessage, payload)
+		writeMu.Unlock()
+		if err != nil {
+			// The server closes once it has its result; prefer that outcome
+			select {
+			case o := <-done:
+				return o.result, stats, o.err
+			case <-time.After(time.Second):
+				return nil, stats, fmt.Errorf("upload: failed to send chunk: %w", err)
+			}
+		}
+		stats.Bytes += int64(size)
+		stats.Chunks++
+		progress.update(stats.Bytes, stats.Chunks, size)
+	}
+
+	writeMu.Lock()
+	err := conn.WriteJSON(models.UploadMessage{Type: "complete"})
+	writeMu.Unlock()
+	if err != nil {
+		return nil, stats, fmt.Errorf("upload: failed to send complete message: %w", err)
+	}
+	o := <-done
+	return o.result, stats, o.err
+}
+
+// RunTest runs a full test session over /ws/test: the selected phases, in
+// order, on one connection
+func (c *Client) RunTest(ctx context.Context, opts TestOptions) (*TestResult, error) {
+	conn, err := c.dial(ctx, "test", url.Values{})
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	start := models.TestStartMessage{
+		Type:          "start",
+		Phases:        opts.Phases,
+		ChunkSize:     opts.Download.ChunkSize,
+		SessionID:     c.SessionID,
+		LoadedLatency: opts.LoadedLatency,
+		PingCount:     opts.Ping.Count,
+		PingInterval:  milliseconds(opts.Ping.Interval),
+	}
+	opts.Upload.LoadedLatency = opts.LoadedLatency
+	if err := conn.WriteJSON(start); err != nil {
+		return nil, fmt.Errorf("test: failed to send start message: %w", err)
+	}
+
+	for {
+		conn.SetReadDeadline(c.readDeadline())
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return nil, readError(ctx, "test", err)
+		}
+
+		var msg PhaseMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			return nil, fmt.Errorf("test: invalid message: %w", err)
+		}
+
+		switch msg.Type {
+		case "session":
+			var session models.SessionMessage
+			if err := json.Unmarshal(data, &session); err == nil && opts.OnSession != nil {
+				opts.OnSession(session.SessionID)
+			}
+		case "phase":
+			if opts.OnPhase != nil {
+				opts.OnPhase(msg)
+			}
+			if msg.Status != "started" {
+				continue
+			}
+			switch msg.Phase {
+			case "ping":
+				_, err = c.ping(ctx, conn, opts.Ping)
+			case "download":
+				_, _, err = c.download(ctx, conn, opts.Download)
+			case "upload":
+				_, _, err = c.upload(ctx, conn, opts.Upload)
+			default:
+				err = fmt.Errorf("test: unknown phase %q", msg.Phase)
+			}
+			if err != nil {
+				return nil, err
+			}
+		case "summary":
+			var result TestResult
+			if err := json.Unmarshal(data, &result); err != nil {
+				return nil, fmt.Errorf("test: invalid summary: %w", err)
+			}
+			return &result, nil
+		case "error":
+			return nil, serverError("test", data)
+		}
+	}
+}
+
+// pong answers a ping message, under writeMu if the connection has another
+// writer
+func pong(conn messageConn, writeMu *sync.Mutex, data []byte) error {
+	var ping models.PingMessage
+	if err := json.Unmarshal(data, &ping); err != nil {
+		return err
+	}
+	ping.Type = "pong"
+	if writeMu != nil {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+	}
+	return conn.WriteJSON(ping)
+}
+
+// serverProgress passes a progress message to fn, if set
+func serverProgress(fn func(ProgressMessage), data []byte) {
+	if fn == nil {
+		return
+	}
+	var msg ProgressMessage
+	if err := json.Unmarshal(data, &msg); err == nil {
+		fn(msg)
+	}
+}
+
+// milliseconds converts d for the wire, where durations are float ms
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+func readError(ctx context.Context, test string, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return fmt.Errorf("%s: connection lost before result: %w", test, err)
+}
+
+func serverError(test string, data []byte) error {
+	var msg models.ErrorMessage
+	_ = json.Unmarshal(data, &msg)
+	return fmt.Errorf("%s: server error: %w", test, errors.New(msg.Message))
+}
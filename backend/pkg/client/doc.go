@@ -0,0 +1,90 @@
+// Package client runs speed tests against a Nova Speed server from Go.
+//
+//	c := client.New("https://speed.example.com")
+//	ping, err := c.Ping(ctx, client.PingOptions{})
+//	down, _, err := c.Download(ctx, client.DownloadOptions{
+//		OnProgress: func(p client.Progress) { fmt.Printf("%.1f Mbps\n", p.Mbps) },
+//	})
+//
+// Results are the server's own result messages, so they match what the
+// browser client sees.
+//
+// # Wire protocol
+//
+// Each test is one WebSocket connection. A ?session=<id> query parameter
+// (from POST /api/sessions) records its result into that session. Text
+// frames carry JSON messages with a "type" field; any test may end with
+// {"type":"error","message":...}.
+//
+// /ws/ping: the server sends {"type":"ping","timestamp":ns,"sequence":n}
+// on a fixed schedule, without waiting for replies, and the client answers
+// each as it arrives with the same message typed "pong", adding
+// "clientReceive" and "clientSend": when it read the ping and sent the pong,
+// in Unix ns by its own clock. After the last probe the server sends a
+// PingResult ({"type":"result",...}); the timestamps let it report the clock
+// offset and upstream and downstream delays. The client may first send
+// {"type":"start","pingCount":n,"pingInterval":ms} to override the server's
+// probe count and interval; without it the server starts after a short wait.
+// The result's "rtts" holds every probe's RTT by sequence number, null where
+// the probe was lost.
+//
+// /ws/download: the client sends {"type":"start","chunkSize":bytes}
+// (chunkSize 0 means the server default). The server streams binary frames
+// of random data, adapting chunk size as it goes, then sends a
+// DownloadResult. To download over several connections, open each with the
+// same ?session= and add "streams":n to every start message. The server
+// waits up to 5s for all n. It then sends on all of them, and every one
+// receives the result with per-stream "streamResults".
+//
+// While a download or upload runs, the server also sends a ProgressMessage
+// ({"type":"progress","mbps":...,"bytes":...}) every 250ms by default, with
+// the throughput it measured over that interval.
+//
+// /ws/upload: the server sends {"type":"start","chunkSize":bytes}. The
+// client sends binary frames of that size and switches size whenever the
+// server sends {"type":"chunkSize","chunkSize":bytes}. The client may send
+// {"type":"complete"} to finish early; either way the server ends with an
+// UploadResult.
+//
+// Latency under load: with ?latency=1 on /ws/download or /ws/upload
+// ("loadedLatency":true in a QUIC or /ws/test start message), the server
+// sends ping messages before and during the transfer. The client answers
+// each with a pong, as in /ws/ping. Before an upload these idle pings come
+// first, so the client waits for "start" before sending data. The result
+// then carries idleLatency, loadedLatency, latencyIncrease and
+// bufferbloatGrade.
+//
+// QUIC (Client.QUICAddr): the client opens a connection with ALPN
+// "nova-speed" and one bidirectional stream per test. Each message is a
+// frame: a type byte (1 text, 2 binary), a big-endian uint32 length and the
+// payload. The first frame is {"type":"start","test":name,"chunkSize":n,
+// "sessionId":id}, plus "pingCount" and "pingInterval" (ms) in place of the
+// ping query parameters. The stream then carries the messages of the
+// matching WebSocket test, without the download start message.
+//
+// Raw TCP (Client.TCPAddr): every connection starts with one line of JSON.
+// The control connection sends {"type":"start","test":"download"|"upload",
+// "duration":seconds,"flows":n,"sessionId":id} and the server answers
+// {"type":"ready","token":t,"flows":n,"duration":seconds} with the clamped
+// values. Each of the n data connections then sends {"type":"join",
+// "token":t}. Once they have joined, or after 5s, the server sends
+// {"type":"running","flows":n} on the control connection. The data
+// connections carry raw bytes until the duration ends and the server closes
+// them. The server then sends the result line.
+//
+// /ws/udp: the control channel of the UDP latency test. The client sends
+// {"type":"start","count":n,"rate":perSecond} and the server answers
+// {"type":"ready","token":t,"port":p,...}. The probes are 45-byte
+// datagrams (see internal/udpproto). From the control channel's IP, the
+// client sends Hello datagrams to port p until the first Probe arrives and
+// answers every Probe with an Echo. The server then sends a PingResult over
+// the socket.
+//
+// /ws/test: the client sends {"type":"start","phases":[...],"chunkSize":n}
+// (plus "sessionId" to record into an existing session, and "pingCount" and
+// "pingInterval" to tune the ping phase) and the server
+// answers {"type":"session","sessionId":id}. For each phase the server
+// sends {"type":"phase","phase":name,"status":"started"}, runs that phase's protocol as above (without the
+// download start message), then sends the same message with status
+// "completed". The session ends with a TestResult typed "summary".
+package client
@@ -0,0 +1,76 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+
+	"nova-speed/backend/internal/models"
+	"nova-speed/backend/internal/quicproto"
+
+	"github.com/quic-go/quic-go"
+)
+
+// quicConn is a test stream on its own QUIC connection
+type quicConn struct {
+	*quicproto.Conn
+	conn quic.Connection
+}
+
+// Close ends the stream and its connection
+func (q *quicConn) Close() error {
+	q.Conn.Close()
+	return q.conn.CloseWithError(0, "")
+}
+
+// dialQUIC opens a QUIC connection to QUICAddr and a stream for one test,
+// and sends the stream's start message
+func (c *Client) dialQUIC(ctx context.Context, start models.StreamStartMessage) (messageConn, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	tlsConfig := &tls.Config{}
+	if c.TLSConfig != nil {
+		tlsConfig = c.TLSConfig.Clone()
+	}
+	tlsConfig.NextProtos = []string{quicproto.ALPN}
+	if tlsConfig.ServerName == "" {
+		if host, _, err := net.SplitHostPort(c.QUICAddr); err == nil {
+			tlsConfig.ServerName = host
+		}
+	}
+
+	dialCtx := ctx
+	if c.HandshakeTimeout > 0 {
+		var cancel context.CancelFunc
+		dialCtx, cancel = context.WithTimeout(ctx, c.HandshakeTimeout)
+		defer cancel()
+	}
+	conn, err := quic.DialAddr(dialCtx, c.QUICAddr, tlsConfig, quicproto.NewConfig())
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to quic://%s: %w", c.QUICAddr, err)
+	}
+	stream, err := conn.OpenStreamSync(dialCtx)
+	if err != nil {
+		conn.CloseWithError(0, "")
+		return nil, fmt.Errorf("failed to open QUIC stream: %w", err)
+	}
+	qc := &quicConn{Conn: quicproto.NewConn(conn, stream), conn: conn}
+
+	// Unblock reads and writes when the context is cancelled
+	go func() {
+		select {
+		case <-ctx.Done():
+			qc.Close()
+		case <-conn.Context().Done():
+		}
+	}()
+
+	if err := qc.WriteJSON(start); err != nil {
+		qc.Close()
+		return nil, fmt.Errorf("%s: failed to send start message: %w", start.Test, err)
+	}
+	return qc, nil
+}
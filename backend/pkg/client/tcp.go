@@ -0,0 +1,212 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"nova-speed/backend/internal/models"
+	"nova-speed/backend/internal/utils"
+)
+
+// tcpBufferSize is the size of each read or write on a raw TCP flow
+const tcpBufferSize = 128 * 1024
+
+// TCPDownload runs a raw TCP download against TCPAddr: the server sends
+// unframed random data over opts.Flows connections for opts.Duration
+func (c *Client) TCPDownload(ctx context.Context, opts TCPOptions) (*DownloadResult, *DownloadStats, error) {
+	counter := newDownloadCounter(DownloadOptions{OnProgress: opts.OnProgress, ProgressInterval: opts.ProgressInterval})
+	var result DownloadResult
+	flows, err := c.runTCP(ctx, "download", opts, &result, func(conn net.Conn) {
+		buf := make([]byte, tcpBufferSize)
+		for {
+			n, err := conn.Read(buf)
+			if n > 0 {
+				counter.add(n)
+			}
+			if err != nil {
+				return
+			}
+		}
+	})
+	stats := counter.snapshot()
+	stats.Streams = flows
+	if err != nil {
+		return nil, stats, err
+	}
+	return &result, stats, nil
+}
+
+// TCPUpload runs a raw TCP upload against TCPAddr: the client sends
+// unframed random data over opts.Flows connections until the server has
+// measured opts.Duration
+func (c *Client) TCPUpload(ctx context.Context, opts TCPOptions) (*UploadResult, *UploadStats, error) {
+	payload, err := utils.GenerateRandomPayload(tcpBufferSize)
+	if err != nil {
+		return nil, nil, fmt.Errorf("upload: failed to generate payload: %w", err)
+	}
+
+	var mu sync.Mutex
+	stats := &UploadStats{}
+	progress := newProgressReporter(opts.OnProgress, opts.ProgressInterval)
+
+	var result UploadResult
+	_, err = c.runTCP(ctx, "upload", opts, &result, func(conn net.Conn) {
+		for {
+			// The server closes the flows once its time is up
+			n, err := conn.Write(payload)
+			mu.Lock()
+			stats.Bytes += int64(n)
+			stats.Chunks++
+			progress.update(stats.Bytes, stats.Chunks, n)
+			mu.Unlock()
+			if err != nil {
+				return
+			}
+		}
+	})
+
+	mu.Lock()
+	defer mu.Unlock()
+	final := *stats
+	if err != nil {
+		return nil, &final, err
+	}
+	return &result, &final, nil
+}
+
+// runTCP runs one raw TCP test: it opens the control connection, joins the
+// data flows, runs move on each of them and decodes the server's result
+// into result. It returns the number of flows used.
+func (c *Client) runTCP(ctx context.Context, test string, opts TCPOptions, result interface{}, move func(conn net.Conn)) (int, error) {
+	if c.TCPAddr == "" {
+		return 0, fmt.Errorf("%s: no raw TCP address set", test)
+	}
+
+	// Closing every connection once the test ends unblocks the flows
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	control, err := c.dialTCP(ctx)
+	if err != nil {
+		return 0, err
+	}
+	reader := bufio.NewReader(control)
+
+	start := models.TCPMessage{
+		Type:      "start",
+		Test:      test,
+		Duration:  opts.Duration.Seconds(),
+		Flows:     opts.Flows,
+		SessionID: c.SessionID,
+	}
+	if err := writeLine(control, start); err != nil {
+		return 0, fmt.Errorf("%s: failed to send start message: %w", test, err)
+	}
+
+	var ready models.TCPMessage
+	if err := c.readLine(ctx, test, control, reader, "ready", &ready, c.readDeadline()); err != nil {
+		return 0, err
+	}
+
+	var wg sync.WaitGroup
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+	flows := make([]net.Conn, 0, ready.Flows)
+	for i := 0; i < ready.Flows; i++ {
+		conn, err := c.dialTCP(ctx)
+		if err != nil {
+			return 0, err
+		}
+		if err := writeLine(conn, models.TCPMessage{Type: "join", Token: ready.Token}); err != nil {
+			return 0, fmt.Errorf("%s: failed to join flow: %w", test, err)
+		}
+		flows = append(flows, conn)
+	}
+
+	var running models.TCPMessage
+	if err := c.readLine(ctx, test, control, reader, "running", &running, c.readDeadline()); err != nil {
+		return 0, err
+	}
+
+	for _, conn := range flows {
+		wg.Add(1)
+		go func(conn net.Conn) {
+			defer wg.Done()
+			move(conn)
+		}(conn)
+	}
+
+	// The result follows the server's own test duration
+	deadline := time.Time{}
+	if c.Timeout > 0 {
+		deadline = time.Now().Add(time.Duration(ready.Duration*float64(time.Second)) + c.Timeout)
+	}
+	if err := c.readLine(ctx, test, control, reader, "result", result, deadline); err != nil {
+		return running.Flows, err
+	}
+	return running.Flows, nil
+}
+
+// dialTCP connects to TCPAddr; the connection is closed when ctx is done
+func (c *Client) dialTCP(ctx context.Context) (net.Conn, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	dialer := &net.Dialer{Timeout: c.HandshakeTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", c.TCPAddr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to tcp://%s: %w", c.TCPAddr, err)
+	}
+
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+	return conn, nil
+}
+
+// readLine reads the next control message, which must be of type want,
+// into v
+func (c *Client) readLine(ctx context.Context, test string, conn net.Conn, reader *bufio.Reader, want string, v interface{}, deadline time.Time) error {
+	conn.SetReadDeadline(deadline)
+	line, err := reader.ReadBytes('\n')
+	if err != nil {
+		return readError(ctx, test, err)
+	}
+
+	var msg struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(line, &msg); err != nil {
+		return fmt.Errorf("%s: invalid message: %w", test, err)
+	}
+	switch msg.Type {
+	case want:
+		if err := json.Unmarshal(line, v); err != nil {
+			return fmt.Errorf("%s: invalid %s message: %w", test, want, err)
+		}
+		return nil
+	case "error":
+		return serverError(test, line)
+	default:
+		return fmt.Errorf("%s: unexpected %q message", test, msg.Type)
+	}
+}
+
+// writeLine sends v as one line of JSON
+func writeLine(conn net.Conn, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = conn.Write(append(data, '\n'))
+	return err
+}
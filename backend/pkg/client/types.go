@@ -0,0 +1,233 @@
+package client
+
+import (
+	"sync"
+	"time"
+
+	"nova-speed/backend/internal/models"
+	"nova-speed/backend/internal/utils"
+)
+
+// Result types are the server's result messages
+type (
+	PingResult      = models.PingResult
+	DownloadResult  = models.DownloadResult
+	UploadResult    = models.UploadResult
+	TestResult      = models.TestResult
+	PhaseMessage    = models.PhaseMessage
+	ProgressMessage = models.ProgressMessage
+	StreamResult    = models.StreamResult
+
+	LatencyUnderLoad = models.LatencyUnderLoad
+	Session          = models.Session
+
+	ConnectionQuality  = models.ConnectionQuality
+	Readiness          = models.Readiness
+	StreamingReadiness = models.StreamingReadiness
+	GamingReadiness    = models.GamingReadiness
+	VideoCallReadiness = models.VideoCallReadiness
+)
+
+// DefaultProgressInterval is how often progress callbacks fire by default
+const DefaultProgressInterval = 250 * time.Millisecond
+
+// Progress is a snapshot of a running download or upload
+type Progress struct {
+	Bytes     int64         // Bytes transferred so far
+	Chunks    int           // Binary frames transferred so far
+	Elapsed   time.Duration // Time since the test started
+	Mbps      float64       // Average throughput so far
+	ChunkSize int           // Current chunk size (upload only)
+}
+
+// PingOptions configures a ping test. Probe count and interval are set by
+// the server.
+type PingOptions struct {
+	// Count and Interval (between probes) override the server defaults
+	// when set; the server clamps them to its limits
+	Count    int
+	Interval time.Duration
+
+	// OnProbe, if set, is called for every probe answered
+	OnProbe func(sequence int)
+}
+
+// UDPOptions configures a UDP latency test
+type UDPOptions struct {
+	// Count and Rate (probes per second) override the server defaults when
+	// set; the server clamps them to its limits
+	Count int
+	Rate  int
+
+	// OnProbe, if set, is called for every probe echoed. It runs on the
+	// goroutine reading the UDP socket.
+	OnProbe func(sequence int)
+}
+
+// DownloadOptions configures a download test
+type DownloadOptions struct {
+	// ChunkSize is the initial chunk size in bytes; 0 uses the server default
+	ChunkSize int
+
+	// Streams is the number of parallel connections to download over. More
+	// than one requires Client.SessionID; RunTest always uses one.
+	Streams int
+
+	// LoadedLatency asks the server to measure latency before and during
+	// the download (see LatencyUnderLoad in the result). Older servers
+	// ignore it.
+	LoadedLatency bool
+
+	// OnProgress, if set, is called every ProgressInterval while data arrives
+	OnProgress       func(Progress)
+	ProgressInterval time.Duration
+
+	// OnServerProgress, if set, is called for every progress message: the
+	// server's own measurements, sent at the interval it is configured with.
+	// With several streams they arrive on the first connection only.
+	OnServerProgress func(ProgressMessage)
+}
+
+// UploadOptions configures an upload test
+type UploadOptions struct {
+	// MaxDuration stops sending data if the server has not finished by then
+	// (default 15s); the server's own test duration normally ends it first
+	MaxDuration time.Duration
+
+	// LoadedLatency asks the server to measure latency before and during
+	// the upload. Data is then only sent once the server's start message
+	// has arrived.
+	LoadedLatency bool
+
+	// OnProgress, if set, is called every ProgressInterval while data is sent
+	OnProgress       func(Progress)
+	ProgressInterval time.Duration
+
+	// OnServerProgress, if set, is called for every progress message with
+	// the throughput the server receives. It runs on the goroutine reading
+	// the connection, concurrently with OnProgress.
+	OnServerProgress func(ProgressMessage)
+}
+
+// TCPOptions configures a raw TCP download or upload
+type TCPOptions struct {
+	// Duration of the transfer; 0 uses the server's test duration
+	Duration time.Duration
+
+	// Flows is the number of parallel connections; the server caps it at
+	// its stream limit. 0 means one.
+	Flows int
+
+	// OnProgress, if set, is called every ProgressInterval while data moves
+	OnProgress       func(Progress)
+	ProgressInterval time.Duration
+}
+
+// TestOptions configures a full test session. Each phase uses its own
+// options; Download.ChunkSize is sent with the session start message.
+type TestOptions struct {
+	// Phases to run: any of "ping", "download", "upload". The server always
+	// runs them in that order; empty means all three.
+	Phases []string
+
+	Ping     PingOptions
+	Download DownloadOptions
+	Upload   UploadOptions
+
+	// LoadedLatency measures latency under load in the download and upload
+	// phases; the phases' own LoadedLatency options are ignored
+	LoadedLatency bool
+
+	// OnPhase, if set, is called for every phase transition
+	OnPhase func(PhaseMessage)
+
+	// OnSession, if set, is called with the session ID the results are
+	// recorded under: Client.SessionID, or a new one the server created
+	OnSession func(id string)
+}
+
+// DownloadStats are the client-side measurements of a download test
+type DownloadStats struct {
+	Bytes   int64   `json:"bytes"`
+	Chunks  int     `json:"chunks"`
+	TTFB    float64 `json:"ttfb"`              // Milliseconds from start request to first chunk
+	Streams int     `json:"streams,omitempty"` // Connections, if more than one
+}
+
+// downloadCounter accumulates DownloadStats across the connections of a
+// download and reports progress
+type downloadCounter struct {
+	mu       sync.Mutex
+	start    time.Time
+	stats    DownloadStats
+	progress *progressReporter
+}
+
+func newDownloadCounter(opts DownloadOptions) *downloadCounter {
+	return &downloadCounter{
+		start:    time.Now(),
+		progress: newProgressReporter(opts.OnProgress, opts.ProgressInterval),
+	}
+}
+
+// add counts one received chunk of n bytes
+func (d *downloadCounter) add(n int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.stats.Chunks == 0 {
+		d.stats.TTFB = float64(time.Since(d.start).Nanoseconds()) / 1_000_000.0
+	}
+	d.stats.Bytes += int64(n)
+	d.stats.Chunks++
+	d.progress.update(d.stats.Bytes, d.stats.Chunks, n)
+}
+
+// snapshot returns a copy of the stats so far
+func (d *downloadCounter) snapshot() *DownloadStats {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	stats := d.stats
+	return &stats
+}
+
+// UploadStats are the client-side measurements of an upload test
+type UploadStats struct {
+	Bytes  int64 `json:"bytes"`
+	Chunks int   `json:"chunks"`
+}
+
+// progressReporter calls a progress callback at most once per interval
+type progressReporter struct {
+	fn       func(Progress)
+	interval time.Duration
+	start    time.Time
+	last     time.Time
+}
+
+func newProgressReporter(fn func(Progress), interval time.Duration) *progressReporter {
+	if interval <= 0 {
+		interval = DefaultProgressInterval
+	}
+	now := time.Now()
+	return &progressReporter{fn: fn, interval: interval, start: now, last: now}
+}
+
+func (p *progressReporter) update(bytes int64, chunks, chunkSize int) {
+	if p.fn == nil {
+		return
+	}
+	now := time.Now()
+	if now.Sub(p.last) < p.interval {
+		return
+	}
+	p.last = now
+
+	elapsed := now.Sub(p.start)
+	p.fn(Progress{
+		Bytes:     bytes,
+		Chunks:    chunks,
+		Elapsed:   elapsed,
+		Mbps:      utils.CalculateThroughput(bytes, elapsed.Seconds()),
+		ChunkSize: chunkSize,
+	})
+}
@@ -0,0 +1,137 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"time"
+
+	"nova-speed/backend/internal/models"
+	"nova-speed/backend/internal/udpproto"
+)
+
+// udpHelloInterval is how often Hello datagrams are repeated until the
+// first probe arrives, in case one is lost
+const udpHelloInterval = 200 * time.Millisecond
+
+// UDPPing runs the UDP latency test. The control messages go over
+// /ws/udp; the probes are datagrams between the server's UDP port and the
+// client, which echoes each one back.
+func (c *Client) UDPPing(ctx context.Context, opts UDPOptions) (*PingResult, error) {
+	conn, err := c.dial(ctx, "udp", url.Values{})
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if err := conn.WriteJSON(models.UDPMessage{Type: "start", Count: opts.Count, Rate: opts.Rate}); err != nil {
+		return nil, fmt.Errorf("udp: failed to send start message: %w", err)
+	}
+
+	var ready models.UDPMessage
+	conn.SetReadDeadline(c.readDeadline())
+	_, data, err := conn.ReadMessage()
+	if err != nil {
+		return nil, readError(ctx, "udp", err)
+	}
+	if err := json.Unmarshal(data, &ready); err != nil {
+		return nil, fmt.Errorf("udp: invalid message: %w", err)
+	}
+	if ready.Type == "error" {
+		return nil, serverError("udp", data)
+	}
+	if ready.Type != "ready" || len(ready.Token) != udpproto.TokenSize {
+		return nil, fmt.Errorf("udp: unexpected %q message", ready.Type)
+	}
+
+	// The server only accepts datagrams from the control channel's IP, so
+	// stay in its address family on dual-stack hosts
+	network := "udp"
+	if local, ok := conn.LocalAddr().(*net.TCPAddr); ok {
+		network = "udp6"
+		if local.IP.To4() != nil {
+			network = "udp4"
+		}
+	}
+	addr := net.JoinHostPort(c.baseURL.Hostname(), strconv.Itoa(ready.Port))
+	udpConn, err := net.Dial(network, addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to udp://%s: %w", addr, err)
+	}
+	defer udpConn.Close()
+	go echoProbes(udpConn, ready.Token, opts.OnProbe)
+
+	// The server sends the result once the probes are done; allow for the
+	// whole run on top of the usual timeout
+	for {
+		if c.Timeout > 0 {
+			run := time.Duration(ready.Count) * time.Second / time.Duration(max(ready.Rate, 1))
+			conn.SetReadDeadline(time.Now().Add(run + c.Timeout))
+		}
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return nil, readError(ctx, "udp", err)
+		}
+
+		var result PingResult
+		if err := json.Unmarshal(data, &result); err != nil {
+			return nil, fmt.Errorf("udp: invalid message: %w", err)
+		}
+		switch result.Type {
+		case "result":
+			return &result, nil
+		case "error":
+			return nil, serverError("udp", data)
+		}
+	}
+}
+
+// echoProbes greets the server until its first probe arrives, then echoes
+// every probe until conn is closed
+func echoProbes(conn net.Conn, token string, onProbe func(sequence int)) {
+	hello := udpproto.Packet{Type: udpproto.Hello, Token: token}.Marshal()
+	stopHello := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(udpHelloInterval)
+		defer ticker.Stop()
+		for {
+			if _, err := conn.Write(hello); err != nil {
+				return
+			}
+			select {
+			case <-ticker.C:
+			case <-stopHello:
+				return
+			}
+		}
+	}()
+
+	greeted := false
+	buf := make([]byte, 2048)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			if !greeted {
+				close(stopHello)
+			}
+			return
+		}
+		p, err := udpproto.Parse(buf[:n])
+		if err != nil || p.Type != udpproto.Probe || p.Token != token {
+			continue
+		}
+		if !greeted {
+			close(stopHello)
+			greeted = true
+		}
+
+		p.Type = udpproto.Echo
+		conn.Write(p.Marshal())
+		if onProbe != nil {
+			onProbe(int(p.Sequence))
+		}
+	}
+}
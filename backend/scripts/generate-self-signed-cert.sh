@@ -0,0 +1,51 @@
+#!/bin/bash
+
+# Script to generate a self-signed certificate for testing native TLS locally
+# Usage: ./scripts/generate-self-signed-cert.sh [output_dir] [hostname]
+
+set -e
+
+CERT_DIR="${1:-./certs}"
+HOSTNAME="${2:-localhost}"
+
+# Colors for output
+RED='\033[0;31m'
+GREEN='\033[0;32m'
+YELLOW='\033[1;33m'
+NC='\033[0m' # No Color
+
+log_error() {
+    echo -e "${RED}[ERROR]${NC} $1" >&2
+}
+
+log_success() {
+    echo -e "${GREEN}[SUCCESS]${NC} $1"
+}
+
+log_info() {
+    echo -e "${YELLOW}[INFO]${NC} $1"
+}
+
+if ! command -v openssl >/dev/null 2>&1; then
+    log_error "openssl is required but not installed"
+    exit 1
+fi
+
+mkdir -p "$CERT_DIR"
+
+log_info "Generating self-signed certificate for $HOSTNAME in $CERT_DIR"
+openssl req -x509 -newkey rsa:2048 -nodes -days 365 \
+    -keyout "$CERT_DIR/key.pem" \
+    -out "$CERT_DIR/cert.pem" \
+    -subj "/CN=$HOSTNAME" \
+    -addext "subjectAltName=DNS:$HOSTNAME,DNS:localhost,IP:127.0.0.1,IP:::1" 2>/dev/null
+
+chmod 600 "$CERT_DIR/key.pem"
+
+log_success "Certificate written to $CERT_DIR/cert.pem and $CERT_DIR/key.pem"
+echo ""
+echo "Start the server with:"
+echo "  TLS_CERT_FILE=$CERT_DIR/cert.pem TLS_KEY_FILE=$CERT_DIR/key.pem go run ."
+echo ""
+echo "Then test with:"
+echo "  curl --cacert $CERT_DIR/cert.pem https://localhost:3443/health"
@@ -9,6 +9,16 @@ export interface PingResult {
   packetLoss?: number;
   minLatency?: number;
   maxLatency?: number;
+  p50Latency?: number;
+  p90Latency?: number;
+  p95Latency?: number;
+  p99Latency?: number;
+  latencyStdDev?: number;
+  jitterRfc3550?: number;
+  rtts?: (number | null)[]; // By sequence number, null for lost probes
+  clockOffset?: number;
+  upstreamLatency?: number;
+  downstreamLatency?: number;
 }
 
 export interface DownloadResult {
@@ -57,6 +67,12 @@ export interface TestProgress {
 
 type ProgressCallback = (progress: TestProgress) => void;
 
+// nowNs returns the wall clock in Unix nanoseconds, with sub-millisecond
+// precision where the browser allows it
+function nowNs(): number {
+  return Math.round((performance.timeOrigin + performance.now()) * 1_000_000);
+}
+
 export class SpeedTestClient {
   private wsBaseUrl: string;
 
@@ -84,18 +100,24 @@ export class SpeedTestClient {
 
       ws.onopen = () => {
         console.log('Ping test connected');
+        // Start with the server's probe settings rather than waiting for it to time out
+        ws.send(JSON.stringify({ type: 'start' }));
       };
 
       ws.onmessage = (event) => {
+        const received = nowNs();
         try {
           const message = JSON.parse(event.data);
 
           if (message.type === 'ping') {
-            // Echo back immediately as pong
+            // Echo back immediately as pong, timestamped so the server can
+            // estimate clock offset and one-way delays
             const pong = {
               type: 'pong',
               timestamp: message.timestamp,
               sequence: message.sequence,
+              clientReceive: received,
+              clientSend: nowNs(),
             };
             ws.send(JSON.stringify(pong));
 
@@ -114,6 +136,16 @@ export class SpeedTestClient {
               packetLoss: message.packetLoss,
               minLatency: message.minLatency,
               maxLatency: message.maxLatency,
+              p50Latency: message.p50Latency,
+              p90Latency: message.p90Latency,
+              p95Latency: message.p95Latency,
+              p99Latency: message.p99Latency,
+              latencyStdDev: message.latencyStdDev,
+              jitterRfc3550: message.jitterRfc3550,
+              rtts: message.rtts,
+              clockOffset: message.clockOffset,
+              upstreamLatency: message.upstreamLatency,
+              downstreamLatency: message.downstreamLatency,
             };
             resolve(result);
             ws.close();
@@ -149,6 +181,8 @@ export class SpeedTestClient {
       let bytesReceived = 0;
       let startTime: number | null = null;
       let lastUpdateTime = 0;
+      // Once the server sends progress messages, show its measurements instead
+      let serverProgress = false;
 
       ws.onopen = () => {
         console.log('Download test connected');
@@ -171,7 +205,7 @@ export class SpeedTestClient {
             const elapsed = (now - startTime) / 1000; // seconds
 
             // Update progress every 100ms to avoid too many updates
-            if (now - lastUpdateTime >= 100) {
+            if (!serverProgress && now - lastUpdateTime >= 100) {
               const mbps = (bytesReceived * 8) / (elapsed * 1_000_000);
               
               if (onProgress) {
@@ -188,7 +222,16 @@ export class SpeedTestClient {
           // JSON result message
           try {
             const result = JSON.parse(event.data);
-            if (result.type === 'result') {
+            if (result.type === 'progress') {
+              serverProgress = true;
+              if (onProgress) {
+                onProgress({
+                  test: 'download',
+                  value: Math.min(result.mbps, maxSpeed),
+                  unit: 'Mbps',
+                });
+              }
+            } else if (result.type === 'result') {
               const downloadResult: DownloadResult = {
                 throughput: result.throughput,
                 bytes: result.bytes,
@@ -235,6 +278,8 @@ export class SpeedTestClient {
       const testDuration = 10000; // 10 seconds
       let animationFrameId: number | null = null;
       let lastUpdateTime = 0;
+      // Once the server sends progress messages, show its measurements instead
+      let serverProgress = false;
 
       // Throttle sending to prevent overwhelming the connection
       // Calculate target send rate based on reasonable max speed
@@ -298,7 +343,7 @@ export class SpeedTestClient {
         }
 
         // Update progress every 100ms
-        if (now - lastUpdateTime >= 100) {
+        if (!serverProgress && now - lastUpdateTime >= 100) {
           const elapsedSeconds = elapsed / 1000;
           const mbps = (bytesSent * 8) / (elapsedSeconds * 1_000_000);
 
@@ -331,6 +376,15 @@ export class SpeedTestClient {
           } else if (message.type === 'chunkSize') {
             // Server adjusted chunk size
             chunkSize = message.chunkSize;
+          } else if (message.type === 'progress') {
+            serverProgress = true;
+            if (onProgress) {
+              onProgress({
+                test: 'upload',
+                value: Math.min(message.mbps, maxSpeed),
+                unit: 'Mbps',
+              });
+            }
           } else if (message.type === 'result') {
             if (animationFrameId !== null) {
               cancelAnimationFrame(animationFrameId);
@@ -23,7 +23,7 @@ echo ""
 if [ ! -f "$NEW_BINARY" ]; then
     echo -e "${RED}Error: New binary not found at $NEW_BINARY${NC}"
     echo "Please build the backend first:"
-    echo "  cd backend && go build -o ../bin/nova-speed-backend ./main.go"
+    echo "  cd backend && go build -o ../bin/nova-speed-backend ."
     exit 1
 fi
 